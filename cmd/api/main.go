@@ -3,29 +3,217 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"net"
+	"errors"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"rpi-search-ranking/internal/api"
+	"rpi-search-ranking/internal/auth"
+	"rpi-search-ranking/internal/capture"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/monitoring"
 	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/storage"
+	"rpi-search-ranking/internal/training"
 	"rpi-search-ranking/internal/utils"
 	"syscall"
 	"time"
 	"github.com/gorilla/mux"
 )
 
+// Maximum size of a JSON request body
+const maxRequestBodyBytes = 1 << 20
+
+var logger = logging.For("http")
+
+// storageManager tracks the size of the data directory
+var storageManager *storage.Manager
+
+// reporter sends evaluations to the evaluation component, nil when reporting is disabled
+var reporter *utils.Reporter
+
+// captureWriter logs sampled rankings for training, nil when captures are disabled
+var captureWriter *capture.Writer
+
 func main() {
+	modelFile := flag.String("model", "", "Optional path to a model saved by regressiontrain, ranksvmtrain or mlptrain, served under -modelName")
+	modelName := flag.String("modelName", "logistic", "Name under which the -model file is served (e.g., ranksvm or mlp for models saved by ranksvmtrain or mlptrain)")
+	shutdownDelay := flag.Duration("shutdownDelay", 5*time.Second, "Time between failing readiness checks and draining the listener on shutdown")
+	logLevels := flag.String("log", "info", "Log levels as a default level followed by component overrides (e.g., info,ranking=debug,http=warn)")
+	reverseDNS := flag.Bool("reverseDNS", false, "Resolve client hostnames in the background and include them in request logs")
+	authConfig := flag.String("authConfig", "", "Optional path to a JSON file with API clients, quotas and query limits, reloaded on SIGHUP")
+	dataDir := flag.String("dataDir", "./data", "Directory holding captures and spilled evaluations, measured and cleaned up by the storage manager")
+	captureDir := flag.String("captureDir", "captures", "Directory of ranking captures relative to -dataDir, empty disables captures")
+	captureSampleRate := flag.Float64("captureSampleRate", 1, "Share of served rankings that are captured, between 0 and 1")
+	captureMaxBytes := flag.Int64("captureMaxBytes", capture.DefaultConfig().MaxBytes, "Size at which the capture log is rotated")
+	captureQuota := flag.Int64("captureQuota", 1<<30, "Maximum bytes of ranking captures, oldest files are deleted above it (0 disables)")
+	captureMaxAge := flag.Duration("captureMaxAge", 7*24*time.Hour, "Rotated captures older than this are deleted (0 disables)")
+	captureCompactAfter := flag.Duration("captureCompactAfter", time.Hour, "Rotated captures older than this are gzipped (0 disables)")
+	retentionInterval := flag.Duration("retentionInterval", 10*time.Minute, "Time between storage retention runs")
+	interleavingConfig := flag.String("interleaving", "", "Optional path to a JSON file with interleaving experiments between registered models")
+	experimentConfig := flag.String("experiments", "", "Optional path to a JSON file with experiment buckets and shadow rankers")
+	feedbackDir := flag.String("feedbackDir", "./data/feedback", "Directory of the impression and feedback event log, empty disables feedback logging")
+	feedbackMaxBytes := flag.Int64("feedbackMaxBytes", feedback.DefaultConfig().MaxBytes, "Size at which the feedback event log is rotated")
+	feedbackMaxFiles := flag.Int("feedbackMaxFiles", feedback.DefaultConfig().MaxFiles, "Number of rotated feedback event logs kept")
+	feedbackQueueSize := flag.Int("feedbackQueueSize", feedback.DefaultConfig().QueueSize, "Feedback events buffered for the event log before new ones are dropped")
+	evaluationURL := flag.String("evaluationURL", utils.DefaultEvaluationEndpoint, "Evaluation collector URL, empty disables evaluation reporting")
+	evaluationBatch := flag.Bool("evaluationBatch", false, "Send evaluations to the collector as JSON arrays instead of one evaluation per request")
+	evaluationSpill := flag.String("evaluationSpill", "evaluations/spill.jsonl", "File relative to -dataDir receiving evaluations while the collector is unavailable, empty discards them")
+	flag.Parse()
+
+	// Configure structured logging
+	if err := logging.Configure(*logLevels); err != nil {
+		logger.Error("invalid log configuration", "error", err)
+		os.Exit(1)
+	}
+	if *reverseDNS {
+		hostnames = logging.NewHostnameCache(time.Hour, 10000, 16)
+	}
+
+	// Measure storage and apply quotas and retention to the data directory in the background
+	var err error
+	policies := []storage.Policy{{Dir: "evaluations", MaxBytes: 100 << 20}}
+	if *captureDir != "" {
+		policies = append(policies, storage.Policy{Dir: *captureDir, MaxBytes: *captureQuota, MaxAge: *captureMaxAge,
+			CompactAfter: *captureCompactAfter, Skip: []string{capture.ActiveFile}})
+	}
+	storageManager, err = storage.NewManager(*dataDir, policies)
+	if err != nil {
+		logger.Error("failed to measure data directory", "path", *dataDir, "error", err)
+		os.Exit(1)
+	}
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go storageManager.Run(retentionCtx, *retentionInterval)
+
+	// Capture a sample of the served rankings for training, skipping captures while over quota
+	if *captureDir != "" {
+		config := capture.DefaultConfig()
+		config.Dir = filepath.Join(*dataDir, *captureDir)
+		config.SampleRate = *captureSampleRate
+		config.MaxBytes = *captureMaxBytes
+		config.Allowed = func() bool { return storageManager.Allow(*captureDir) }
+		captureWriter, err = capture.NewWriter(config)
+		if err != nil {
+			logger.Error("failed to open capture log", "path", config.Dir, "error", err)
+			os.Exit(1)
+		}
+		ranking.SetCapturer(captureWriter)
+	}
+
+	// Log impressions and user feedback
+	if *feedbackDir != "" {
+		config := feedback.DefaultConfig()
+		config.Dir = *feedbackDir
+		config.MaxBytes = *feedbackMaxBytes
+		config.MaxFiles = *feedbackMaxFiles
+		config.QueueSize = *feedbackQueueSize
+		feedbackRecorder, err := feedback.NewRecorder(config)
+		if err != nil {
+			logger.Error("failed to open feedback log", "path", config.Dir, "error", err)
+			os.Exit(1)
+		}
+		defer feedbackRecorder.Close()
+		api.SetFeedbackRecorder(feedbackRecorder)
+	}
+
+	// Start the evaluation reporter
+	if *evaluationURL != "" {
+		config := utils.DefaultReporterConfig()
+		config.Endpoint = *evaluationURL
+		config.Batch = *evaluationBatch
+		if *evaluationSpill != "" {
+			config.SpillFile = filepath.Join(*dataDir, *evaluationSpill)
+		}
+		reporter = utils.NewReporter(config)
+	}
+
+	// Load API clients and quotas
+	if *authConfig != "" {
+		config, err := auth.LoadConfig(*authConfig)
+		if err != nil {
+			logger.Error("failed to load auth config", "path", *authConfig, "error", err)
+			os.Exit(1)
+		}
+		guard = auth.NewGuard(config)
+		api.SetQueryLimits(config.MaxQueryLength, config.MaxQueryTerms)
+		go reloadAuthConfigOnHangup(*authConfig)
+	}
+
+	// Load the learned ranking model, staying unready if it cannot be loaded
+	if *modelFile != "" {
+		api.ExpectModel(*modelName)
+		model, err := training.LoadModel(*modelFile)
+		if err != nil {
+			logger.Error("failed to load model", "path", *modelFile, "error", err)
+		} else {
+			version, err := training.ModelFileVersion(*modelFile)
+			if err != nil {
+				logger.Warn("failed to compute model version", "path", *modelFile, "error", err)
+			}
+			if missing := model.Schema().Missing(features.RankingSchema()); len(missing) > 0 {
+				logger.Warn("model uses features the ranker does not compute, they are 0 when ranking", "features", missing)
+			}
+			if mismatched := model.Schema().Mismatched(features.RankingSchema()); len(mismatched) > 0 {
+				logger.Warn("model names features differently from the ranker under the same ID, it was likely trained on another dataset format", "features", mismatched)
+			}
+			api.RegisterModel(*modelName, version, model)
+		}
+	}
+
+	// Start interleaving experiments between the registered models
+	if *interleavingConfig != "" {
+		config, err := api.LoadInterleavingConfig(*interleavingConfig)
+		if err == nil {
+			err = api.SetInterleavingExperiments(config.Experiments)
+		}
+		if err != nil {
+			logger.Error("failed to start interleaving experiments", "path", *interleavingConfig, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Start experiment buckets and shadow rankers
+	if *experimentConfig != "" {
+		config, err := api.LoadExperimentConfig(*experimentConfig)
+		if err == nil {
+			err = api.SetExperimentConfig(config)
+		}
+		if err != nil {
+			logger.Error("failed to start experiments", "path", *experimentConfig, "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Initialize the API router
 	r := mux.NewRouter()
 
-	// Add middleware to log the request
+	// Add middleware to tag the request with an ID, log the request, record request metrics and check credentials
+	r.Use(requestIDMiddleware)
 	r.Use(loggingMiddleware)
+	r.Use(metricsMiddleware)
+	r.Use(authMiddleware)
 
 	// Define the endpoint using GET method
 	r.HandleFunc("/getDocumentScores", getDocumentScores).Methods("GET")
 
+	// Define the JSON ranking endpoints using POST method
+	r.HandleFunc("/v1/rank", rankHandler).Methods("POST")
+	r.HandleFunc("/v1/rank/batch", rankBatchHandler).Methods("POST")
+	r.HandleFunc("/v1/explain", explainHandler).Methods("GET")
+	r.HandleFunc("/v1/feedback", feedbackHandler).Methods("POST")
+
+	// Expose metrics in the Prometheus text format
+	r.Handle("/metrics", monitoring.Default.Handler()).Methods("GET")
+
+	// Define the liveness and readiness probes
+	r.HandleFunc("/healthz", healthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", readyzHandler).Methods("GET")
+
 	// Start the server in a goroutine
 	srv := &http.Server{
 		Handler: r,
@@ -34,9 +222,10 @@ func main() {
 	 
 	// Run the server in a separate goroutine
 	go func() {
-		log.Println("Starting Ranking API server on port 6060...")
-		if err := srv.ListenAndServe(); err != nil {
-			log.Fatal("Server failed: ", err)
+		logger.Info("starting ranking API server", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -47,39 +236,60 @@ func main() {
 	// Block until we receive a termination signal
 	<-sig
 
+	// Fail readiness checks first so the orchestrator stops routing new traffic here
+	logger.Info("shutting down the server", "drainDelay", *shutdownDelay)
+	api.SetShuttingDown()
+	time.Sleep(*shutdownDelay)
+
 	// Gracefully shut down the server with a 5-second timeout
-	log.Println("Shutting down the server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server shutdown failed: ", err)
+		logger.Error("server shutdown failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("server gracefully stopped")
+
+	// Flush evaluations still waiting to be sent
+	if err := reporter.Close(ctx); err != nil {
+		logger.Warn("evaluation reporter did not flush in time", "error", err)
+	}
+
+	// Write captures still waiting in the queue
+	if err := captureWriter.Close(ctx); err != nil {
+		logger.Warn("capture writer did not flush in time", "error", err)
 	}
-	log.Println("Server gracefully stopped.")
 }
 
-// loggingMiddleware logs each incoming request with client IP/hostname for debugging purposes
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get the client's IP address from the request
-		clientIP := r.RemoteAddr
-		host, _, err := net.SplitHostPort(clientIP)
+// reloadAuthConfigOnHangup reloads the auth config on every SIGHUP, keeping the current config if the file is invalid
+func reloadAuthConfigOnHangup(filename string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		config, err := auth.LoadConfig(filename)
 		if err != nil {
-			log.Printf("Error extracting IP address from %s: %v", clientIP, err)
-		}
-
-		// Try to perform a reverse DNS lookup to get the hostname
-		hostname, err := net.LookupAddr(host)
-		if err != nil || len(hostname) == 0 {
-			// Fallback if reverse DNS lookup fails
-			hostname = append(hostname, "unknown")
+			logger.Error("failed to reload auth config, keeping the previous config", "path", filename, "error", err)
+			continue
 		}
+		guard.Reload(config)
+		api.SetQueryLimits(config.MaxQueryLength, config.MaxQueryTerms)
+		logger.Info("reloaded auth config", "path", filename, "clients", len(config.Clients))
+	}
+}
 
-		// Log the HTTP method, path, client IP, and resolved hostname
-		log.Printf("Received request: %s %s from IP: %s, Hostname: %s", r.Method, r.URL.Path, host, hostname[0])
+// Handler function for the /healthz liveness endpoint
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, http.StatusOK, map[string]string{"status": api.StatusOK})
+}
 
-		// Pass the request along the chain
-		next.ServeHTTP(w, r)
-	})
+// Handler function for the /readyz readiness endpoint
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	report := api.CheckReadiness(r.Context())
+	statusCode := http.StatusOK
+	if !report.Ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+	sendJSON(w, statusCode, report)
 }
 
 // Handler function for the /getDocumentScores endpoint
@@ -98,31 +308,149 @@ func getDocumentScores(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the internal function to get document scores and geenerate evaluation 
-	docScores, err := api.GetDocumentScores(ranking.Query{Id: queryId, Text: queryText}, evalObj )
+	docScores, err := api.GetDocumentScores(r.Context(), ranking.Query{Id: queryId, Text: queryText}, evalObj )
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, "Failed to retrieve document scores")
+		sendRankError(w, r, err)
 		return
 	}
 
-	// Return the document scores as JSON
+	// Return the document scores as JSON, identifying the impression in a header since the body is an array
+	w.Header().Set(api.ImpressionIDHeader, api.RecordImpression(r.Context(), queryId, queryText, api.DefaultModel, docScores))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(docScores); err != nil {
 		sendError(w, http.StatusInternalServerError, "Failed to encode response")
 	}
 
-	// Get storage
-	err = evalObj.UpdateStorageSize( "./data" ) 
+	reportEvaluation(r.Context(), evalObj)
+}
+
+// Handler function for the /v1/rank endpoint
+func rankHandler(w http.ResponseWriter, r *http.Request) {
+	// Create evaluation for component
+	evalObj := utils.CreateEvaluation()
+
+	// Decode the ranking request from the body
+	var req api.RankRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	response, err := api.Rank(r.Context(), req, evalObj)
 	if err != nil {
-		log.Println( err )
+		sendRankError(w, r, err)
+		return
 	}
 
-	// Send the evalation object to evaluation component
-	err = utils.SendEvaluation( evalObj )
+	sendJSON(w, http.StatusOK, response)
+	reportEvaluation(r.Context(), evalObj)
+}
+
+// Handler function for the /v1/rank/batch endpoint
+func rankBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the batch request from the body
+	var batch api.BatchRankRequest
+	if !decodeJSON(w, r, &batch) {
+		return
+	}
+
+	response, evals, err := api.RankBatch(r.Context(), batch)
 	if err != nil {
-		log.Println( err ) 
+		sendRankError(w, r, err)
+		return
 	}
 
+	sendJSON(w, http.StatusOK, response)
+	for _, evalObj := range evals {
+		reportEvaluation(r.Context(), evalObj)
+	}
+}
+
+// Handler function for the /v1/explain endpoint
+func explainHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract parameters from the URL query string
+	queryText := r.URL.Query().Get("text")
+	docID := r.URL.Query().Get("docID")
+	model := r.URL.Query().Get("model")
+
+	response, err := api.Explain(r.Context(), queryText, docID, model)
+	if errors.Is(err, api.ErrDocumentNotRanked) {
+		sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		sendRankError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, response)
+}
+
+// Handler function for the /v1/feedback endpoint
+func feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the feedback from the body
+	var req api.FeedbackRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := api.Feedback(r.Context(), req)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, api.ErrInvalidRequest):
+		sendError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, api.ErrFeedbackDisabled), errors.Is(err, feedback.ErrDropped):
+		sendError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		logger.ErrorContext(r.Context(), "failed to record feedback", "error", err)
+		sendError(w, http.StatusInternalServerError, "Failed to record feedback")
+	}
+}
+
+// decodeJSON decodes a size-limited JSON request body, sending a 400 response on failure
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// sendRankError maps ranking errors to client or server errors
+func sendRankError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, api.ErrInvalidRequest) {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.ErrorContext(r.Context(), "failed to rank documents", "error", err)
+	sendError(w, http.StatusInternalServerError, api.RankFailedMessage)
+}
+
+// sendJSON sends a JSON response with the given status code
+func sendJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("failed to encode response", "error", err)
+	}
+}
+
+// reportEvaluation records storage usage and queues the evaluation object for the evaluation component
+func reportEvaluation(ctx context.Context, evalObj *utils.Evaluation) {
+	if reporter == nil {
+		return
+	}
+
+	// Get storage as measured by the last retention run
+	evalObj.TotalStorage = storageManager.TotalBytes()
+
+	// Queue the evaluation object, the reporter sends it in the background
+	if !reporter.Report(evalObj) {
+		logger.WarnContext(ctx, "evaluation queue full, dropping evaluation")
+	}
 }
 
 // sendError sends a structured error response
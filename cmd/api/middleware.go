@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"rpi-search-ranking/internal/auth"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/monitoring"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// validRequestID matches request IDs accepted from clients, other values are replaced by a generated ID
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// hostnames resolves client hostnames for request logs when reverse DNS is enabled
+var hostnames *logging.HostnameCache
+
+// guard enforces API credentials and rate limits when an auth config is given
+var guard *auth.Guard
+
+// requestIDMiddleware propagates the client's request ID or generates one, echoing it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		// Pass the request along the chain
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// loggingMiddleware logs each request with the client IP, and the hostname when it has already been resolved
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		// Get the client's IP address from the request
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			logger.WarnContext(r.Context(), "failed to extract IP address", "remoteAddr", r.RemoteAddr, "error", err)
+			host = r.RemoteAddr
+		}
+		attrs := []any{"method", r.Method, "path", r.URL.Path, "clientIP", host}
+
+		// Never wait on reverse DNS, the hostname is logged once the background lookup has finished
+		if hostnames != nil {
+			if hostname, ok := hostnames.Lookup(host); ok && hostname != "" {
+				attrs = append(attrs, "hostname", hostname)
+			}
+		}
+		logger.DebugContext(r.Context(), "received request", attrs...)
+
+		// Pass the request along the chain
+		next.ServeHTTP(recorder, r)
+
+		attrs = append(attrs, "status", recorder.statusCode, "duration", time.Since(startTime))
+		logger.InfoContext(r.Context(), "handled request", attrs...)
+	})
+}
+
+// metricsMiddleware records the count and latency of each request by route, method and status code
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		// Pass the request along the chain
+		next.ServeHTTP(recorder, r)
+
+		// Label by route template so path parameters do not create new series
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		status := strconv.Itoa(recorder.statusCode)
+		monitoring.HTTPRequests.Inc(route, r.Method, status)
+		monitoring.HTTPRequestDuration.Observe(time.Since(startTime).Seconds(), route, r.Method, status)
+	})
+}
+
+// authMiddleware rejects requests without valid credentials and requests over the client or IP quota
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if guard == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clientID, rejection := guard.Authorize(r)
+		if rejection != nil {
+			if rejection.StatusCode == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `APIKey header="`+auth.APIKeyHeader+`"`)
+			}
+			if rejection.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rejection.RetryAfter.Seconds()))))
+			}
+			logger.WarnContext(r.Context(), "rejected request", "path", r.URL.Path, "clientID", clientID,
+				"status", rejection.StatusCode, "reason", rejection.Message)
+			sendError(w, rejection.StatusCode, rejection.Message)
+			return
+		}
+		if clientID != "" {
+			logger.DebugContext(r.Context(), "authenticated request", "clientID", clientID)
+		}
+
+		// Pass the request along the chain
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
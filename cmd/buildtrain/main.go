@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/capture"
+	"rpi-search-ranking/internal/clickmodel"
+	"rpi-search-ranking/internal/datagen"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/trec"
+)
+
+// Create a pairwise comparison dataset from captured rankings of the RPI corpus, labelled with qrels or clicks,
+// in the same format as cmd/datagen
+func main() {
+	captureDir := flag.String("captureDir", "./data/captures", "Directory of the ranking captures written by the API")
+	labels := flag.String("labels", "qrels", "Source of relevance labels: qrels or clicks")
+	qrelsFile := flag.String("qrels", "", "Path to TREC qrels, required with -labels qrels")
+	matchBy := flag.String("matchBy", "id", "Match captures to qrels by query id or normalized query text")
+	feedbackDir := flag.String("feedbackDir", "./data/feedback", "Directory of the feedback event log, used with -labels clicks")
+	model := flag.String("model", "dbn", "Click model used to estimate relevance with -labels clicks: cascade, dbn or pbm")
+	minImpressions := flag.Int("minImpressions", 10, "Minimum number of impressions of a document for a query to use its click estimate")
+	iterations := flag.Int("iterations", 50, "EM iterations of the dbn and pbm models")
+	gamma := flag.Float64("gamma", 0.9, "Probability of continuing to the next document in the dbn model")
+	csvFile := flag.String("csvFile", "", "Path to the file in which to save the examples as CSV (e.g., data/processed/rpi/train.csv)")
+	gobFile := flag.String("gobFile", "", "Path to the file in which to save the examples as gob (e.g., data/processed/rpi/train.gob)")
+	rankLibFile := flag.String("rankLibFile", "", "Path to the file in which to save the judged documents as listwise SVMlight lines for RankLib, with -labels qrels (e.g., data/processed/rpi/train.txt)")
+	exampleCount := flag.Int("exampleCount", 1000000, "Maximum number of examples to save")
+	minDiff := flag.Float64("minDiff", 0.1, "Minimum difference of relevance for a valid example")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *csvFile == "" && *gobFile == "" && *rankLibFile == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var judgments capture.Labels
+	var key capture.QueryKey
+	switch *labels {
+	case "qrels":
+		if *qrelsFile == "" {
+			log.Fatalf("Error: -qrels is required with -labels qrels")
+		}
+		file, err := os.Open(*qrelsFile)
+		if err != nil {
+			log.Fatalf("Error opening qrels: %v", err)
+		}
+		qrels, err := trec.ReadQrels(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Error reading qrels: %v", err)
+		}
+		judgments = capture.QrelsLabels(qrels)
+
+		switch *matchBy {
+		case "id":
+			key = capture.ByQueryID
+		case "text":
+			key = capture.ByQueryText
+		default:
+			log.Fatalf("Error: -matchBy must be id or text, got %q", *matchBy)
+		}
+	case "clicks":
+		clickModel, err := clickmodel.New(*model, *gamma, *iterations)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		sessions, err := clickmodel.LoadSessions(*feedbackDir, true)
+		if err != nil {
+			log.Fatalf("Error loading sessions: %v", err)
+		}
+		clickModel.Fit(sessions)
+		judgments = capture.ClickLabels(clickmodel.Estimates(clickModel, sessions, *minImpressions))
+
+		// Click estimates are per normalized query text
+		key = capture.ByQueryText
+	default:
+		log.Fatalf("Error: -labels must be qrels or clicks, got %q", *labels)
+	}
+	if *rankLibFile != "" && *labels != "qrels" {
+		log.Fatalf("Error: -rankLibFile needs the relevance grades of -labels qrels")
+	}
+
+	judged, err := capture.Join(*captureDir, judgments, key)
+	if err != nil {
+		log.Fatalf("Error reading captures: %v", err)
+	}
+	log.Printf("%d labelled queries, %d judged captured documents\n", len(judgments), len(judged))
+
+	// RankLib files are listwise, so they hold the judged documents rather than pairs
+	if *rankLibFile != "" {
+		if err := datagen.SaveQueryGroups(*rankLibFile, features.RankingSchema(), capture.QueryGroups(judged)); err != nil {
+			log.Fatalf("Error saving documents: %v", err)
+		}
+	}
+	if *csvFile == "" && *gobFile == "" {
+		return
+	}
+
+	dataset := capture.Examples(judged, *exampleCount, *minDiff)
+	log.Printf("%d examples\n", len(dataset.Y))
+
+	if *gobFile != "" {
+		if err := datagen.SaveDataset(*gobFile, dataset); err != nil {
+			log.Fatalf("Error saving examples: %v", err)
+		}
+	} else if *csvFile != "" {
+		if err := datagen.SaveDataToCSV(*csvFile, dataset.Schema, dataset.X, dataset.Y); err != nil {
+			log.Fatalf("Error saving examples: %v", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/capture"
+	"rpi-search-ranking/internal/clickmodel"
+	"rpi-search-ranking/internal/datagen"
+)
+
+// Create a pairwise comparison dataset from logged clicks and the features of captured rankings, in the same
+// format as cmd/datagen
+func main() {
+	feedbackDir := flag.String("feedbackDir", "./data/feedback", "Directory of the impression and feedback event log written by the API")
+	captureDir := flag.String("captureDir", "./data/captures", "Directory of the ranking captures written by the API, which hold the features of the shown documents")
+	model := flag.String("model", "dbn", "Click model used to estimate relevance: cascade, dbn or pbm")
+	csvFile := flag.String("csvFile", "", "Path to the file in which to save the examples as CSV (e.g., data/processed/clicks/train.csv)")
+	gobFile := flag.String("gobFile", "", "Path to the file in which to save the examples as gob (e.g., data/processed/clicks/train.gob)")
+	exampleCount := flag.Int("exampleCount", 1000000, "Maximum number of examples to save")
+	minDiff := flag.Float64("minDiff", 0.1, "Minimum difference of estimated relevance for a valid example")
+	minImpressions := flag.Int("minImpressions", 10, "Minimum number of impressions of a document for a query to use its estimate")
+	iterations := flag.Int("iterations", 50, "EM iterations of the dbn and pbm models")
+	gamma := flag.Float64("gamma", 0.9, "Probability of continuing to the next document in the dbn model")
+	requireFeedback := flag.Bool("requireFeedback", true, "Skip impressions without any feedback, since their clicks were never reported")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *csvFile == "" && *gobFile == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	clickModel, err := clickmodel.New(*model, *gamma, *iterations)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	sessions, err := clickmodel.LoadSessions(*feedbackDir, *requireFeedback)
+	if err != nil {
+		log.Fatalf("Error loading sessions: %v", err)
+	}
+	clickModel.Fit(sessions)
+
+	estimates := clickmodel.Estimates(clickModel, sessions, *minImpressions)
+
+	// Impressions only log document IDs, so the features come from the most recent capture of each document
+	judged, err := capture.Join(*captureDir, capture.ClickLabels(estimates), capture.ByQueryText)
+	if err != nil {
+		log.Fatalf("Error reading captures: %v", err)
+	}
+	dataset := capture.Examples(judged, *exampleCount, *minDiff)
+	log.Printf("%d sessions, %d query-document estimates, %d captured, %d examples\n", len(sessions), len(estimates), len(judged), len(dataset.Y))
+
+	if *gobFile != "" {
+		if err := datagen.SaveDataset(*gobFile, dataset); err != nil {
+			log.Fatalf("Error saving examples: %v", err)
+		}
+	} else if *csvFile != "" {
+		if err := datagen.SaveDataToCSV(*csvFile, dataset.Schema, dataset.X, dataset.Y); err != nil {
+			log.Fatalf("Error saving examples: %v", err)
+		}
+	}
+}
@@ -1,25 +1,32 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
 	"rpi-search-ranking/internal/datagen"
 )
 
 // Create microsoft pairwise comparison dataset
 // Designed to use data from https://www.microsoft.com/en-us/research/project/mslr/ by Tao Qin and Tie-Yan Liu
+// LETOR 4.0, Yahoo! LTR and SVMlight files are read with -format
 func main() {
 	file := flag.String("file", "", "Path to the train dataset file (e.g., MSLR-WEB30K/Fold1/train.txt)")
 	csvFile := flag.String("csvFile", "", "Path to the file in which to save the file as CSV (e.g., data/processed/MSLR-WEB30K/Fold1/1mil-train.csv)")
 	gobFile := flag.String("gobFile", "", "Path to the file in which to save the file as gob (e.g., data/processed/MSLR-WEB30K/Fold1/1mil-train.gob)")
+	svmlightFile := flag.String("svmlightFile", "", "Path to the file in which to save the examples as SVMlight classification lines (e.g., data/processed/MSLR-WEB30K/Fold1/1mil-train.svm)")
+	format := flag.String("format", "mslr", "Format of the dataset file: mslr, letor (LETOR 4.0), yahoo (Yahoo! LTR) or svmlight")
 	exampleCount := flag.Int("exampleCount", 1000000, "Number of examples to save")
 	minDiff := flag.Int("minDiff", 3, "Minimum relevance difference for a valid example")
+	workers := flag.Int("workers", 0, "Goroutines parsing the dataset file (0 uses every CPU)")
+	featureSet := flag.String("features", "ranking", "Features to keep: ranking (the MSLR features computed by the ranker), all, or IDs and ranges (e.g., 1-5,110,126-130)")
 
 	flag.Parse()
 
 	// Ensure required file paths are provided
-	if *file == "" || (*csvFile == "" && *gobFile == "") {
+	if *file == "" || (*csvFile == "" && *gobFile == "" && *svmlightFile == "") {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -29,18 +36,43 @@ func main() {
 		log.Fatal("Error: Minimum relevance difference must be between 1 and 4")
 	}
 
-	X, Y, err := datagen.CreateExamples(*file, *exampleCount, *minDiff)
+	datasetFormat, err := datagen.ParseFormat(*format)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	schema, err := datasetFormat.Schema(*featureSet)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Stop reading the dataset on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	options := datagen.StreamOptions{
+		Format:  datasetFormat,
+		Workers: *workers,
+		Progress: func(p datagen.Progress) {
+			log.Printf("Read %d lines, %d queries (%.1f%%)", p.Lines, p.Queries, 100*p.Fraction())
+		},
+	}
+	dataset, err := datagen.CreateExamples(ctx, *file, schema, *exampleCount, *minDiff, options)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	if *gobFile != "" {
-		err = datagen.SaveData(*gobFile, X, Y)
+		err = datagen.SaveDataset(*gobFile, dataset)
 		if err != nil {
 			return
 		}
 	} else if *csvFile != "" {
-		err = datagen.SaveDataToCSV(*csvFile, X, Y)
+		err = datagen.SaveDataToCSV(*csvFile, schema, dataset.X, dataset.Y)
+		if err != nil {
+			return
+		}
+	} else if *svmlightFile != "" {
+		err = datagen.SaveDataToSVMlight(*svmlightFile, schema, dataset.X, dataset.Y)
 		if err != nil {
 			return
 		}
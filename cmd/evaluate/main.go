@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/datagen"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/metrics"
+	"rpi-search-ranking/internal/training"
+	"strconv"
+)
+
+// Rank every query of an MSLR dataset and report listwise metrics
+// Designed to use data from https://www.microsoft.com/en-us/research/project/mslr/ by Tao Qin and Tie-Yan Liu
+// LETOR 4.0, Yahoo! LTR and SVMlight files are read with -format
+func main() {
+	file := flag.String("file", "", "Path to the dataset file (e.g., MSLR-WEB30K/Fold1/test.txt)")
+	format := flag.String("format", "mslr", "Format of the dataset file: mslr, letor (LETOR 4.0), yahoo (Yahoo! LTR) or svmlight")
+	model := flag.String("model", "bm25", "Model used to rank each query: bm25, or learned for the model of -modelFile")
+	modelFile := flag.String("modelFile", "", "Path to the model saved by regressiontrain, ranksvmtrain or mlptrain, required for the learned model")
+	k := flag.Int("k", 10, "Cutoff for NDCG, ERR and precision (0 evaluates whole lists)")
+	threshold := flag.Int("threshold", metrics.DefaultRelevanceThreshold, "Minimum relevance grade counted as relevant by MAP, MRR and precision")
+	workers := flag.Int("workers", 0, "Goroutines parsing the dataset file (0 uses every CPU)")
+	perQuery := flag.Bool("perQuery", false, "Print the metrics of every query before the mean")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *file == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	datasetFormat, err := datagen.ParseFormat(*format)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var vectorModel training.VectorModel
+	schema := features.RankingSchema()
+	switch *model {
+	case "bm25":
+		// Only MSLR numbers the BM25 feature like the ranker
+		if datasetFormat.Name != datagen.MSLR.Name {
+			log.Fatalf("Error: -model bm25 needs the BM25 feature of mslr files, train a model for %s files", datasetFormat.Name)
+		}
+	case "learned":
+		if *modelFile == "" {
+			log.Fatal("Error: -modelFile is required for the learned model")
+		}
+		learned, err := training.LoadModel(*modelFile)
+		if err != nil {
+			log.Fatalf("Error loading model: %v", err)
+		}
+		vectorModel, schema = learned, learned.Schema()
+	default:
+		log.Fatalf("Error: unknown model %q", *model)
+	}
+
+	if *perQuery {
+		fmt.Printf("qid\tndcg@%d\tap\trr\terr@%d\tp@%d\n", *k, *k, *k)
+	}
+
+	// Rank one query at a time so memory does not grow with the dataset
+	var results []metrics.QueryMetrics
+	options := datagen.StreamOptions{Format: datasetFormat, Workers: *workers}
+	err = datagen.StreamQueryGroups(context.Background(), *file, schema, options, func(group datagen.QueryGroup) error {
+		result := metrics.Evaluate(rankGroup(group, schema, vectorModel), *k, *threshold)
+		results = append(results, result)
+		if *perQuery {
+			printMetrics(strconv.Itoa(group.QID), result)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error loading dataset: %v", err)
+	}
+
+	fmt.Printf("Model: %s, Queries: %d\n", *model, len(results))
+	mean := metrics.Mean(results)
+	fmt.Printf("NDCG@%d: %.4f\n", *k, mean.NDCG)
+	fmt.Printf("MAP: %.4f\n", mean.AP)
+	fmt.Printf("MRR: %.4f\n", mean.RR)
+	fmt.Printf("ERR@%d: %.4f\n", *k, mean.ERR)
+	fmt.Printf("P@%d: %.4f\n", *k, mean.Precision)
+}
+
+// rankGroup orders the documents of a query with the model and returns their relevances in rank order
+func rankGroup(group datagen.QueryGroup, schema features.Schema, model training.VectorModel) []int {
+	order := training.Order(group.Features, schema, model)
+	relevances := make([]int, len(order))
+	for i, index := range order {
+		relevances[i] = group.Relevances[index]
+	}
+	return relevances
+}
+
+func printMetrics(label string, m metrics.QueryMetrics) {
+	fmt.Printf("%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\n", label, m.NDCG, m.AP, m.RR, m.ERR, m.Precision)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"rpi-search-ranking/internal/interleaving"
+)
+
+// Report the outcome of interleaving experiments from the feedback log written by the API
+func main() {
+	feedbackDir := flag.String("feedbackDir", "./data/feedback", "Directory of the impression and feedback event log written by the API")
+	flag.Parse()
+
+	results, err := interleaving.Summarize(*feedbackDir)
+	if err != nil {
+		log.Fatalf("Error reading feedback log: %v", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No interleaved impressions found")
+		return
+	}
+
+	fmt.Printf("%-24s %11s %7s %7s %7s %9s %17s\n", "experiment", "impressions", "winsA", "winsB", "ties", "winRateB", "95% CI")
+	for _, result := range results {
+		fmt.Printf("%-24s %11d %7d %7d %7d %9.4f   [%.4f, %.4f]\n", result.Experiment, result.Impressions,
+			result.WinsA, result.WinsB, result.Ties, result.WinRateB, result.Lower, result.Upper)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/training"
+	"strconv"
+	"strings"
+)
+
+// Train an MLP on the same pairwise datasets as regressiontrain, saving a model file that evaluate, trecrun and
+// the API load
+func main() {
+	trainFile := flag.String("trainFile", "", "Path to the train dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/train.gob)")
+	testFile := flag.String("testFile", "", "Path to the test dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/test.gob)")
+	modelFile := flag.String("modelFile", "", "Optional path in which to save the trained model (e.g., data/models/mlp.gob)")
+	hidden := flag.String("hidden", "128,64", "Comma-separated sizes of the hidden layers")
+	dropout := flag.Float64("dropout", 0.2, "Probability of dropping each hidden unit during training")
+	learningRate := flag.Float64("learningRate", 0.001, "Learning rate of AdamW")
+	weightDecay := flag.Float64("weightDecay", 2e-5, "Decoupled weight decay of AdamW")
+	batchSize := flag.Int("batchSize", 256, "Examples per update")
+	epochs := flag.Int("epochs", 50, "Maximum number of epochs")
+	validationFraction := flag.Float64("validationFraction", 0.1, "Share of the training examples, by query, held out for early stopping (0 trains all epochs)")
+	patience := flag.Int("patience", 5, "Epochs without improvement of the validation loss before stopping")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *trainFile == "" || *testFile == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	hiddenSizes, err := parseSizes(*hidden)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Load train and test data back
+	train, test, err := training.LoadTrainTest(*trainFile, *testFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Hold out queries to stop when the validation loss stops improving
+	options := training.MLPOptions{
+		Epochs:       *epochs,
+		BatchSize:    *batchSize,
+		LearningRate: *learningRate,
+		WeightDecay:  *weightDecay,
+		Dropout:      *dropout,
+	}
+	if *validationFraction > 0 && train.QIDs == nil {
+		log.Printf("Warning: %s has no query IDs, so pairs of a query may be split between training and validation; regenerate it to group them", *trainFile)
+	}
+	XTrain, YTrain, stopping, err := training.HoldOut(train, *validationFraction, *patience)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	options.EarlyStopping = stopping
+	mlp := training.NewMLP(hiddenSizes, train.Schema)
+	if err := mlp.Train(XTrain, YTrain, options); err != nil {
+		log.Fatal(err)
+	}
+
+	// Save the model for use by the ranking API
+	if *modelFile != "" {
+		if err := mlp.Save(*modelFile); err != nil {
+			log.Fatalf("Error saving model: %v", err)
+		}
+	}
+
+	// Evaluate on the test data
+	training.Confusion(mlp, test.X, test.Y).Print(os.Stdout)
+}
+
+// parseSizes parses a comma-separated list of hidden layer sizes
+func parseSizes(value string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(value, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hidden layer size %q: %v", field, err)
+		}
+		if size < 1 {
+			return nil, fmt.Errorf("hidden layer size must be positive, got %d", size)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
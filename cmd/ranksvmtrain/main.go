@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/training"
+	"strconv"
+	"strings"
+)
+
+// Train a RankSVM on the same pairwise datasets as regressiontrain, saving a model file that evaluate, trecrun and
+// the API load
+func main() {
+	trainFile := flag.String("trainFile", "", "Path to the train dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/train.gob)")
+	testFile := flag.String("testFile", "", "Path to the test dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/test.gob)")
+	modelFile := flag.String("modelFile", "", "Optional path in which to save the trained model (e.g., data/models/ranksvm.gob)")
+	lambdas := flag.String("lambdas", "0.00001,0.0001,0.001,0.01", "Comma-separated L2 strengths searched by cross-validation")
+	folds := flag.Int("folds", 5, "Number of query-grouped cross-validation folds used to choose lambda")
+	validationFraction := flag.Float64("validationFraction", 0.1, "Share of the training examples, by query, held out for early stopping of the final model (0 trains all epochs)")
+	patience := flag.Int("patience", 3, "Epochs without improvement of the validation hinge loss before stopping")
+	epochs := flag.Int("epochs", 20, "Maximum number of epochs of the final model")
+	cvEpochs := flag.Int("cvEpochs", 5, "Number of epochs of each cross-validation model")
+	batchSize := flag.Int("batchSize", 1, "Examples per Pegasos step")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *trainFile == "" || *testFile == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	lambdaValues, err := parseLambdas(*lambdas)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Load train and test data back
+	train, test, err := training.LoadTrainTest(*trainFile, *testFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if train.QIDs == nil {
+		log.Printf("Warning: %s has no query IDs, so pairs of a query may be split across folds; regenerate it to group them", *trainFile)
+	}
+
+	// Perform Grid Search CV to find the best lambda
+	options := training.RankSVMOptions{Epochs: *cvEpochs, BatchSize: *batchSize}
+	bestLambda, bestAcc := training.RankSVMGridSearchCV(train.Schema, train.X, train.Y, train.QIDs, lambdaValues, *folds, options)
+	fmt.Printf("Best Lambda: %.5f, Best Cross-Validation Accuracy: %.2f%%\n", bestLambda, bestAcc)
+
+	// Train the final model with the best lambda, holding out queries to stop when the validation loss stops improving
+	options.Epochs = *epochs
+	XTrain, YTrain, stopping, err := training.HoldOut(train, *validationFraction, *patience)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	options.EarlyStopping = stopping
+	svm := training.NewRankSVM(bestLambda, train.Schema)
+	if err := svm.Train(XTrain, YTrain, options); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(svm.Weights)
+
+	// Save the model for use by the ranking API
+	if *modelFile != "" {
+		if err := svm.Save(*modelFile); err != nil {
+			log.Fatalf("Error saving model: %v", err)
+		}
+	}
+
+	// Evaluate on the test data
+	training.Confusion(svm, test.X, test.Y).Print(os.Stdout)
+}
+
+// parseLambdas parses a comma-separated list of positive L2 strengths
+func parseLambdas(value string) ([]float64, error) {
+	var lambdas []float64
+	for _, field := range strings.Split(value, ",") {
+		lambda, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lambda %q: %v", field, err)
+		}
+		if lambda <= 0 {
+			return nil, fmt.Errorf("lambda must be positive, got %v", lambda)
+		}
+		lambdas = append(lambdas, lambda)
+	}
+	return lambdas, nil
+}
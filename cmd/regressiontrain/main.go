@@ -5,9 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"rpi-search-ranking/internal/datagen"
-	"rpi-search-ranking/internal/ranking"
 	"rpi-search-ranking/internal/training"
+	"runtime"
 )
 
 // Current output:
@@ -25,6 +24,21 @@ import (
 func main() {
 	trainFile := flag.String("trainFile", "", "Path to the train dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/train.gob)")
 	testFile := flag.String("testFile", "", "Path to the test dataset file (e.g., data/processed/MSLR-WEB30K/Fold1/test.gob)")
+	modelFile := flag.String("modelFile", "", "Optional path in which to save the trained model (e.g., data/models/logistic.gob)")
+	folds := flag.Int("folds", 5, "Number of query-grouped cross-validation folds used to choose lambda")
+	validationFraction := flag.Float64("validationFraction", 0.1, "Share of the training examples, by query, held out for early stopping of the final model (0 trains all epochs)")
+	patience := flag.Int("patience", 5, "Epochs without improvement of the validation loss before stopping")
+	epochs := flag.Int("epochs", 1000, "Maximum number of epochs of the final model")
+	cvEpochs := flag.Int("cvEpochs", 100, "Number of epochs of each cross-validation model")
+	optimizerName := flag.String("optimizer", "sgd", "Optimizer: sgd, momentum or adam")
+	momentum := flag.Float64("momentum", 0.9, "Decay of past gradients of the momentum optimizer")
+	batchSize := flag.Int("batchSize", 0, "Examples per update (0 for full-batch gradient descent)")
+	learningRate := flag.Float64("learningRate", 0.02, "Initial learning rate")
+	scheduleName := flag.String("schedule", "constant", "Learning rate schedule: constant, step, exponential or cosine")
+	decay := flag.Float64("decay", 0.5, "Factor applied to the learning rate by the step and exponential schedules")
+	decayEvery := flag.Int("decayEvery", 10, "Epochs between decays of the step schedule")
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "Goroutines computing the gradient of large batches")
+	clipNorm := flag.Float64("clipNorm", 0, "Maximum L2 norm of a batch gradient (0 disables clipping)")
 	flag.Parse()
 
 	// Ensure required file paths are provided
@@ -34,59 +48,60 @@ func main() {
 	}
 
 	// Load train and test data back
-	var XTrain, XTest []ranking.Features
-	var YTrain, YTest []int
-	if err := datagen.LoadData(*trainFile, &XTrain, &YTrain); err != nil {
-		log.Fatalf("Error loading train data: %v", err)
+	train, test, err := training.LoadTrainTest(*trainFile, *testFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if train.QIDs == nil {
+		log.Printf("Warning: %s has no query IDs, so pairs of a query may be split across folds; regenerate it to group them", *trainFile)
+	}
+
+	optimizer, err := training.NewOptimizer(*optimizerName, *momentum)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	options := training.TrainOptions{
+		Epochs:    *cvEpochs,
+		BatchSize: *batchSize,
+		Optimizer: optimizer,
+		ClipNorm:  *clipNorm,
+		Workers:   *workers,
 	}
-	if err := datagen.LoadData(*testFile, &XTest, &YTest); err != nil {
-		log.Fatalf("Error loading test data: %v", err)
+	if options.Schedule, err = training.ParseSchedule(*scheduleName, *learningRate, *decay, *decayEvery, *cvEpochs); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
 	// Define lambda values to search through
 	lambdaValues := []float64{1.0, 1.25, 1.5, 1.75, 2.0, 2.25}
 
 	// Perform Grid Search CV to find the best lambda
-	bestLambda, bestAcc := training.GridSearchCV(XTrain, YTrain, lambdaValues, 5)
+	bestLambda, bestAcc := training.GridSearchCV(train.Schema, train.X, train.Y, train.QIDs, lambdaValues, *folds, options)
 	fmt.Printf("Best Lambda: %.4f, Best Cross-Validation Accuracy: %.2f%%\n", bestLambda, bestAcc)
 
-	// Train the final model with the best lambda on the full training set
-	lr := training.NewLogisticRegression(bestLambda)
-	err := lr.Train(XTrain, YTrain, 0.02, 1000)
+	// Train the final model with the best lambda, holding out queries to stop when the validation loss stops improving
+	options.Epochs = *epochs
+	if options.Schedule, err = training.ParseSchedule(*scheduleName, *learningRate, *decay, *decayEvery, *epochs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	XTrain, YTrain, stopping, err := training.HoldOut(train, *validationFraction, *patience)
 	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	options.EarlyStopping = stopping
+	lr := training.NewLogisticRegression(bestLambda, train.Schema)
+	if err := lr.Train(XTrain, YTrain, options); err != nil {
 		log.Fatal(err)
 	}
 
 	fmt.Println(lr.Weights)
 
-	// Confusion matrix variables
-	TP, FP, TN, FN := 0, 0, 0, 0
-
-	// Make predictions and evaluate on the test data
-	for i, features := range XTest {
-		class := lr.PredictClass(features)
-		actual := YTest[i]
-
-		// Update confusion matrix based on the prediction and actual class
-		if class == 1 && actual == 1 {
-			TP++ // True Positive
-		} else if class == 1 && actual == -1 {
-			FP++ // False Positive
-		} else if class == -1 && actual == -1 {
-			TN++ // True Negative
-		} else if class == -1 && actual == 1 {
-			FN++ // False Negative
+	// Save the model for use by the ranking API
+	if *modelFile != "" {
+		if err := lr.Save(*modelFile); err != nil {
+			log.Fatalf("Error saving model: %v", err)
 		}
 	}
 
-	// Calculate accuracy
-	accuracy := float64(TP+TN) / float64(TP+FP+TN+FN) * 100
-	fmt.Printf("Test Accuracy: %.2f%%\n", accuracy)
-
-	// Print Confusion Matrix
-	fmt.Printf("Confusion Matrix:\n")
-	fmt.Printf("              Predicted\n")
-	fmt.Printf("              1     -1\n")
-	fmt.Printf("Actual  1    %d    %d\n", TP, FN)
-	fmt.Printf("        -1   %d    %d\n", FP, TN)
+	// Evaluate on the test data
+	training.Confusion(lr, test.X, test.Y).Print(os.Stdout)
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/training"
+	"rpi-search-ranking/internal/trec"
+	"time"
+)
+
+// Rank TREC topics into a run file and evaluate runs against qrels like trec_eval
+func main() {
+	topicsFile := flag.String("topics", "", "Path to a topics file in TREC format or with one \"id<TAB>text\" topic per line")
+	runFile := flag.String("run", "", "Path of the run file written for -topics (stdout if empty), or the run evaluated without -topics")
+	qrelsFile := flag.String("qrels", "", "Optional path to qrels used to evaluate the run, printing trec_eval measures")
+	tag := flag.String("tag", "", "Run tag written in the last column (defaults to the model name)")
+	model := flag.String("model", "bm25", "Model used to rank each topic: bm25, or learned for the model of -modelFile")
+	modelFile := flag.String("modelFile", "", "Path to the model saved by regressiontrain, ranksvmtrain or mlptrain, required for the learned model")
+	depth := flag.Int("depth", 1000, "Maximum number of documents retrieved per topic")
+	indexURL := flag.String("indexURL", ranking.DefaultIndexURL, "Base URL of the index service backend")
+	linkURL := flag.String("linkURL", ranking.DefaultLinkAnalysisURL, "Base URL of the link analysis service backend")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of each request to the backend")
+	perQuery := flag.Bool("q", false, "Print measures for every query before the summary, like trec_eval -q")
+	complete := flag.Bool("c", false, "Evaluate judged queries missing from the run with no documents, like trec_eval -c")
+	flag.Parse()
+
+	// Ensure required file paths are provided
+	if *topicsFile == "" && (*runFile == "" || *qrelsFile == "") {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if *tag == "" {
+		*tag = *model
+	}
+
+	var run trec.Run
+	var failed []string
+	var err error
+	if *topicsFile != "" {
+		run, failed, err = rankTopics(*topicsFile, *runFile, *tag, *model, *modelFile, *depth,
+			ranking.Backend{IndexURL: *indexURL, LinkAnalysisURL: *linkURL}, *timeout)
+	} else {
+		run, err = readRun(*runFile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *qrelsFile != "" {
+		if err := evaluate(run, *qrelsFile, *tag, *complete, *perQuery); err != nil {
+			log.Fatalf("Error %v", err)
+		}
+	}
+
+	// Failed topics are missing from the run file, so report them instead of exiting successfully
+	if len(failed) > 0 {
+		log.Fatalf("Error: failed to rank %d of %d topics, which are missing from the run file: %v", len(failed), len(run), failed)
+	}
+}
+
+// evaluate prints trec_eval measures of the run against the qrels file
+func evaluate(run trec.Run, qrelsFile, tag string, complete, perQuery bool) error {
+	file, err := os.Open(qrelsFile)
+	if err != nil {
+		return fmt.Errorf("opening qrels: %v", err)
+	}
+	defer file.Close()
+	qrels, err := trec.ReadQrels(file)
+	if err != nil {
+		return fmt.Errorf("reading qrels: %v", err)
+	}
+
+	results, summary := trec.Evaluate(run, qrels, complete)
+	return trec.WriteResults(os.Stdout, tag, results, summary, perQuery)
+}
+
+// rankTopics ranks every topic against the backend and writes the run file. Topics that fail to rank are returned
+// in failed and have no entries in the run, so that evaluation counts them as retrieving no documents.
+func rankTopics(topicsFile, runFile, tag, model, modelFile string, depth int, backend ranking.Backend, timeout time.Duration) (run trec.Run, failed []string, err error) {
+	pairwiseModel, err := loadModel(model, modelFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	transport, err := backend.Transport(http.DefaultTransport)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{Timeout: timeout, Transport: transport}
+
+	file, err := os.Open(topicsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	topics, err := trec.ReadTopics(file)
+	file.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out io.Writer = os.Stdout
+	if runFile != "" {
+		file, err := os.Create(runFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	run = trec.Run{}
+	for _, topic := range topics {
+		result, err := ranking.RankDocumentsWithOptions(ranking.Query{Id: topic.ID, Text: topic.Title}, client, ranking.RankOptions{
+			Model: pairwiseModel,
+			TopK:  depth,
+		})
+		if err != nil {
+			// Keep going so one failing topic does not lose the whole run. The run file cannot list a topic without
+			// documents, but the returned run does, so it is evaluated with no documents even without -c.
+			log.Printf("warning: failed to rank topic %s: %v\n", topic.ID, err)
+			failed = append(failed, topic.ID)
+			run[topic.ID] = nil
+			continue
+		}
+
+		entries := make([]trec.RunEntry, len(result.Documents))
+		for i, doc := range result.Documents {
+			// Learned models score by pairwise wins, so make scores strictly follow the ranking for trec_eval
+			entries[i] = trec.RunEntry{DocID: doc.DocID, Rank: doc.Rank, Score: float64(len(result.Documents) - i)}
+			if pairwiseModel == nil {
+				entries[i].Score = doc.Score
+			}
+		}
+		if err := trec.WriteRun(writer, topic.ID, entries, tag); err != nil {
+			return nil, nil, err
+		}
+		run[topic.ID] = entries
+	}
+	return run, failed, nil
+}
+
+func loadModel(model, modelFile string) (ranking.PairwiseModel, error) {
+	switch model {
+	case "bm25":
+		return nil, nil
+	case "learned":
+		if modelFile == "" {
+			return nil, fmt.Errorf("-modelFile is required for the learned model")
+		}
+		return training.LoadModel(modelFile)
+	}
+	return nil, fmt.Errorf("unknown model %q", model)
+}
+
+func readRun(runFile string) (trec.Run, error) {
+	file, err := os.Open(runFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return trec.ReadRun(file)
+}
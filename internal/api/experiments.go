@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/utils"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Units that requests are hashed on to assign experiment buckets
+const (
+	UnitRequest = "request" // every request is assigned independently
+	UnitUser    = "user"    // requests with the same userID share a bucket
+)
+
+// Maximum number of shadow rankings running at once, further shadows are skipped
+const maxConcurrentShadows = 4
+
+// Timeout of a shadow ranking, which no longer has the deadline of its request
+const shadowTimeout = 30 * time.Second
+
+// RankerConfig selects how an experiment bucket or shadow ranks documents
+type RankerConfig struct {
+	Model            string             `json:"model,omitempty"`            // registered model name, defaults to "bm25"
+	BM25             ranking.BM25Params `json:"bm25,omitempty"`             // BM25 parameters, defaults to k1 = 1.2 and b = 0.75
+	DisabledFeatures []string           `json:"disabledFeatures,omitempty"` // Features fields hidden from the model
+}
+
+// Bucket is a share of the traffic ranked with its own config
+type Bucket struct {
+	Name   string       `json:"name"`
+	Weight int          `json:"weight"` // share of the traffic relative to the other buckets
+	Ranker RankerConfig `json:"ranker"`
+}
+
+// ShadowRanker ranks a fraction of the served requests again without serving the result
+type ShadowRanker struct {
+	Name     string       `json:"name"`
+	Fraction float64      `json:"fraction"` // share of served requests, between 0 and 1
+	Ranker   RankerConfig `json:"ranker"`
+}
+
+// ExperimentConfig is the format of the experiments file
+type ExperimentConfig struct {
+	Salt    string         `json:"salt"` // changing the salt reshuffles users between buckets
+	Unit    string         `json:"unit"` // "request" or "user"
+	Buckets []Bucket       `json:"buckets"`
+	Shadows []ShadowRanker `json:"shadows"`
+}
+
+var (
+	experimentConfig atomic.Pointer[ExperimentConfig]
+	shadowSemaphore  = make(chan struct{}, maxConcurrentShadows)
+)
+
+// LoadExperimentConfig reads experiment buckets and shadow rankers from a JSON file
+func LoadExperimentConfig(filename string) (ExperimentConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ExperimentConfig{}, fmt.Errorf("error reading experiment config: %v", err)
+	}
+	var config ExperimentConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ExperimentConfig{}, fmt.Errorf("error parsing experiment config: %v", err)
+	}
+	return config, nil
+}
+
+// SetExperimentConfig validates and activates experiment buckets and shadow rankers. Models must already be registered.
+func SetExperimentConfig(config ExperimentConfig) error {
+	if config.Unit != UnitRequest && config.Unit != UnitUser {
+		return fmt.Errorf("experiment unit must be %q or %q", UnitRequest, UnitUser)
+	}
+	names := make(map[string]bool)
+	for _, bucket := range config.Buckets {
+		if bucket.Name == "" || names[bucket.Name] {
+			return fmt.Errorf("bucket and shadow names must be unique and non-empty: %q", bucket.Name)
+		}
+		names[bucket.Name] = true
+		if bucket.Weight < 0 {
+			return fmt.Errorf("weight of bucket %s cannot be negative", bucket.Name)
+		}
+		if _, _, err := bucket.Ranker.resolve(); err != nil {
+			return fmt.Errorf("bucket %s: %v", bucket.Name, err)
+		}
+	}
+	for _, shadow := range config.Shadows {
+		if shadow.Name == "" || names[shadow.Name] {
+			return fmt.Errorf("bucket and shadow names must be unique and non-empty: %q", shadow.Name)
+		}
+		names[shadow.Name] = true
+		if shadow.Fraction < 0 || shadow.Fraction > 1 {
+			return fmt.Errorf("fraction of shadow %s must be between 0 and 1", shadow.Name)
+		}
+		if _, _, err := shadow.Ranker.resolve(); err != nil {
+			return fmt.Errorf("shadow %s: %v", shadow.Name, err)
+		}
+	}
+
+	experimentConfig.Store(&config)
+	return nil
+}
+
+// resolve returns the model of the config, with disabled features hidden from it
+func (c RankerConfig) resolve() (ranking.PairwiseModel, string, error) {
+	model, modelName, err := lookupModel(c.Model)
+	if err != nil {
+		return nil, "", err
+	}
+	model, err = ranking.MaskFeatures(model, c.DisabledFeatures)
+	if err != nil {
+		return nil, "", err
+	}
+	return model, modelName, nil
+}
+
+// assignBucket hashes the request into a bucket of the active experiment config
+func assignBucket(ctx context.Context, req RankRequest) (Bucket, bool) {
+	config := experimentConfig.Load()
+	if config == nil {
+		return Bucket{}, false
+	}
+	var totalWeight uint64
+	for _, bucket := range config.Buckets {
+		totalWeight += uint64(bucket.Weight)
+	}
+	if totalWeight == 0 {
+		return Bucket{}, false
+	}
+
+	// Requests without a user or request ID fall back to a random key
+	key := logging.RequestID(ctx)
+	if config.Unit == UnitUser && req.UserID != "" {
+		key = req.UserID
+	}
+	if key == "" {
+		key = uuid.New().String()
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(config.Salt + "/" + key))
+	slot := hash.Sum64() % totalWeight
+	for _, bucket := range config.Buckets {
+		if slot < uint64(bucket.Weight) {
+			return bucket, true
+		}
+		slot -= uint64(bucket.Weight)
+	}
+	return Bucket{}, false
+}
+
+// runShadows ranks the request with the sampled shadow rankers in the background and logs their rankings against the
+// served impression. Shadows are skipped when feedback logging is disabled or too many are running already.
+func runShadows(ctx context.Context, req RankRequest, impressionID string, eval *utils.Evaluation) {
+	config := experimentConfig.Load()
+	r := recorder.Load()
+	if config == nil || r == nil {
+		return
+	}
+
+	for _, shadow := range config.Shadows {
+		if rand.Float64() >= shadow.Fraction {
+			continue
+		}
+		select {
+		case shadowSemaphore <- struct{}{}:
+		default:
+			logger.DebugContext(ctx, "skipping shadow ranker, too many running", "shadow", shadow.Name)
+			continue
+		}
+		eval.ShadowRankers = append(eval.ShadowRankers, shadow.Name)
+
+		// The shadow outlives the request, so it keeps the request ID but not the cancellation
+		shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+		go func(shadow ShadowRanker) {
+			defer func() { <-shadowSemaphore }()
+			defer cancel()
+			rankShadow(shadowCtx, r, req, impressionID, shadow)
+		}(shadow)
+	}
+}
+
+func rankShadow(ctx context.Context, r *feedback.Recorder, req RankRequest, impressionID string, shadow ShadowRanker) {
+	model, modelName, err := shadow.Ranker.resolve()
+	if err != nil {
+		logger.WarnContext(ctx, "failed to resolve shadow ranker", "shadow", shadow.Name, "error", err)
+		return
+	}
+	result, err := ranking.RankDocumentsWithOptions(ranking.Query{Id: req.QueryID, Text: req.QueryText}, clientFor(ctx), ranking.RankOptions{
+		Model:     model,
+		TopK:      req.TopK,
+		Offset:    req.Offset,
+		Filter:    req.Filter,
+		BM25:      shadow.Ranker.BM25,
+		NoCapture: true,
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "shadow ranking failed", "shadow", shadow.Name, "error", err)
+		return
+	}
+
+	documents := make([]feedback.ImpressionDocument, len(result.Documents))
+	for i, doc := range result.Documents {
+		documents[i] = feedback.ImpressionDocument{DocID: doc.DocID, Position: doc.Rank, Score: doc.Score}
+	}
+	if err := r.RecordShadow(impressionID, shadow.Name, modelName, documents); err != nil {
+		logger.WarnContext(ctx, "failed to log shadow ranking", "shadow", shadow.Name, "error", err)
+	}
+}
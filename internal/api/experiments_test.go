@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/utils"
+	"testing"
+)
+
+// setExperimentConfig activates a config for the duration of a test
+func setExperimentConfig(t *testing.T, config ExperimentConfig) {
+	t.Helper()
+	if err := SetExperimentConfig(config); err != nil {
+		t.Fatalf("SetExperimentConfig() error = %v", err)
+	}
+	t.Cleanup(func() { experimentConfig.Store(nil) })
+}
+
+// waitForShadows blocks until all running shadow rankings have finished
+func waitForShadows() {
+	for range maxConcurrentShadows {
+		shadowSemaphore <- struct{}{}
+	}
+	for range maxConcurrentShadows {
+		<-shadowSemaphore
+	}
+}
+
+func TestSetExperimentConfig(t *testing.T) {
+	registerTestModel(t, "reverse", reverseBM25{})
+	t.Cleanup(func() { experimentConfig.Store(nil) })
+
+	tests := []struct {
+		name    string
+		config  ExperimentConfig
+		wantErr bool
+	}{
+		{"Valid", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}},
+			Shadows: []ShadowRanker{{Name: "shadow", Fraction: 0.5}}}, false},
+		{"Zero weights and fractions", ExperimentConfig{Unit: UnitRequest, Buckets: []Bucket{{Name: "control"}},
+			Shadows: []ShadowRanker{{Name: "shadow"}}}, false},
+		{"Full shadow fraction", ExperimentConfig{Unit: UnitRequest, Shadows: []ShadowRanker{{Name: "shadow", Fraction: 1}}}, false},
+		{"Unknown unit", ExperimentConfig{Unit: "session"}, true},
+		{"Unnamed bucket", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Weight: 1}}}, true},
+		{"Duplicate bucket", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1}, {Name: "control", Weight: 1}}}, true},
+		{"Bucket and shadow with the same name", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1}},
+			Shadows: []ShadowRanker{{Name: "control", Fraction: 0.5}}}, true},
+		{"Unnamed shadow", ExperimentConfig{Unit: UnitUser, Shadows: []ShadowRanker{{Fraction: 0.5}}}, true},
+		{"Negative weight", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: -1}}}, true},
+		{"Negative shadow fraction", ExperimentConfig{Unit: UnitUser, Shadows: []ShadowRanker{{Name: "shadow", Fraction: -0.1}}}, true},
+		{"Shadow fraction above 1", ExperimentConfig{Unit: UnitUser, Shadows: []ShadowRanker{{Name: "shadow", Fraction: 1.1}}}, true},
+		{"Unknown bucket model", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1, Ranker: RankerConfig{Model: "missing"}}}}, true},
+		{"Unknown shadow model", ExperimentConfig{Unit: UnitUser, Shadows: []ShadowRanker{{Name: "shadow", Ranker: RankerConfig{Model: "missing"}}}}, true},
+		{"Disabled feature", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1,
+			Ranker: RankerConfig{Model: "reverse", DisabledFeatures: []string{"BM25"}}}}}, false},
+		{"Unknown disabled feature", ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "control", Weight: 1,
+			Ranker: RankerConfig{Model: "reverse", DisabledFeatures: []string{"Missing"}}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			experimentConfig.Store(nil)
+			err := SetExperimentConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetExperimentConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// Only accepted configs are activated
+			if active := experimentConfig.Load(); (active != nil) == tt.wantErr {
+				t.Errorf("active config = %v after SetExperimentConfig() error = %v", active, err)
+			}
+		})
+	}
+}
+
+func TestAssignBucket(t *testing.T) {
+	buckets := []Bucket{{Name: "control", Weight: 3}, {Name: "treatment", Weight: 1}, {Name: "off", Weight: 0}}
+
+	// assign returns the bucket names of n keys, with the key as user ID or request ID depending on the unit
+	assign := func(t *testing.T, salt, unit string, n int, key func(i int) (userID, requestID string)) []string {
+		setExperimentConfig(t, ExperimentConfig{Salt: salt, Unit: unit, Buckets: buckets})
+		names := make([]string, n)
+		for i := range names {
+			userID, requestID := key(i)
+			bucket, ok := assignBucket(logging.WithRequestID(context.Background(), requestID), RankRequest{UserID: userID})
+			if !ok {
+				t.Fatalf("assignBucket() assigned no bucket to key %d", i)
+			}
+			names[i] = bucket.Name
+		}
+		return names
+	}
+	byUser := func(i int) (string, string) { return fmt.Sprintf("user%d", i), fmt.Sprintf("request%d", i) }
+	byRequest := func(i int) (string, string) { return "", fmt.Sprintf("request%d", i) }
+
+	tests := []struct {
+		name string
+		unit string
+		key  func(i int) (userID, requestID string)
+		same func(i int) (userID, requestID string) // keys that must be assigned the same buckets
+	}{
+		{"User unit ignores the request ID", UnitUser, byUser, func(i int) (string, string) { return fmt.Sprintf("user%d", i), "other" }},
+		{"User unit falls back to the request ID", UnitUser, byRequest, byRequest},
+		{"Request unit ignores the user ID", UnitRequest, byUser, func(i int) (string, string) { return "other", fmt.Sprintf("request%d", i) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 1000
+			got := assign(t, "salt", tt.unit, n, tt.key)
+			if same := assign(t, "salt", tt.unit, n, tt.same); fmt.Sprint(same) != fmt.Sprint(got) {
+				t.Errorf("assignBucket() is not deterministic per %s", tt.unit)
+			}
+
+			// Buckets get a share of the keys proportional to their weight, zero weight buckets none
+			counts := make(map[string]int)
+			changed := 0
+			for i, name := range assign(t, "other salt", tt.unit, n, tt.key) {
+				counts[got[i]]++
+				if name != got[i] {
+					changed++
+				}
+			}
+			if counts["off"] != 0 || counts["control"] < 650 || counts["control"] > 850 {
+				t.Errorf("assignBucket() bucket counts = %v, want about 750 control and 250 treatment", counts)
+			}
+			if changed == 0 {
+				t.Errorf("assignBucket() assigned the same buckets with a different salt")
+			}
+		})
+	}
+
+	// No buckets are assigned without a config or without weights
+	experimentConfig.Store(nil)
+	if _, ok := assignBucket(context.Background(), RankRequest{UserID: "user"}); ok {
+		t.Errorf("assignBucket() assigned a bucket without an experiment config")
+	}
+	setExperimentConfig(t, ExperimentConfig{Unit: UnitUser, Buckets: []Bucket{{Name: "off"}}})
+	if _, ok := assignBucket(context.Background(), RankRequest{UserID: "user"}); ok {
+		t.Errorf("assignBucket() assigned a bucket of zero weight")
+	}
+}
+
+func TestRank_experimentBucket(t *testing.T) {
+	useFakeUpstreams(t)
+	registerTestModel(t, "reverse", reverseBM25{})
+	dir := setFeedbackRecorder(t)
+	setExperimentConfig(t, ExperimentConfig{
+		Unit:    UnitUser,
+		Buckets: []Bucket{{Name: "treatment", Weight: 1, Ranker: RankerConfig{Model: "reverse"}}},
+		Shadows: []ShadowRanker{{Name: "bm25-shadow", Fraction: 1}},
+	})
+	// Shadows outlive their request and may still use the fake upstreams and the recorder
+	t.Cleanup(waitForShadows)
+
+	eval := utils.CreateEvaluation()
+	response, err := Rank(context.Background(), RankRequest{QueryID: "q1", QueryText: "rpi", UserID: "user"}, eval)
+	waitForShadows()
+	if err != nil {
+		t.Fatalf("Rank() error = %v", err)
+	}
+	if response.Model != "reverse" || response.Experiment != "treatment" || eval.Experiment != "treatment" {
+		t.Errorf("Rank() ranked with model %q in experiment %q, want the treatment bucket", response.Model, response.Experiment)
+	}
+	if want := []string{"doc3", "doc2", "doc1"}; !reflect.DeepEqual(docIDs(response.Results), want) {
+		t.Errorf("Rank() results = %v, want %v", docIDs(response.Results), want)
+	}
+
+	// The shadow ranks the served impression again without serving it
+	if !reflect.DeepEqual(eval.ShadowRankers, []string{"bm25-shadow"}) {
+		t.Errorf("evaluation shadow rankers = %v, want [bm25-shadow]", eval.ShadowRankers)
+	}
+	shadows := waitForEvents(t, dir, feedback.EventShadow, 1)
+	if len(shadows) != 1 || shadows[0].ImpressionID != response.ImpressionID || shadows[0].Shadow != "bm25-shadow" {
+		t.Fatalf("shadow events = %+v, want one bm25-shadow event of impression %s", shadows, response.ImpressionID)
+	}
+	if got := shadows[0].Documents; len(got) != 3 || got[0].DocID != "doc1" {
+		t.Errorf("shadow documents = %+v, want the BM25 ranking", got)
+	}
+
+	// Requests for a specific model are not part of the experiment
+	response, err = Rank(context.Background(), RankRequest{QueryID: "q1", QueryText: "rpi", UserID: "user", Model: DefaultModel}, utils.CreateEvaluation())
+	if err != nil || response.Experiment != "" {
+		t.Errorf("Rank() with a model = experiment %q, error %v, want no experiment", response.Experiment, err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rpi-search-ranking/internal/ranking"
+)
+
+// ErrDocumentNotRanked is returned by Explain when the document is not among the ranked documents
+var ErrDocumentNotRanked = errors.New("document not ranked for query")
+
+// ExplainResponse is the response of GET /v1/explain
+type ExplainResponse struct {
+	QueryText   string               `json:"queryText"`   // query text from the request
+	DocID       string               `json:"docID"`       // explained document
+	Model       string               `json:"model"`       // model used to order the results
+	Rank        int                  `json:"rank"`        // 1-based position of the document
+	Score       float64              `json:"score"`       // BM25 score, or pairwise wins for learned models
+	TotalRanked int                  `json:"totalRanked"` // documents ranked for the query
+	Explanation *ranking.Explanation `json:"explanation"` // features and score breakdown
+}
+
+// Explain ranks the query and explains the rank of a single document
+func Explain(ctx context.Context, queryText, docID, modelName string) (ExplainResponse, error) {
+	if queryText == "" || docID == "" {
+		return ExplainResponse{}, fmt.Errorf("%w: text and docID are required", ErrInvalidRequest)
+	}
+	if err := validateQueryText(queryText); err != nil {
+		return ExplainResponse{}, err
+	}
+	model, modelName, err := lookupModel(modelName)
+	if err != nil {
+		return ExplainResponse{}, err
+	}
+
+	result, err := ranking.RankDocumentsWithOptions(ranking.Query{Id: "explain", Text: queryText}, clientFor(ctx), ranking.RankOptions{
+		Model:     model,
+		Explain:   true,
+		NoCapture: true,
+	})
+	if err != nil {
+		return ExplainResponse{}, err
+	}
+	recordRankMetrics(modelName, model, result)
+
+	for _, doc := range result.Documents {
+		if doc.DocID == docID {
+			logger.InfoContext(ctx, "explained document", "docID", docID, "queryText", queryText, "model", modelName)
+			return ExplainResponse{
+				QueryText:   queryText,
+				DocID:       docID,
+				Model:       modelName,
+				Rank:        doc.Rank,
+				Score:       doc.Score,
+				TotalRanked: result.TotalRanked,
+				Explanation: doc.Explanation,
+			}, nil
+		}
+	}
+
+	return ExplainResponse{}, fmt.Errorf("%w: %s", ErrDocumentNotRanked, docID)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/ranking"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// ImpressionIDHeader carries the impression ID of responses that are not JSON objects
+const ImpressionIDHeader = "X-Impression-ID"
+
+// ErrFeedbackDisabled is returned by Feedback when no feedback recorder is configured
+var ErrFeedbackDisabled = errors.New("feedback logging is disabled")
+
+// recorder logs impressions and feedback, nil disables logging
+var recorder atomic.Pointer[feedback.Recorder]
+
+// SetFeedbackRecorder enables impression and feedback logging
+func SetFeedbackRecorder(r *feedback.Recorder) {
+	recorder.Store(r)
+}
+
+// FeedbackRequest is the body of POST /v1/feedback
+type FeedbackRequest struct {
+	ImpressionID string                 `json:"impressionID"` // impression ID of the ranking response
+	Interactions []feedback.Interaction `json:"interactions"` // clicks, skips and dwell times on its documents
+}
+
+// RecordImpression assigns an impression ID to the documents returned for a query and logs them if enabled
+func RecordImpression(ctx context.Context, queryID, queryText, modelName string, docs []ranking.Document) string {
+	return recordImpression(ctx, feedback.Impression{QueryID: queryID, QueryText: queryText, Model: modelName}, docs, nil)
+}
+
+// recordImpression assigns an impression ID and logs the documents, with the team of each document in interleaving experiments
+func recordImpression(ctx context.Context, impression feedback.Impression, docs []ranking.Document, teams []string) string {
+	impression.ImpressionID = uuid.New().String()
+	r := recorder.Load()
+	if r == nil {
+		return impression.ImpressionID
+	}
+
+	impression.Documents = make([]feedback.ImpressionDocument, len(docs))
+	for i, doc := range docs {
+		impression.Documents[i] = feedback.ImpressionDocument{DocID: doc.DocID, Position: doc.Rank, Score: doc.Score}
+		if teams != nil {
+			impression.Documents[i].Team = teams[i]
+		}
+	}
+	if err := r.RecordImpression(impression); err != nil {
+		logger.WarnContext(ctx, "failed to log impression", "impressionID", impression.ImpressionID, "error", err)
+	}
+	return impression.ImpressionID
+}
+
+// Feedback records user interactions with the documents of an impression
+func Feedback(ctx context.Context, req FeedbackRequest) error {
+	r := recorder.Load()
+	if r == nil {
+		return ErrFeedbackDisabled
+	}
+
+	err := r.RecordFeedback(req.ImpressionID, req.Interactions)
+	if errors.Is(err, feedback.ErrInvalidFeedback) {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.InfoContext(ctx, "recorded feedback", "impressionID", req.ImpressionID, "interactions", len(req.Interactions))
+	return nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/ranking"
+	"testing"
+	"time"
+)
+
+// setFeedbackRecorder enables feedback logging for the duration of a test and returns the directory of the log
+func setFeedbackRecorder(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	r, err := feedback.NewRecorder(feedback.Config{Dir: dir, MaxRecent: 10, QueueSize: 100})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	SetFeedbackRecorder(r)
+	t.Cleanup(func() {
+		SetFeedbackRecorder(nil)
+		r.Close()
+	})
+	return dir
+}
+
+// waitForEvents returns the logged events of a type once at least n are written in the background
+func waitForEvents(t *testing.T, dir, eventType string, n int) []feedback.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var events []feedback.Event
+		err := feedback.ReadEvents(dir, func(event feedback.Event) error {
+			if event.Type == eventType {
+				events = append(events, event)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ReadEvents() error = %v", err)
+		}
+		if len(events) >= n || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFeedback(t *testing.T) {
+	ctx := context.Background()
+	click := []feedback.Interaction{{DocID: "doc1", Action: feedback.ActionClick}}
+
+	SetFeedbackRecorder(nil)
+	if err := Feedback(ctx, FeedbackRequest{ImpressionID: "imp", Interactions: click}); !errors.Is(err, ErrFeedbackDisabled) {
+		t.Errorf("Feedback() without a recorder error = %v, want %v", err, ErrFeedbackDisabled)
+	}
+
+	dir := setFeedbackRecorder(t)
+	impressionID := RecordImpression(ctx, "q1", "rpi", DefaultModel, []ranking.Document{{DocID: "doc1", Rank: 1}, {DocID: "doc2", Rank: 2}})
+
+	tests := []struct {
+		name    string
+		req     FeedbackRequest
+		wantErr error
+	}{
+		{"Click", FeedbackRequest{ImpressionID: impressionID, Interactions: click}, nil},
+		{"Unknown impression", FeedbackRequest{ImpressionID: "missing", Interactions: click}, nil},
+		{"No interactions", FeedbackRequest{ImpressionID: impressionID}, ErrInvalidRequest},
+		{"Unknown action", FeedbackRequest{ImpressionID: impressionID, Interactions: []feedback.Interaction{{DocID: "doc1", Action: "hover"}}}, ErrInvalidRequest},
+		{"Unknown document", FeedbackRequest{ImpressionID: impressionID, Interactions: []feedback.Interaction{{DocID: "doc3", Action: feedback.ActionClick}}}, ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Feedback(ctx, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Feedback() error = %v, want %v", err, tt.wantErr)
+			}
+			// Only invalid feedback is the client's fault
+			if tt.wantErr != ErrInvalidRequest && errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Feedback() error = %v, want no %v", err, ErrInvalidRequest)
+			}
+		})
+	}
+
+	// Impressions log document positions without features, and feedback on impressions this server does not
+	// remember, such as those of another replica, is logged unverified
+	impressions := waitForEvents(t, dir, feedback.EventImpression, 1)
+	if len(impressions) != 1 || len(impressions[0].Documents) != 2 || impressions[0].Documents[1].Position != 2 {
+		t.Fatalf("impressions = %+v, want the impression of %s", impressions, impressionID)
+	}
+	events := waitForEvents(t, dir, feedback.EventFeedback, 2)
+	if len(events) != 2 || events[0].Unverified || !events[1].Unverified || events[1].ImpressionID != "missing" {
+		t.Errorf("feedback events = %+v, want the click on %s and an unverified click on missing", events, impressionID)
+	}
+}
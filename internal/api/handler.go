@@ -1,8 +1,9 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"log"
+	"rpi-search-ranking/internal/logging"
 	"rpi-search-ranking/internal/ranking"
 	"rpi-search-ranking/internal/utils"
 	"time"
@@ -11,14 +12,19 @@ import (
 // HTTP timeout
 const httpTimeout = 10 * time.Second
 
+var logger = logging.For("api")
+
 // GetDocumentScores returns the scores and metadata for relevant documents based on the query
 // It also takes in an evaluation object to record metrics
-func GetDocumentScores(query ranking.Query, eval *utils.Evaluation) ([]ranking.Document, error) {
+func GetDocumentScores(ctx context.Context, query ranking.Query, eval *utils.Evaluation) ([]ranking.Document, error) {
 
 	// Validate the query text
 	if query.Text == "" {
 		return nil, errors.New("query text cannot be empty")
 	}
+	if err := validateQueryText(query.Text); err != nil {
+		return nil, err
+	}
 
 	// Create a new HTTP client
 	// client := &http.Client{
@@ -71,7 +77,7 @@ func GetDocumentScores(query ranking.Query, eval *utils.Evaluation) ([]ranking.D
 	// 	return nil, err
 	// }
 
-	log.Printf("Processed query ID: %s, Query Text: %s", query.Id, query.Text)
+	logger.InfoContext(ctx, "processed query", "queryID", query.Id, "queryText", query.Text)
     
 	// Return the document scores
 	return docScores, nil
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"rpi-search-ranking/internal/ranking"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Maximum time a single dependency probe may take
+const probeTimeout = 2 * time.Second
+
+// Dependency statuses reported by CheckReadiness
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// probeClient is separate from client so readiness probes do not count towards upstream metrics
+var probeClient = &http.Client{}
+
+var (
+	shuttingDown atomic.Bool
+
+	expectedModelsMu sync.Mutex
+	expectedModels   []string
+)
+
+// DependencyStatus is the readiness of a single dependency
+type DependencyStatus struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`          // "ok" or "error"
+	Error    string        `json:"error,omitempty"` // reason the dependency is not ready
+	Duration time.Duration `json:"duration"`        // time taken by the check in nanoseconds
+}
+
+// ReadinessReport is the response of GET /readyz
+type ReadinessReport struct {
+	Ready        bool               `json:"ready"`
+	ShuttingDown bool               `json:"shuttingDown"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// ExpectModel marks a model as required for readiness until it has been registered
+func ExpectModel(name string) {
+	expectedModelsMu.Lock()
+	defer expectedModelsMu.Unlock()
+	expectedModels = append(expectedModels, name)
+}
+
+// SetShuttingDown makes readiness checks fail so traffic is drained before the server stops
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// CheckReadiness probes every upstream API concurrently and checks that the expected models are loaded
+func CheckReadiness(ctx context.Context) ReadinessReport {
+	expectedModelsMu.Lock()
+	modelNames := append([]string(nil), expectedModels...)
+	expectedModelsMu.Unlock()
+
+	dependencies := make([]DependencyStatus, len(ranking.Upstreams), len(ranking.Upstreams)+len(modelNames))
+	var wg sync.WaitGroup
+	for i, upstream := range ranking.Upstreams {
+		wg.Add(1)
+		go func(i int, upstream ranking.Upstream) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+
+			startTime := time.Now()
+			err := ranking.ProbeUpstream(probeCtx, probeClient, upstream)
+			dependencies[i] = newDependencyStatus(upstream.Name, err, time.Since(startTime))
+		}(i, upstream)
+	}
+	wg.Wait()
+
+	for _, name := range modelNames {
+		var err error
+		if model, _, lookupErr := lookupModel(name); lookupErr != nil || model == nil {
+			err = fmt.Errorf("model %q is not loaded", name)
+		}
+		dependencies = append(dependencies, newDependencyStatus("model:"+name, err, 0))
+	}
+
+	report := ReadinessReport{
+		Ready:        !shuttingDown.Load(),
+		ShuttingDown: shuttingDown.Load(),
+		Dependencies: dependencies,
+	}
+	for _, dependency := range dependencies {
+		if dependency.Status != StatusOK {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func newDependencyStatus(name string, err error, duration time.Duration) DependencyStatus {
+	if err != nil {
+		return DependencyStatus{Name: name, Status: StatusError, Error: err.Error(), Duration: duration}
+	}
+	return DependencyStatus{Name: name, Status: StatusOK, Duration: duration}
+}
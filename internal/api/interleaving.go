@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/interleaving"
+	"rpi-search-ranking/internal/monitoring"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/utils"
+	"sync/atomic"
+	"time"
+)
+
+// InterleavedModel is the model name of responses merged from two rankers
+const InterleavedModel = "interleaved"
+
+// InterleavingExperiment compares two registered models on a fraction of the traffic
+type InterleavingExperiment struct {
+	Name     string  `json:"name"`
+	ModelA   string  `json:"modelA"`   // usually the production model
+	ModelB   string  `json:"modelB"`   // the candidate model
+	Fraction float64 `json:"fraction"` // share of eligible requests, between 0 and 1
+}
+
+// InterleavingConfig is the format of the interleaving experiments file
+type InterleavingConfig struct {
+	Experiments []InterleavingExperiment `json:"experiments"`
+}
+
+// interleavingExperiments holds the active experiments, whose fractions sum to at most 1
+var interleavingExperiments atomic.Pointer[[]InterleavingExperiment]
+
+// LoadInterleavingConfig reads interleaving experiments from a JSON file
+func LoadInterleavingConfig(filename string) (InterleavingConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return InterleavingConfig{}, fmt.Errorf("error reading interleaving config: %v", err)
+	}
+	var config InterleavingConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return InterleavingConfig{}, fmt.Errorf("error parsing interleaving config: %v", err)
+	}
+	return config, nil
+}
+
+// SetInterleavingExperiments validates and activates interleaving experiments. Models must already be registered.
+func SetInterleavingExperiments(experiments []InterleavingExperiment) error {
+	var total float64
+	names := make(map[string]bool, len(experiments))
+	for _, experiment := range experiments {
+		if experiment.Name == "" || names[experiment.Name] {
+			return fmt.Errorf("interleaving experiment names must be unique and non-empty: %q", experiment.Name)
+		}
+		names[experiment.Name] = true
+		if experiment.Fraction < 0 || experiment.Fraction > 1 {
+			return fmt.Errorf("fraction of experiment %s must be between 0 and 1", experiment.Name)
+		}
+		total += experiment.Fraction
+		for _, name := range []string{experiment.ModelA, experiment.ModelB} {
+			if _, _, err := lookupModel(name); err != nil {
+				return fmt.Errorf("experiment %s: %v", experiment.Name, err)
+			}
+		}
+	}
+	if total > 1 {
+		return fmt.Errorf("interleaving fractions sum to %.2f, more than 1", total)
+	}
+
+	experiments = append([]InterleavingExperiment(nil), experiments...)
+	interleavingExperiments.Store(&experiments)
+	return nil
+}
+
+// pickInterleavingExperiment draws the experiment a request takes part in, if any
+func pickInterleavingExperiment() (InterleavingExperiment, bool) {
+	experiments := interleavingExperiments.Load()
+	if experiments == nil {
+		return InterleavingExperiment{}, false
+	}
+	draw := rand.Float64()
+	for _, experiment := range *experiments {
+		if draw < experiment.Fraction {
+			return experiment, true
+		}
+		draw -= experiment.Fraction
+	}
+	return InterleavingExperiment{}, false
+}
+
+// rankInterleaved ranks the request with both models of an experiment and merges the results with team-draft interleaving.
+// The candidates are fetched and featurized once and ordered by each model, and only the merged ranking is captured.
+func rankInterleaved(ctx context.Context, req RankRequest, experiment InterleavingExperiment, eval *utils.Evaluation) (RankResponse, error) {
+	// Start timer
+	startTime := time.Now()
+
+	modelNames := []string{experiment.ModelA, experiment.ModelB}
+	models := make([]ranking.PairwiseModel, 2)
+	for i, modelName := range modelNames {
+		model, _, err := lookupModel(modelName)
+		if err != nil {
+			return RankResponse{}, err
+		}
+		models[i] = model
+	}
+
+	candidates, err := ranking.FetchCandidates(ranking.Query{Id: req.QueryID, Text: req.QueryText}, clientFor(ctx), req.Filter, ranking.BM25Params{})
+	if err != nil {
+		return RankResponse{}, err
+	}
+
+	// Order the same candidates with each model. The rankings of each model are not served as is.
+	results := make([]ranking.RankResult, 2)
+	rankings := make([][]string, 2)
+	docsByTeam := make([]map[string]ranking.Document, 2)
+	for i, model := range models {
+		results[i] = candidates.Rank(ranking.RankOptions{Model: model, NoCapture: true})
+		if model != nil {
+			monitoring.ModelInferenceDuration.Observe(results[i].InferenceTime.Seconds(), modelNames[i])
+		}
+		docsByTeam[i] = make(map[string]ranking.Document, len(results[i].Documents))
+		for _, doc := range results[i].Documents {
+			rankings[i] = append(rankings[i], doc.DocID)
+			docsByTeam[i][doc.DocID] = doc
+		}
+	}
+	monitoring.CandidateDocuments.Observe(float64(results[0].TotalCandidates))
+
+	// Merge the whole rankings, keeping each document as scored by the team that contributed it, and capture the
+	// merged ranking. Team-draft merges a prefix of the whole merged ranking, so the served page is its top.
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	merged, teams := interleaving.TeamDraft(rankings[0], rankings[1], len(rankings[0])+len(rankings[1]), rng)
+	docs := make(ranking.Documents, len(merged))
+	for i, docID := range merged {
+		team := 0
+		if teams[i] == interleaving.TeamB {
+			team = 1
+		}
+		docs[i] = docsByTeam[team][docID]
+		docs[i].Rank = i + 1
+	}
+	candidates.Capture(docs, teams, ranking.RankOptions{ModelName: InterleavedModel, Experiment: experiment.Name})
+	if req.TopK > 0 && req.TopK < len(docs) {
+		docs, teams = docs[:req.TopK], teams[:req.TopK]
+	}
+
+	// End timer
+	processTime := time.Since(startTime)
+
+	// Record metrics to evaluation
+	eval.AlgorithmRunTime = results[0].InferenceTime + results[1].InferenceTime
+	eval.QueryData.ProcessTime = processTime
+	eval.QueryData.NumDocumentsParsed = results[0].TotalCandidates
+	eval.QueryData.NumRankedDocuments = len(docs)
+	eval.Experiment = experiment.Name
+
+	impression := feedback.Impression{QueryID: req.QueryID, QueryText: req.QueryText, Model: InterleavedModel, Experiment: experiment.Name}
+	response := RankResponse{
+		QueryID:         req.QueryID,
+		ImpressionID:    recordImpression(ctx, impression, docs, teams),
+		Model:           InterleavedModel,
+		Experiment:      experiment.Name,
+		TotalCandidates: results[0].TotalCandidates,
+		TotalRanked:     results[0].TotalRanked,
+		Results:         rankedDocuments(docs),
+	}
+
+	logger.InfoContext(ctx, "processed interleaved query", "queryID", req.QueryID, "queryText", req.QueryText,
+		"experiment", experiment.Name, "candidates", response.TotalCandidates, "processTime", processTime)
+
+	return response, nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/interleaving"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/utils"
+	"strings"
+	"testing"
+)
+
+// captures collects the captured rankings
+type captures []ranking.Capture
+
+func (c *captures) Capture(capture ranking.Capture) {
+	*c = append(*c, capture)
+}
+
+func TestRank_interleaved(t *testing.T) {
+	useFakeUpstreams(t)
+	registerTestModel(t, "reverse", reverseBM25{})
+	dir := setFeedbackRecorder(t)
+	if err := SetInterleavingExperiments([]InterleavingExperiment{{Name: "reverse-vs-bm25", ModelA: DefaultModel, ModelB: "reverse", Fraction: 1}}); err != nil {
+		t.Fatalf("SetInterleavingExperiments() error = %v", err)
+	}
+	t.Cleanup(func() { interleavingExperiments.Store(nil) })
+	var captured captures
+	ranking.SetCapturer(&captured)
+	t.Cleanup(func() { ranking.SetCapturer(nil) })
+
+	// Count the index requests of the fake upstreams
+	upstream := client.Transport
+	indexRequests := 0
+	client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.URL.String(), ranking.InvertibleIndexEndpoint) {
+			indexRequests++
+		}
+		return upstream.RoundTrip(req)
+	})}
+
+	for i, topK := range []int{0, 2} {
+		eval := utils.CreateEvaluation()
+		indexRequests, captured = 0, nil
+		response, err := Rank(context.Background(), RankRequest{QueryID: "q1", QueryText: "rpi", TopK: topK}, eval)
+		if err != nil {
+			t.Fatalf("Rank() error = %v", err)
+		}
+		// Both models rank the same candidates, fetched once
+		if indexRequests != 1 {
+			t.Errorf("Rank() made %d index requests, want 1", indexRequests)
+		}
+		if response.Model != InterleavedModel || response.Experiment != "reverse-vs-bm25" || eval.Experiment != "reverse-vs-bm25" {
+			t.Errorf("Rank() ranked with model %q in experiment %q, want the interleaving experiment", response.Model, response.Experiment)
+		}
+
+		// Each document appears once, and the first is the top document of the team that picked first
+		wantLength := 3
+		if topK > 0 {
+			wantLength = topK
+		}
+		seen := make(map[string]bool)
+		for i, result := range response.Results {
+			if seen[result.DocID] || result.Rank != i+1 {
+				t.Errorf("Rank() results = %+v, want distinct documents ranked from 1", response.Results)
+			}
+			seen[result.DocID] = true
+		}
+		if len(response.Results) != wantLength {
+			t.Fatalf("Rank() returned %d results with topK %d, want %d", len(response.Results), topK, wantLength)
+		}
+
+		impressions := waitForEvents(t, dir, feedback.EventImpression, i+1)
+		impression := impressions[len(impressions)-1]
+		if impression.ImpressionID != response.ImpressionID || impression.Experiment != "reverse-vs-bm25" || len(impression.Documents) != wantLength {
+			t.Fatalf("impression = %+v, want the interleaved results of %s", impression, response.ImpressionID)
+		}
+		topOfTeam := map[string]string{interleaving.TeamA: "doc1", interleaving.TeamB: "doc3"}
+		if first := impression.Documents[0]; topOfTeam[first.Team] != first.DocID {
+			t.Errorf("first document %s of team %q, want the top document of its team", first.DocID, first.Team)
+		}
+
+		// Only the merged ranking is captured, whole and with the team of each document
+		if len(captured) != 1 {
+			t.Fatalf("Rank() made %d captures, want 1", len(captured))
+		}
+		if c := captured[0]; c.Model != InterleavedModel || c.Experiment != "reverse-vs-bm25" || len(c.Documents) != 3 || len(c.Teams) != 3 {
+			t.Fatalf("capture = %+v, want the whole interleaved ranking", c)
+		}
+		for i, doc := range impression.Documents {
+			if c := captured[0]; c.Documents[i].DocID != doc.DocID || c.Teams[i] != doc.Team {
+				t.Errorf("captured document %d = %s of team %q, want the impression document %s of team %q", i, c.Documents[i].DocID, c.Teams[i], doc.DocID, doc.Team)
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"rpi-search-ranking/internal/feedback"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/monitoring"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultModel ranks documents by BM25 and is always available
+const DefaultModel = "bm25"
+
+// MaxBatchQueries is the maximum number of queries accepted by RankBatch
+const MaxBatchQueries = 32
+
+// Number of batch queries ranked at the same time
+const maxConcurrentQueries = 8
+
+// ErrInvalidRequest is wrapped by errors caused by invalid client input
+var ErrInvalidRequest = errors.New("invalid request")
+
+// RankFailedMessage is returned to clients for rankings that fail for reasons other than invalid input
+const RankFailedMessage = "Failed to retrieve document scores"
+
+// client is shared by all ranking calls so upstream connections are reused
+var client = &http.Client{
+	Timeout: httpTimeout,
+	Transport: monitoring.InstrumentTransport(http.DefaultTransport, func(req *http.Request) string {
+		return ranking.UpstreamName(req.URL.String())
+	}),
+}
+
+// clientFor returns an HTTP client that forwards the request ID of the context to the upstream services
+func clientFor(ctx context.Context) *http.Client {
+	requestID := logging.RequestID(ctx)
+	if requestID == "" {
+		return client
+	}
+	return &http.Client{
+		Timeout:   client.Timeout,
+		Transport: requestIDTransport{requestID: requestID, base: client.Transport},
+	}
+}
+
+// requestIDTransport sets the request ID header on every outgoing request
+type requestIDTransport struct {
+	requestID string
+	base      http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(logging.RequestIDHeader, t.requestID)
+	return t.base.RoundTrip(req)
+}
+
+// registeredModel is a model with the version recorded in its captures
+type registeredModel struct {
+	model   ranking.PairwiseModel
+	version string
+}
+
+var (
+	modelsMu sync.RWMutex
+	models   = map[string]registeredModel{DefaultModel: {}}
+)
+
+// RegisterModel makes a model available to ranking requests under the given name.
+// version identifies the trained weights, e.g. a hash of the model file.
+func RegisterModel(name, version string, model ranking.PairwiseModel) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[name] = registeredModel{model: model, version: version}
+}
+
+// Models returns the sorted names of all registered models
+func Models() []string {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupModel returns the model registered under name, defaulting to BM25 when name is empty
+func lookupModel(name string) (ranking.PairwiseModel, string, error) {
+	if name == "" {
+		name = DefaultModel
+	}
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	registered, ok := models[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: unknown model %q", ErrInvalidRequest, name)
+	}
+	return registered.model, name, nil
+}
+
+// modelVersion returns the version of the model registered under name
+func modelVersion(name string) string {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	return models[name].version
+}
+
+// RankRequest is the body of POST /v1/rank and each entry of POST /v1/rank/batch
+type RankRequest struct {
+	QueryID   string         `json:"queryID"`          // client supplied query identifier, required
+	QueryText string         `json:"queryText"`        // query text, required
+	Model     string         `json:"model,omitempty"`  // registered model name, defaults to "bm25"
+	TopK      int            `json:"topK,omitempty"`   // number of results to return, defaults to all
+	Offset    int            `json:"offset,omitempty"` // number of ranked results to skip
+	Filter    ranking.Filter `json:"filter,omitempty"` // metadata restrictions on the results
+	Debug     bool           `json:"debug,omitempty"`  // include explanations and timings in the response
+	UserID    string         `json:"userID,omitempty"` // stable user identifier for experiment bucketing
+}
+
+// RankResponse is the response of POST /v1/rank
+type RankResponse struct {
+	QueryID         string           `json:"queryID"`              // query identifier from the request
+	ImpressionID    string           `json:"impressionID"`         // identifies this response in POST /v1/feedback
+	Model           string           `json:"model"`                // model used to order the results, "interleaved" in experiments
+	Experiment      string           `json:"experiment,omitempty"` // interleaving experiment or bucket that produced the results
+	TotalCandidates int              `json:"totalCandidates"`      // documents matching any query term
+	TotalRanked     int              `json:"totalRanked"`          // documents ranked after filtering, before paging
+	Results         []RankedDocument `json:"results"`              // requested page of ranked documents
+	Debug           *DebugInfo       `json:"debug,omitempty"`      // only present when requested
+}
+
+// RankedDocument is a single ranked result
+type RankedDocument struct {
+	DocID       string                   `json:"docID"`                 // document identifier
+	Rank        int                      `json:"rank"`                  // 1-based position in the full ranking
+	Score       float64                  `json:"score"`                 // BM25 score, or pairwise wins for learned models
+	Metadata    ranking.DocumentMetadata `json:"metadata"`              // document metadata from the index
+	Explanation *ranking.Explanation     `json:"explanation,omitempty"` // features and score breakdown, debug only
+}
+
+// DebugInfo holds timing information about a ranking call
+type DebugInfo struct {
+	ProcessTime   time.Duration `json:"processTime"`   // total ranking time in nanoseconds
+	InferenceTime time.Duration `json:"inferenceTime"` // time spent in the model in nanoseconds
+}
+
+// BatchRankRequest is the body of POST /v1/rank/batch
+type BatchRankRequest struct {
+	Queries []RankRequest `json:"queries"` // up to MaxBatchQueries queries
+}
+
+// BatchRankResponse is the response of POST /v1/rank/batch, with results in request order
+type BatchRankResponse struct {
+	Results []BatchRankResult `json:"results"`
+}
+
+// BatchRankResult holds either the response or the error of a single batch query
+type BatchRankResult struct {
+	Response *RankResponse `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// maxQueryLength and maxQueryTerms limit the size of accepted queries, 0 disables a limit
+var maxQueryLength, maxQueryTerms atomic.Int64
+
+// SetQueryLimits sets the maximum query text length in bytes and the maximum number of query terms
+func SetQueryLimits(maxLength, maxTerms int) {
+	maxQueryLength.Store(int64(maxLength))
+	maxQueryTerms.Store(int64(maxTerms))
+}
+
+// validateQueryText checks the query text against the configured limits
+func validateQueryText(text string) error {
+	if limit := maxQueryLength.Load(); limit > 0 && int64(len(text)) > limit {
+		return fmt.Errorf("%w: query text exceeds %d bytes", ErrInvalidRequest, limit)
+	}
+	if limit := maxQueryTerms.Load(); limit > 0 && int64(len(strings.Fields(text))) > limit {
+		return fmt.Errorf("%w: query exceeds %d terms", ErrInvalidRequest, limit)
+	}
+	return nil
+}
+
+// validate checks the request fields that do not depend on upstream services
+func (req RankRequest) validate() error {
+	if req.QueryID == "" || req.QueryText == "" {
+		return fmt.Errorf("%w: queryID and queryText are required", ErrInvalidRequest)
+	}
+	if req.TopK < 0 || req.Offset < 0 {
+		return fmt.Errorf("%w: topK and offset cannot be negative", ErrInvalidRequest)
+	}
+	return validateQueryText(req.QueryText)
+}
+
+// Rank ranks the documents for a single request and records metrics in the evaluation object
+func Rank(ctx context.Context, req RankRequest, eval *utils.Evaluation) (RankResponse, error) {
+	if err := req.validate(); err != nil {
+		return RankResponse{}, err
+	}
+
+	// Interleave two rankers for a fraction of the requests that do not ask for a specific model
+	if req.Model == "" && !req.Debug && req.Offset == 0 {
+		if experiment, ok := pickInterleavingExperiment(); ok {
+			return rankInterleaved(ctx, req, experiment, eval)
+		}
+	}
+
+	// Requests that do not ask for a specific model are ranked with the config of their experiment bucket
+	ranker := RankerConfig{Model: req.Model}
+	var bucket Bucket
+	if req.Model == "" {
+		if assigned, ok := assignBucket(ctx, req); ok {
+			bucket, ranker = assigned, assigned.Ranker
+			eval.Experiment = bucket.Name
+		}
+	}
+
+	model, modelName, err := ranker.resolve()
+	if err != nil {
+		return RankResponse{}, err
+	}
+
+	// Start timer
+	startTime := time.Now()
+
+	result, err := ranking.RankDocumentsWithOptions(ranking.Query{Id: req.QueryID, Text: req.QueryText}, clientFor(ctx), ranking.RankOptions{
+		Model:        model,
+		TopK:         req.TopK,
+		Offset:       req.Offset,
+		Filter:       req.Filter,
+		Explain:      req.Debug,
+		BM25:         ranker.BM25,
+		ModelName:    modelName,
+		ModelVersion: modelVersion(modelName),
+		Experiment:   bucket.Name,
+	})
+	if err != nil {
+		return RankResponse{}, err
+	}
+
+	// End timer
+	processTime := time.Since(startTime)
+	recordRankMetrics(modelName, model, result)
+
+	// Record metrics to evaluation
+	eval.AlgorithmRunTime = result.InferenceTime
+	eval.QueryData.ProcessTime = processTime
+	eval.QueryData.NumDocumentsParsed = result.TotalCandidates
+	eval.QueryData.NumRankedDocuments = len(result.Documents)
+
+	impression := feedback.Impression{QueryID: req.QueryID, QueryText: req.QueryText, Model: modelName, Bucket: bucket.Name}
+	response := RankResponse{
+		QueryID:         req.QueryID,
+		ImpressionID:    recordImpression(ctx, impression, result.Documents, nil),
+		Model:           modelName,
+		Experiment:      bucket.Name,
+		TotalCandidates: result.TotalCandidates,
+		TotalRanked:     result.TotalRanked,
+		Results:         rankedDocuments(result.Documents),
+	}
+	if req.Debug {
+		response.Debug = &DebugInfo{
+			ProcessTime:   processTime,
+			InferenceTime: result.InferenceTime,
+		}
+	}
+
+	// Shadow rankers are compared against the served impression, so they need one
+	if response.ImpressionID != "" {
+		runShadows(ctx, req, response.ImpressionID, eval)
+	}
+
+	logger.InfoContext(ctx, "processed query", "queryID", req.QueryID, "queryText", req.QueryText, "model", modelName,
+		"experiment", bucket.Name, "candidates", result.TotalCandidates, "processTime", processTime)
+
+	return response, nil
+}
+
+// rankedDocuments converts ranked documents to results
+func rankedDocuments(docs []ranking.Document) []RankedDocument {
+	results := make([]RankedDocument, len(docs))
+	for i, doc := range docs {
+		results[i] = RankedDocument{
+			DocID:       doc.DocID,
+			Rank:        doc.Rank,
+			Score:       doc.Score,
+			Metadata:    doc.Metadata,
+			Explanation: doc.Explanation,
+		}
+	}
+	return results
+}
+
+// recordRankMetrics reports the candidate set size and model inference time of a ranking call
+func recordRankMetrics(modelName string, model ranking.PairwiseModel, result ranking.RankResult) {
+	monitoring.CandidateDocuments.Observe(float64(result.TotalCandidates))
+	if model != nil {
+		monitoring.ModelInferenceDuration.Observe(result.InferenceTime.Seconds(), modelName)
+	}
+}
+
+// RankBatch ranks up to MaxBatchQueries requests concurrently.
+// Each query gets its own evaluation object, returned in request order alongside the results.
+func RankBatch(ctx context.Context, batch BatchRankRequest) (BatchRankResponse, []*utils.Evaluation, error) {
+	if len(batch.Queries) == 0 {
+		return BatchRankResponse{}, nil, fmt.Errorf("%w: at least one query is required", ErrInvalidRequest)
+	}
+	if len(batch.Queries) > MaxBatchQueries {
+		return BatchRankResponse{}, nil, fmt.Errorf("%w: at most %d queries are allowed per batch", ErrInvalidRequest, MaxBatchQueries)
+	}
+
+	results := make([]BatchRankResult, len(batch.Queries))
+	evals := make([]*utils.Evaluation, len(batch.Queries))
+
+	// Limit the number of queries fanning out to the upstream services at once
+	semaphore := make(chan struct{}, maxConcurrentQueries)
+	var wg sync.WaitGroup
+	for i, req := range batch.Queries {
+		wg.Add(1)
+		go func(i int, req RankRequest) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			evals[i] = utils.CreateEvaluation()
+			response, err := Rank(ctx, req, evals[i])
+			if errors.Is(err, ErrInvalidRequest) {
+				results[i].Error = err.Error()
+				return
+			}
+			// Like single queries, other errors are only logged since they may expose upstream details
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to rank batch query", "queryID", req.QueryID, "error", err)
+				results[i].Error = RankFailedMessage
+				return
+			}
+			results[i].Response = &response
+		}(i, req)
+	}
+	wg.Wait()
+
+	return BatchRankResponse{Results: results}, evals, nil
+}
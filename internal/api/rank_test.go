@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/utils"
+	"strings"
+	"testing"
+)
+
+// reverseBM25 prefers the document with the lower BM25 score
+type reverseBM25 struct{}
+
+func (reverseBM25) PredictClass(diff ranking.Features) int {
+	if diff.BM25 < 0 {
+		return 1
+	}
+	return -1
+}
+
+// registerTestModel registers a model for the duration of a test
+func registerTestModel(t *testing.T, name string, model ranking.PairwiseModel) {
+	t.Helper()
+	RegisterModel(name, "test", model)
+	t.Cleanup(func() {
+		modelsMu.Lock()
+		defer modelsMu.Unlock()
+		delete(models, name)
+	})
+}
+
+// roundTripFunc allows creating a RoundTripper from a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// useFakeUpstreams answers the upstream requests of rankings for the duration of a test.
+// The term "rpi" matches doc1, doc2 and doc3 with decreasing frequency, so BM25 ranks them in that order.
+// The index fails for the term "unavailable".
+func useFakeUpstreams(t *testing.T) {
+	t.Helper()
+	frequencies := map[string]int{"doc1": 3, "doc2": 2, "doc3": 1}
+	respond := func(url string) (string, bool) {
+		if term, ok := strings.CutPrefix(url, ranking.InvertibleIndexEndpoint); ok {
+			if term == "unavailable" {
+				return "", false
+			}
+			if term != "rpi" {
+				return fmt.Sprintf(`{"term": %q, "index": []}`, term), true
+			}
+			var index []string
+			for docID, frequency := range frequencies {
+				index = append(index, fmt.Sprintf(`{"docID": %q, "frequency": %d, "positions": [1]}`, docID, frequency))
+			}
+			return fmt.Sprintf(`{"term": "rpi", "index": [%s]}`, strings.Join(index, ",")), true
+		}
+		if docID, ok := strings.CutPrefix(url, ranking.MetadataEndpoint); ok {
+			return fmt.Sprintf(`{"docID": %q, "metadata": {"docLength": 100, "timeLastUpdated": "2024-11-09T15:30:00Z",
+				"docType": "html", "imageCount": 0, "docTitle": %[1]q, "URL": "https://%[1]s.example.com"}}`, docID), true
+		}
+		if url == ranking.StatisticsEndpoint {
+			return `{"avgDocLength": 100, "docCount": 10}`, true
+		}
+		if strings.HasPrefix(url, ranking.PagerankEndpoint) {
+			return `{"pageRank": 0.5, "inLinkCount": 1, "outLinkCount": 1}`, true
+		}
+		return "", false
+	}
+
+	original := client
+	client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, ok := respond(req.URL.String())
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusNotFound
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+	t.Cleanup(func() { client = original })
+}
+
+func docIDs(results []RankedDocument) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.DocID
+	}
+	return ids
+}
+
+func TestRank(t *testing.T) {
+	useFakeUpstreams(t)
+	registerTestModel(t, "reverse", reverseBM25{})
+
+	tests := []struct {
+		name    string
+		req     RankRequest
+		want    []string
+		wantErr error
+	}{
+		{"BM25", RankRequest{QueryID: "q1", QueryText: "rpi"}, []string{"doc1", "doc2", "doc3"}, nil},
+		{"Model", RankRequest{QueryID: "q1", QueryText: "rpi", Model: "reverse"}, []string{"doc3", "doc2", "doc1"}, nil},
+		{"Page", RankRequest{QueryID: "q1", QueryText: "rpi", TopK: 1, Offset: 1}, []string{"doc2"}, nil},
+		{"No matches", RankRequest{QueryID: "q1", QueryText: "unknown"}, []string{}, nil},
+		{"Missing query text", RankRequest{QueryID: "q1"}, nil, ErrInvalidRequest},
+		{"Negative topK", RankRequest{QueryID: "q1", QueryText: "rpi", TopK: -1}, nil, ErrInvalidRequest},
+		{"Unknown model", RankRequest{QueryID: "q1", QueryText: "rpi", Model: "missing"}, nil, ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := Rank(context.Background(), tt.req, utils.CreateEvaluation())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rank() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(docIDs(response.Results), tt.want) {
+				t.Errorf("Rank() results = %v, want %v", docIDs(response.Results), tt.want)
+			}
+		})
+	}
+}
+
+func TestRankBatch(t *testing.T) {
+	useFakeUpstreams(t)
+
+	tooMany := make([]RankRequest, MaxBatchQueries+1)
+	for _, queries := range [][]RankRequest{nil, tooMany} {
+		if _, _, err := RankBatch(context.Background(), BatchRankRequest{Queries: queries}); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("RankBatch() of %d queries error = %v, want %v", len(queries), err, ErrInvalidRequest)
+		}
+	}
+
+	// Results and evaluations are in request order, with errors of single queries in their result
+	batch := BatchRankRequest{Queries: []RankRequest{
+		{QueryID: "q1", QueryText: "rpi"},
+		{QueryID: "q2"},
+		{QueryID: "q3", QueryText: "rpi", TopK: 1},
+		{QueryID: "q4", QueryText: "unavailable"},
+	}}
+	response, evals, err := RankBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("RankBatch() error = %v", err)
+	}
+	if len(response.Results) != 4 || len(evals) != 4 {
+		t.Fatalf("RankBatch() returned %d results and %d evaluations, want 4", len(response.Results), len(evals))
+	}
+	if r := response.Results[0].Response; r == nil || r.QueryID != "q1" || len(r.Results) != 3 {
+		t.Errorf("RankBatch() result 0 = %+v, want 3 documents of q1", response.Results[0])
+	}
+	if r := response.Results[1]; r.Response != nil || !strings.HasPrefix(r.Error, ErrInvalidRequest.Error()) {
+		t.Errorf("RankBatch() result 1 = %+v, want an invalid request error", r)
+	}
+	if r := response.Results[2].Response; r == nil || r.QueryID != "q3" || len(r.Results) != 1 || evals[2].QueryData.NumRankedDocuments != 1 {
+		t.Errorf("RankBatch() result 2 = %+v, want 1 document of q3", response.Results[2])
+	}
+	// Upstream failures are not exposed to clients
+	if r := response.Results[3]; r.Response != nil || r.Error != RankFailedMessage {
+		t.Errorf("RankBatch() result 3 = %+v, want error %q", r, RankFailedMessage)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds API clients, their credentials and quotas. It is loaded from a JSON file, for example:
+//
+//	{
+//	  "clients": [
+//	    {"id": "frontend", "apiKey": "k1", "ratePerSecond": 20, "burst": 40},
+//	    {"id": "crawler", "hmacSecret": "s1", "ratePerSecond": 2, "burst": 5}
+//	  ],
+//	  "ipRatePerSecond": 10,
+//	  "ipBurst": 20,
+//	  "maxQueryLength": 512,
+//	  "maxQueryTerms": 32,
+//	  "publicPaths": ["/healthz", "/readyz", "/metrics"]
+//	}
+type Config struct {
+	Clients         []Client `json:"clients"`
+	IPRatePerSecond float64  `json:"ipRatePerSecond"` // requests per second allowed from one IP, 0 disables the limit
+	IPBurst         int      `json:"ipBurst"`         // requests one IP may send at once
+	MaxQueryLength  int      `json:"maxQueryLength"`  // maximum query text length in bytes, 0 disables the limit
+	MaxQueryTerms   int      `json:"maxQueryTerms"`   // maximum number of query terms, 0 disables the limit
+	PublicPaths     []string `json:"publicPaths"`     // paths served without credentials or rate limits
+}
+
+// Client is an API client identified by an API key or by HMAC-signed requests
+type Client struct {
+	ID            string  `json:"id"`
+	APIKey        string  `json:"apiKey,omitempty"`     // sent in the X-API-Key header
+	HMACSecret    string  `json:"hmacSecret,omitempty"` // key used to sign requests, see Guard.Authorize
+	RatePerSecond float64 `json:"ratePerSecond"`        // requests per second, 0 disables the limit
+	Burst         int     `json:"burst"`                // requests the client may send at once
+}
+
+// LoadConfig reads and validates a JSON config file
+func LoadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode auth config %s: %v", filename, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth config %s: %v", filename, err)
+	}
+	return &config, nil
+}
+
+// validate checks that client IDs and credentials are unique and quotas are consistent
+func (c *Config) validate() error {
+	ids := make(map[string]struct{})
+	keys := make(map[string]struct{})
+	for _, client := range c.Clients {
+		if client.ID == "" {
+			return fmt.Errorf("client without id")
+		}
+		if _, ok := ids[client.ID]; ok {
+			return fmt.Errorf("duplicate client id %q", client.ID)
+		}
+		ids[client.ID] = struct{}{}
+
+		if client.APIKey == "" && client.HMACSecret == "" {
+			return fmt.Errorf("client %q has neither an apiKey nor an hmacSecret", client.ID)
+		}
+		if client.APIKey != "" {
+			if _, ok := keys[client.APIKey]; ok {
+				return fmt.Errorf("client %q reuses the api key of another client", client.ID)
+			}
+			keys[client.APIKey] = struct{}{}
+		}
+		if client.RatePerSecond < 0 || (client.RatePerSecond > 0 && client.Burst < 1) {
+			return fmt.Errorf("client %q needs a non-negative ratePerSecond and a burst of at least 1", client.ID)
+		}
+	}
+	if c.IPRatePerSecond < 0 || (c.IPRatePerSecond > 0 && c.IPBurst < 1) {
+		return fmt.Errorf("ipRatePerSecond must be non-negative with an ipBurst of at least 1")
+	}
+	if c.MaxQueryLength < 0 || c.MaxQueryTerms < 0 {
+		return fmt.Errorf("query limits cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Headers used to authenticate requests
+const (
+	APIKeyHeader    = "X-API-Key"
+	ClientIDHeader  = "X-Client-ID"
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+// Maximum difference between the signed timestamp and the server clock
+const maxClockSkew = 5 * time.Minute
+
+// Maximum request body size read to verify a signature
+const maxSignedBodyBytes = 1 << 20
+
+// Rejection describes why a request was refused
+type Rejection struct {
+	StatusCode int           // http.StatusUnauthorized or http.StatusTooManyRequests
+	Message    string        // reason sent to the client
+	RetryAfter time.Duration // time until the request may be retried, for rate limited requests
+}
+
+// Guard authenticates requests and enforces per-client and per-IP rate limits.
+// The configuration can be replaced at any time with Reload.
+type Guard struct {
+	state atomic.Pointer[guardState]
+	now   func() time.Time
+}
+
+// guardState is an immutable snapshot of a configuration and its limiters
+type guardState struct {
+	config         *Config
+	clientsByKey   map[string]*Client
+	clientsByID    map[string]*Client
+	clientLimiters map[string]*limiter
+	ipLimiter      *limiter
+}
+
+// NewGuard creates a guard enforcing the given configuration
+func NewGuard(config *Config) *Guard {
+	g := &Guard{now: time.Now}
+	g.Reload(config)
+	return g
+}
+
+// Reload replaces the configuration, resetting all rate limits
+func (g *Guard) Reload(config *Config) {
+	state := &guardState{
+		config:         config,
+		clientsByKey:   make(map[string]*Client),
+		clientsByID:    make(map[string]*Client),
+		clientLimiters: make(map[string]*limiter),
+		ipLimiter:      newLimiter(config.IPRatePerSecond, config.IPBurst),
+	}
+	for i := range config.Clients {
+		client := &config.Clients[i]
+		state.clientsByID[client.ID] = client
+		if client.APIKey != "" {
+			state.clientsByKey[client.APIKey] = client
+		}
+		state.clientLimiters[client.ID] = newLimiter(client.RatePerSecond, client.Burst)
+	}
+	g.state.Store(state)
+}
+
+// Config returns the configuration currently enforced
+func (g *Guard) Config() *Config {
+	return g.state.Load().config
+}
+
+// Authorize checks the request's IP rate limit, credentials and client rate limit, in that order.
+// It returns the authenticated client ID, or a rejection. Public paths are always allowed.
+//
+// Clients authenticate with their API key in the X-API-Key header, or by signing the request:
+// X-Client-ID holds the client ID, X-Timestamp the Unix time in seconds and X-Signature the hex
+// encoded HMAC-SHA256, keyed by the client's secret, of "METHOD\nREQUEST_URI\nTIMESTAMP\nBODY".
+func (g *Guard) Authorize(r *http.Request) (string, *Rejection) {
+	state := g.state.Load()
+	if slices.Contains(state.config.PublicPaths, r.URL.Path) {
+		return "", nil
+	}
+	now := g.now()
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if ok, wait := state.ipLimiter.allow(ip, now); !ok {
+		return "", rateLimited("too many requests from this address", wait)
+	}
+
+	client, rejection := state.authenticate(r, now)
+	if rejection != nil {
+		return "", rejection
+	}
+
+	if ok, wait := state.clientLimiters[client.ID].allow(client.ID, now); !ok {
+		return client.ID, rateLimited("client quota exceeded", wait)
+	}
+	return client.ID, nil
+}
+
+// authenticate identifies the client from an API key or a request signature
+func (s *guardState) authenticate(r *http.Request, now time.Time) (*Client, *Rejection) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		for candidate, client := range s.clientsByKey {
+			if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+				return client, nil
+			}
+		}
+		return nil, unauthorized("invalid API key")
+	}
+
+	clientID := r.Header.Get(ClientIDHeader)
+	if clientID == "" {
+		return nil, unauthorized("missing credentials")
+	}
+	client, ok := s.clientsByID[clientID]
+	if !ok || client.HMACSecret == "" {
+		return nil, unauthorized("unknown client")
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return nil, unauthorized("invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, unauthorized("timestamp outside the allowed clock skew")
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return nil, unauthorized("invalid signature")
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return nil, unauthorized(err.Error())
+	}
+	if !hmac.Equal(signature, Sign(client.HMACSecret, r.Method, r.URL.RequestURI(), timestamp, body)) {
+		return nil, unauthorized("invalid signature")
+	}
+	return client, nil
+}
+
+// Sign computes the request signature expected by Authorize
+func Sign(secret, method, requestURI string, timestamp int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n", method, requestURI, timestamp)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// readBody reads the request body for signature verification and restores it for the handler
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body")
+	}
+	if len(body) > maxSignedBodyBytes {
+		return nil, fmt.Errorf("request body too large to verify")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func unauthorized(message string) *Rejection {
+	return &Rejection{StatusCode: http.StatusUnauthorized, Message: message}
+}
+
+func rateLimited(message string, wait time.Duration) *Rejection {
+	return &Rejection{StatusCode: http.StatusTooManyRequests, Message: message, RetryAfter: wait}
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow is the server time used by test guards
+var testNow = time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestGuard(config *Config) *Guard {
+	g := NewGuard(config)
+	g.now = func() time.Time { return testNow }
+	return g
+}
+
+// advance moves the clock of a guard forward
+func advance(g *Guard, d time.Duration) {
+	now := g.now().Add(d)
+	g.now = func() time.Time { return now }
+}
+
+func signedRequest(secret, clientID string, timestamp int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/rank?debug=true", strings.NewReader(body))
+	req.Header.Set(ClientIDHeader, clientID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, hex.EncodeToString(Sign(secret, http.MethodPost, "/v1/rank?debug=true", timestamp, []byte(body))))
+	return req
+}
+
+func TestGuard_Authorize(t *testing.T) {
+	config := &Config{
+		Clients: []Client{
+			{ID: "frontend", APIKey: "key1"},
+			{ID: "crawler", HMACSecret: "secret"},
+		},
+		PublicPaths: []string{"/healthz"},
+	}
+	apiKeyRequest := func(key string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/getDocumentScores?id=1&text=rpi", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		return req
+	}
+	tests := []struct {
+		name         string
+		req          *http.Request
+		wantClientID string
+		wantStatus   int
+	}{
+		{"Valid API key", apiKeyRequest("key1"), "frontend", 0},
+		{"Invalid API key", apiKeyRequest("nope"), "", http.StatusUnauthorized},
+		{"Missing credentials", apiKeyRequest(""), "", http.StatusUnauthorized},
+		{"Public path", httptest.NewRequest(http.MethodGet, "/healthz", nil), "", 0},
+		{"Valid signature", signedRequest("secret", "crawler", testNow.Unix(), `{"queryID":"1"}`), "crawler", 0},
+		{"Wrong secret", signedRequest("guess", "crawler", testNow.Unix(), `{"queryID":"1"}`), "", http.StatusUnauthorized},
+		{"Unknown client", signedRequest("secret", "unknown", testNow.Unix(), `{}`), "", http.StatusUnauthorized},
+		{"Stale timestamp", signedRequest("secret", "crawler", testNow.Add(-time.Hour).Unix(), `{}`), "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientID, rejection := newTestGuard(config).Authorize(tt.req)
+			if tt.wantStatus == 0 {
+				if rejection != nil {
+					t.Fatalf("Authorize() rejected with %d: %s", rejection.StatusCode, rejection.Message)
+				}
+				if clientID != tt.wantClientID {
+					t.Errorf("Authorize() client = %q, want %q", clientID, tt.wantClientID)
+				}
+				return
+			}
+			if rejection == nil || rejection.StatusCode != tt.wantStatus {
+				t.Errorf("Authorize() rejection = %v, want status %d", rejection, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGuard_Authorize_restoresSignedBody(t *testing.T) {
+	g := newTestGuard(&Config{Clients: []Client{{ID: "crawler", HMACSecret: "secret"}}})
+	req := signedRequest("secret", "crawler", testNow.Unix(), `{"queryID":"1"}`)
+	if _, rejection := g.Authorize(req); rejection != nil {
+		t.Fatalf("Authorize() rejected: %s", rejection.Message)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"queryID":"1"}` {
+		t.Errorf("body after Authorize() = %q", body)
+	}
+}
+
+func TestGuard_Authorize_rateLimits(t *testing.T) {
+	g := newTestGuard(&Config{
+		Clients:         []Client{{ID: "frontend", APIKey: "key1", RatePerSecond: 1, Burst: 2}},
+		IPRatePerSecond: 10,
+		IPBurst:         3,
+	})
+	request := func(key, remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/v1/explain", nil)
+		req.Header.Set(APIKeyHeader, key)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	// The client burst allows two requests, then the client must wait a second
+	for i := 0; i < 2; i++ {
+		if _, rejection := g.Authorize(request("key1", "10.0.0.1:1234")); rejection != nil {
+			t.Fatalf("request %d rejected: %s", i, rejection.Message)
+		}
+	}
+	_, rejection := g.Authorize(request("key1", "10.0.0.2:1234"))
+	if rejection == nil || rejection.StatusCode != http.StatusTooManyRequests || rejection.RetryAfter != time.Second {
+		t.Fatalf("client over quota: rejection = %+v, want 429 retrying after 1s", rejection)
+	}
+
+	// The IP burst is three requests, even without valid credentials
+	_, rejection = g.Authorize(request("bad", "10.0.0.1:1234"))
+	if rejection == nil || rejection.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("third request from IP: rejection = %+v, want 401", rejection)
+	}
+	_, rejection = g.Authorize(request("bad", "10.0.0.1:1234"))
+	if rejection == nil || rejection.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("IP over quota: rejection = %+v, want 429", rejection)
+	}
+
+	// Tokens refill over time
+	advance(g, time.Second)
+	if _, rejection := g.Authorize(request("key1", "10.0.0.3:1234")); rejection != nil {
+		t.Errorf("request after refill rejected: %s", rejection.Message)
+	}
+}
+
+func TestGuard_Reload(t *testing.T) {
+	g := newTestGuard(&Config{Clients: []Client{{ID: "frontend", APIKey: "old"}}})
+	g.Reload(&Config{Clients: []Client{{ID: "frontend", APIKey: "new"}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/explain", nil)
+	req.Header.Set(APIKeyHeader, "old")
+	if _, rejection := g.Authorize(req); rejection == nil {
+		t.Errorf("Authorize() accepted a key removed by Reload")
+	}
+	req.Header.Set(APIKeyHeader, "new")
+	if _, rejection := g.Authorize(req); rejection != nil {
+		t.Errorf("Authorize() rejected a key added by Reload: %s", rejection.Message)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"Valid", `{"clients": [{"id": "a", "apiKey": "k", "ratePerSecond": 1, "burst": 1}], "maxQueryTerms": 10}`, false},
+		{"Malformed JSON", `{"clients": [`, true},
+		{"Missing credentials", `{"clients": [{"id": "a"}]}`, true},
+		{"Duplicate ID", `{"clients": [{"id": "a", "apiKey": "k1"}, {"id": "a", "apiKey": "k2"}]}`, true},
+		{"Duplicate key", `{"clients": [{"id": "a", "apiKey": "k"}, {"id": "b", "apiKey": "k"}]}`, true},
+		{"Rate without burst", `{"clients": [{"id": "a", "apiKey": "k", "ratePerSecond": 1}]}`, true},
+		{"Negative limit", `{"maxQueryLength": -1}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "auth.json")
+			if err := os.WriteFile(filename, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadConfig(filename); (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Number of idle buckets kept before the limiter drops those that have refilled
+const maxIdleBuckets = 10000
+
+// tokenBucket allows bursts of up to burst requests, refilled at rate tokens per second
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// limiter holds a token bucket per key, such as a client ID or an IP address
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token for the key, otherwise returning how long until a token is available
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l == nil || l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.dropFull(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+	}
+
+	// Refill the bucket for the time since the last request
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+		bucket.updated = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// dropFull removes buckets that would be full by now, as they are equivalent to new buckets
+func (l *limiter) dropFull(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
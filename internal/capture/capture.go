@@ -0,0 +1,193 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"rpi-search-ranking/internal/eventlog"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/monitoring"
+	"rpi-search-ranking/internal/ranking"
+	"sync"
+	"time"
+)
+
+// LogName is the name of the capture log in the capture directory
+const LogName = "impressions"
+
+// ActiveFile is the capture file currently being written, which retention must leave alone
+const ActiveFile = LogName + ".jsonl"
+
+var logger = logging.For("capture")
+
+// Document is a ranked document as captured
+type Document struct {
+	DocID    string           `json:"docID"`
+	Rank     int              `json:"rank"`
+	Score    float64          `json:"score"`
+	Features ranking.Features `json:"features"`
+	Team     string           `json:"team,omitempty"` // team that contributed the document of an interleaved ranking
+}
+
+// Record is a single captured ranking, one JSON line of the capture log
+type Record struct {
+	Time            time.Time          `json:"time"`
+	QueryID         string             `json:"queryID"`
+	QueryText       string             `json:"queryText"`
+	Model           string             `json:"model"`
+	ModelVersion    string             `json:"modelVersion,omitempty"`
+	Experiment      string             `json:"experiment,omitempty"`
+	BM25            ranking.BM25Params `json:"bm25"`
+	SampleRate      float64            `json:"sampleRate"` // share of rankings captured, to reweight counts
+	TotalCandidates int                `json:"totalCandidates"`
+	Documents       []Document         `json:"documents"` // ranked documents in ranked order
+}
+
+// Config configures a Writer
+type Config struct {
+	Dir        string      // directory of the capture log
+	SampleRate float64     // share of rankings captured, between 0 and 1
+	QueueSize  int         // records buffered before new ones are dropped
+	MaxBytes   int64       // size at which the capture log is rotated
+	MaxFiles   int         // rotated files kept, 0 leaves the limit to the storage manager
+	Allowed    func() bool // consulted before each capture, e.g. a storage quota, nil always allows
+}
+
+// DefaultConfig returns the capture settings used by the ranking API
+func DefaultConfig() Config {
+	return Config{
+		Dir:        "./data/captures",
+		SampleRate: 1,
+		QueueSize:  1000,
+		MaxBytes:   64 << 20,
+	}
+}
+
+// Writer samples rankings and appends them to a rotated JSONL log from a background goroutine
+type Writer struct {
+	config Config
+	log    *eventlog.Writer
+
+	mu      sync.Mutex // guards closed and sends on queue
+	closed  bool
+	queue   chan Record
+	closing chan struct{} // closed by Close to drop the records still queued
+	done    chan struct{} // closed once the last record has been written
+}
+
+// NewWriter opens the capture log in config.Dir and starts writing captures
+func NewWriter(config Config) (*Writer, error) {
+	log, err := eventlog.NewWriter(config.Dir, LogName, config.MaxBytes, config.MaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("error opening capture log: %v", err)
+	}
+	w := &Writer{
+		config:  config,
+		log:     log,
+		queue:   make(chan Record, config.QueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Capture samples a ranking and queues it without blocking. The documents are copied before Capture returns.
+func (w *Writer) Capture(c ranking.Capture) {
+	if rand.Float64() >= w.config.SampleRate {
+		return
+	}
+	if w.config.Allowed != nil && !w.config.Allowed() {
+		monitoring.Captures.Inc("skipped")
+		return
+	}
+
+	record := Record{
+		Time:            c.Time,
+		QueryID:         c.Query.Id,
+		QueryText:       c.Query.Text,
+		Model:           c.Model,
+		ModelVersion:    c.ModelVersion,
+		Experiment:      c.Experiment,
+		BM25:            c.BM25,
+		SampleRate:      w.config.SampleRate,
+		TotalCandidates: c.TotalCandidates,
+		Documents:       make([]Document, len(c.Documents)),
+	}
+	for i, doc := range c.Documents {
+		record.Documents[i] = Document{DocID: doc.DocID, Rank: doc.Rank, Score: doc.Score, Features: doc.Features}
+		if i < len(c.Teams) {
+			record.Documents[i].Team = c.Teams[i]
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		monitoring.Captures.Inc("dropped")
+		return
+	}
+	select {
+	case w.queue <- record:
+	default:
+		monitoring.Captures.Inc("dropped")
+	}
+}
+
+// Close stops accepting captures and writes the queued ones, dropping what is left when ctx is done
+func (w *Writer) Close(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	var err error
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		close(w.closing)
+		<-w.done
+		err = ctx.Err()
+	}
+	if closeErr := w.log.Close(); closeErr != nil {
+		return closeErr
+	}
+	return err
+}
+
+// run writes queued records until the queue is closed
+func (w *Writer) run() {
+	defer close(w.done)
+	for record := range w.queue {
+		select {
+		case <-w.closing:
+			monitoring.Captures.Inc("dropped")
+			continue
+		default:
+		}
+		if err := w.log.Write(record); err != nil {
+			logger.Warn("failed to write capture", "queryID", record.QueryID, "error", err)
+			monitoring.Captures.Inc("failed")
+			continue
+		}
+		monitoring.Captures.Inc("written")
+	}
+}
+
+// Read calls fn with every record of the capture log in dir, oldest first, stopping at the first error
+func Read(dir string, fn func(Record) error) error {
+	return eventlog.Read(dir, LogName, func(line []byte) error {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("error parsing capture: %v", err)
+		}
+		return fn(record)
+	})
+}
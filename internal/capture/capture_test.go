@@ -0,0 +1,169 @@
+package capture
+
+import (
+	"context"
+	"reflect"
+	"rpi-search-ranking/internal/clickmodel"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/trec"
+	"testing"
+	"time"
+)
+
+func testCapture(queryID string) ranking.Capture {
+	return ranking.Capture{
+		Time:         time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Query:        ranking.Query{Id: queryID, Text: "data science"},
+		Model:        "logistic",
+		ModelVersion: "0123456789ab",
+		Experiment:   "treatment",
+		BM25:         ranking.DefaultBM25Params,
+		Documents: ranking.Documents{
+			{DocID: "doc2", Rank: 1, Score: 2, Features: ranking.Features{BM25: 3.5}},
+			{DocID: "doc1", Rank: 2, Score: 1, Features: ranking.Features{BM25: 1.5}},
+		},
+		TotalCandidates: 5,
+	}
+}
+
+func readAll(t *testing.T, dir string) []Record {
+	t.Helper()
+	var records []Record
+	if err := Read(dir, func(r Record) error { records = append(records, r); return nil }); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return records
+}
+
+func TestWriter_Capture(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.Dir = dir
+	w, err := NewWriter(config)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	c := testCapture("q1")
+	c.Teams = []string{"A", "B"}
+	w.Capture(c)
+	// The writer copies the documents, so the ranker may reuse them after Capture returns
+	c.Documents[0].DocID = "changed"
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	w.Capture(testCapture("q2"))
+
+	records := readAll(t, dir)
+	if len(records) != 1 {
+		t.Fatalf("Read() returned %d records, want 1", len(records))
+	}
+	got := records[0]
+	if got.QueryID != "q1" || got.QueryText != "data science" || got.Model != "logistic" || got.ModelVersion != "0123456789ab" ||
+		got.Experiment != "treatment" || got.TotalCandidates != 5 || got.SampleRate != 1 || !got.Time.Equal(c.Time) {
+		t.Errorf("Read() = %+v, want the captured ranking", got)
+	}
+	if len(got.Documents) != 2 || got.Documents[0].DocID != "doc2" || got.Documents[0].Rank != 1 || got.Documents[1].Features.BM25 != 1.5 ||
+		got.Documents[0].Team != "A" || got.Documents[1].Team != "B" {
+		t.Errorf("Read() documents = %+v, want doc2 and doc1 in ranked order with their teams", got.Documents)
+	}
+}
+
+func TestWriter_Sampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		allowed    bool
+		want       int
+	}{
+		{"All", 1, true, 10},
+		{"None", 0, true, 0},
+		{"Over quota", 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := DefaultConfig()
+			config.Dir = dir
+			config.SampleRate = tt.sampleRate
+			config.Allowed = func() bool { return tt.allowed }
+			w, err := NewWriter(config)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			for i := 0; i < 10; i++ {
+				w.Capture(testCapture("q"))
+			}
+			if err := w.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got := len(readAll(t, dir)); got != tt.want {
+				t.Errorf("captured %d rankings, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.Dir = dir
+	w, err := NewWriter(config)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	older := testCapture("q1")
+	w.Capture(older)
+	newer := testCapture("q1")
+	newer.Query.Text = "Data  Science"
+	newer.Documents = ranking.Documents{
+		{DocID: "doc1", Rank: 1, Features: ranking.Features{BM25: 9}},
+		{DocID: "doc3", Rank: 2, Features: ranking.Features{BM25: 4}},
+	}
+	w.Capture(newer)
+	w.Capture(testCapture("unjudged"))
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels Labels
+		key    QueryKey
+		want   []Judged
+	}{
+		{
+			name:   "Qrels by query ID",
+			labels: QrelsLabels(trec.Qrels{"q1": {"doc1": 2, "doc2": 0, "doc4": 1}}),
+			key:    ByQueryID,
+			want: []Judged{
+				{Query: "q1", DocID: "doc1", Relevance: 2, Features: ranking.Features{BM25: 9}},
+				{Query: "q1", DocID: "doc2", Relevance: 0, Features: ranking.Features{BM25: 3.5}},
+			},
+		},
+		{
+			name:   "Clicks by normalized query text",
+			labels: ClickLabels([]clickmodel.Estimate{{Query: "data science", DocID: "doc3", Relevance: 0.7}}),
+			key:    ByQueryText,
+			want:   []Judged{{Query: "data science", DocID: "doc3", Relevance: 0.7, Features: ranking.Features{BM25: 4}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Join(dir, tt.labels, tt.key)
+			if err != nil {
+				t.Fatalf("Join() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Join() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// doc1 is more relevant than doc2, so there is one example in each order
+	dataset := Examples(tests[0].want, 10, 0.5)
+	X, Y := dataset.X, dataset.Y
+	if len(X) != 2 || len(Y) != 2 || Y[0] == Y[1] || dataset.QIDs[0] != dataset.QIDs[1] {
+		t.Errorf("Examples() = %+v, want both orders of the pair of the query", dataset)
+	}
+}
@@ -0,0 +1,136 @@
+package capture
+
+import (
+	"math"
+	"rpi-search-ranking/internal/clickmodel"
+	"rpi-search-ranking/internal/datagen"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/trec"
+	"sort"
+	"strconv"
+)
+
+// Labels holds the relevance of judged documents, keyed by query and document ID
+type Labels map[string]map[string]float64
+
+// QrelsLabels converts human judgments to labels keyed by query ID
+func QrelsLabels(qrels trec.Qrels) Labels {
+	labels := make(Labels, len(qrels))
+	for queryID, judgments := range qrels {
+		labels[queryID] = make(map[string]float64, len(judgments))
+		for docID, relevance := range judgments {
+			labels[queryID][docID] = float64(relevance)
+		}
+	}
+	return labels
+}
+
+// ClickLabels converts click model estimates to labels keyed by normalized query text
+func ClickLabels(estimates []clickmodel.Estimate) Labels {
+	labels := make(Labels)
+	for _, estimate := range estimates {
+		if labels[estimate.Query] == nil {
+			labels[estimate.Query] = make(map[string]float64)
+		}
+		labels[estimate.Query][estimate.DocID] = estimate.Relevance
+	}
+	return labels
+}
+
+// QueryKey returns the key of the labels of a captured query
+type QueryKey func(Record) string
+
+// ByQueryID matches captures to labels by query ID, as used by qrels
+func ByQueryID(record Record) string {
+	return record.QueryID
+}
+
+// ByQueryText matches captures to labels by normalized query text, as used by click labels
+func ByQueryText(record Record) string {
+	return clickmodel.NormalizeQuery(record.QueryText)
+}
+
+// Judged is a labelled document of a captured query
+type Judged struct {
+	Query     string
+	DocID     string
+	Relevance float64
+	Features  ranking.Features // features of the most recent capture of the document for the query
+}
+
+// Join reads the captures in dir and labels their documents, returning every judged query-document pair once,
+// sorted by query and document ID. Unjudged documents are skipped, since their relevance is unknown.
+func Join(dir string, labels Labels, key QueryKey) ([]Judged, error) {
+	byKey := make(map[[2]string]*Judged)
+	err := Read(dir, func(record Record) error {
+		query := key(record)
+		judgments, ok := labels[query]
+		if !ok {
+			return nil
+		}
+		for _, doc := range record.Documents {
+			relevance, ok := judgments[doc.DocID]
+			if !ok {
+				continue
+			}
+			// Captures are read oldest first, so later captures overwrite the features
+			byKey[[2]string{query, doc.DocID}] = &Judged{Query: query, DocID: doc.DocID, Relevance: relevance, Features: doc.Features}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	judged := make([]Judged, 0, len(byKey))
+	for _, j := range byKey {
+		judged = append(judged, *j)
+	}
+	sort.Slice(judged, func(i, j int) bool {
+		if judged[i].Query != judged[j].Query {
+			return judged[i].Query < judged[j].Query
+		}
+		return judged[i].DocID < judged[j].DocID
+	})
+	return judged, nil
+}
+
+// Examples turns judged documents into a dataset of pairwise examples of features.RankingSchema vectors,
+// pairing documents of the same query whose relevance differs by at least minDiff
+func Examples(judged []Judged, maxExamples int, minDiff float64) datagen.Dataset {
+	docs := make([]datagen.ScoredDocument, len(judged))
+	for i, j := range judged {
+		docs[i] = datagen.ScoredDocument{Query: j.Query, Relevance: j.Relevance, Features: j.Features}
+	}
+	return datagen.ExamplesFromScores(docs, maxExamples, minDiff)
+}
+
+// QueryGroups groups judged documents by query for listwise dataset files, with relevance rounded to the nearest
+// grade. Queries keep their ID as qid when every query ID is a number, as with TREC topics, and are otherwise
+// numbered from 1 in sorted order.
+func QueryGroups(judged []Judged) []datagen.QueryGroup {
+	numeric := true
+	for _, j := range judged {
+		if _, err := strconv.Atoi(j.Query); err != nil {
+			numeric = false
+			break
+		}
+	}
+
+	var groups []datagen.QueryGroup
+	for i, j := range judged {
+		if i == 0 || j.Query != judged[i-1].Query {
+			qid := len(groups) + 1
+			if numeric {
+				qid, _ = strconv.Atoi(j.Query)
+			}
+			groups = append(groups, datagen.QueryGroup{QID: qid})
+		}
+		group := &groups[len(groups)-1]
+		group.Relevances = append(group.Relevances, int(math.Round(j.Relevance)))
+		group.Features = append(group.Features, features.FromFeatures(j.Features))
+		group.DocIDs = append(group.DocIDs, j.DocID)
+	}
+	return groups
+}
@@ -0,0 +1,41 @@
+package clickmodel
+
+// Cascade assumes users scan top-down and stop at the first click, so only documents up to the
+// first click were examined. Its maximum likelihood estimate is closed-form and needs no EM.
+type Cascade struct {
+	relevance map[docKey]float64
+}
+
+// NewCascade creates an unfitted cascade model
+func NewCascade() *Cascade {
+	return &Cascade{relevance: make(map[docKey]float64)}
+}
+
+// Fit estimates relevance as clicks over examinations
+func (m *Cascade) Fit(sessions []Session) {
+	clicks := make(map[docKey]float64)
+	examinations := make(map[docKey]float64)
+	for _, session := range sessions {
+		last := session.firstClick()
+		if last < 0 {
+			last = len(session.DocIDs) - 1
+		}
+		for i := 0; i <= last; i++ {
+			key := docKey{session.Query, session.DocIDs[i]}
+			examinations[key]++
+			if session.Clicks[i] {
+				clicks[key]++
+			}
+		}
+	}
+
+	m.relevance = make(map[docKey]float64, len(examinations))
+	for key, n := range examinations {
+		m.relevance[key] = clicks[key] / n
+	}
+}
+
+// Relevance returns the click probability of an examined document, 0 if it was never examined
+func (m *Cascade) Relevance(query, docID string) float64 {
+	return m.relevance[docKey{query, docID}]
+}
@@ -0,0 +1,115 @@
+package clickmodel
+
+import (
+	"math"
+	"math/rand"
+	"rpi-search-ranking/internal/feedback"
+	"testing"
+)
+
+func TestCascade(t *testing.T) {
+	sessions := []Session{
+		{Query: "q", DocIDs: []string{"a", "b", "c"}, Clicks: []bool{false, true, false}},
+		{Query: "q", DocIDs: []string{"a", "b", "c"}, Clicks: []bool{false, false, false}},
+		{Query: "q", DocIDs: []string{"c", "a", "b"}, Clicks: []bool{true, false, false}},
+	}
+	m := NewCascade()
+	m.Fit(sessions)
+
+	// a was examined in the first two sessions only, c was examined in the last two and clicked once
+	tests := map[string]float64{"a": 0, "b": 0.5, "c": 0.5}
+	for docID, want := range tests {
+		if got := m.Relevance("q", docID); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Relevance(%q) = %v, want %v", docID, got, want)
+		}
+	}
+}
+
+// simulate shows two documents in both orders and clicks with the given attractiveness and examination
+// probabilities, stopping after a click with probability stop
+func simulate(attractiveness map[string]float64, examination []float64, stop float64, n int) []Session {
+	rng := rand.New(rand.NewSource(1))
+	orders := [][]string{{"good", "bad"}, {"bad", "good"}}
+	sessions := make([]Session, n)
+	for i := range sessions {
+		order := orders[i%2]
+		session := Session{Query: "q", DocIDs: order, Clicks: make([]bool, 2)}
+		for position, docID := range order {
+			if rng.Float64() >= examination[position] {
+				break
+			}
+			if rng.Float64() < attractiveness[docID] {
+				session.Clicks[position] = true
+				if rng.Float64() < stop {
+					break
+				}
+			}
+		}
+		sessions[i] = session
+	}
+	return sessions
+}
+
+func TestModels_RecoverRelevance(t *testing.T) {
+	attractiveness := map[string]float64{"good": 0.7, "bad": 0.2}
+
+	// Without stopping after clicks the sessions follow the PBM assumptions
+	pbm := NewPBM(50)
+	pbm.Fit(simulate(attractiveness, []float64{1, 0.5}, 0, 4000))
+	// Attractiveness and examination are only identified up to scale, so check click probabilities and ratios
+	if got := pbm.Relevance("q", "good") * pbm.Examination(0); math.Abs(got-0.7) > 0.05 {
+		t.Errorf("PBM click probability of good at position 1 = %v, want about 0.7", got)
+	}
+	if got := pbm.Examination(1) / pbm.Examination(0); math.Abs(got-0.5) > 0.1 {
+		t.Errorf("PBM relative examination of position 2 = %v, want about 0.5", got)
+	}
+	if got := pbm.Relevance("q", "good") / pbm.Relevance("q", "bad"); math.Abs(got-3.5) > 0.7 {
+		t.Errorf("PBM attractiveness ratio of good to bad = %v, want about 3.5", got)
+	}
+
+	sessions := simulate(attractiveness, []float64{1, 0.5}, 0.5, 4000)
+	for name, m := range map[string]Model{"PBM": pbm, "DBN": NewDBN(0.9, 50), "Cascade": NewCascade()} {
+		m.Fit(sessions)
+		if good, bad := m.Relevance("q", "good"), m.Relevance("q", "bad"); good <= bad {
+			t.Errorf("%s relevance of good = %v, not above bad = %v", name, good, bad)
+		}
+	}
+}
+
+func TestLoadSessionsAndEstimates(t *testing.T) {
+	dir := t.TempDir()
+	r, err := feedback.NewRecorder(feedback.Config{Dir: dir, MaxRecent: 10, QueueSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents := []feedback.ImpressionDocument{
+		{DocID: "b", Position: 2},
+		{DocID: "a", Position: 1},
+	}
+	r.RecordImpression(feedback.Impression{ImpressionID: "imp1", QueryID: "q1", QueryText: "RPI  Admissions", Model: "bm25", Documents: documents})
+	r.RecordImpression(feedback.Impression{ImpressionID: "imp2", QueryID: "q2", QueryText: "rpi admissions", Model: "bm25", Documents: documents})
+	r.RecordFeedback("imp1", []feedback.Interaction{{DocID: "a", Action: feedback.ActionDwell, DwellMillis: 100}})
+	// Feedback on impressions that are not in the log, such as those of another replica, makes no session
+	r.RecordFeedback("imp3", []feedback.Interaction{{DocID: "a", Action: feedback.ActionClick}})
+	r.Close()
+
+	sessions, err := LoadSessions(dir, true)
+	if err != nil {
+		t.Fatalf("LoadSessions() error = %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("LoadSessions() returned %d sessions, want 1 with feedback", len(sessions))
+	}
+	session := sessions[0]
+	if session.Query != "rpi admissions" || session.DocIDs[0] != "a" || !session.Clicks[0] || session.Clicks[1] {
+		t.Errorf("LoadSessions() = %+v, want a clicked above b", session)
+	}
+
+	sessions, _ = LoadSessions(dir, false)
+	m := NewCascade()
+	m.Fit(sessions)
+	estimates := Estimates(m, sessions, 2)
+	if len(estimates) != 2 || estimates[0].DocID != "a" || estimates[0].Impressions != 2 {
+		t.Fatalf("Estimates() = %+v, want a above b with 2 impressions each", estimates)
+	}
+}
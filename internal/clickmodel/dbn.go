@@ -0,0 +1,108 @@
+package clickmodel
+
+// DBN is the dynamic Bayesian network model. Users examine documents top-down, click attractive ones
+// (attractiveness a), are satisfied by a clicked document with probability s, and otherwise continue
+// to the next document with probability Gamma. Relevance is a*s.
+type DBN struct {
+	Gamma      float64 // continuation probability, fixed as in Chapelle and Zhang (2009)
+	Iterations int     // EM iterations
+
+	attractiveness map[docKey]float64
+	satisfaction   map[docKey]float64
+}
+
+// NewDBN creates an unfitted DBN with the given continuation probability
+func NewDBN(gamma float64, iterations int) *DBN {
+	return &DBN{
+		Gamma:          gamma,
+		Iterations:     iterations,
+		attractiveness: make(map[docKey]float64),
+		satisfaction:   make(map[docKey]float64),
+	}
+}
+
+// Fit estimates attractiveness and satisfaction by EM
+func (m *DBN) Fit(sessions []Session) {
+	m.attractiveness = make(map[docKey]float64)
+	m.satisfaction = make(map[docKey]float64)
+	for _, session := range sessions {
+		for _, docID := range session.DocIDs {
+			m.attractiveness[docKey{session.Query, docID}] = 0.5
+			m.satisfaction[docKey{session.Query, docID}] = 0.5
+		}
+	}
+
+	for iteration := 0; iteration < m.Iterations; iteration++ {
+		aNumerator := make(map[docKey]float64, len(m.attractiveness))
+		aDenominator := make(map[docKey]float64, len(m.attractiveness))
+		sNumerator := make(map[docKey]float64, len(m.satisfaction))
+		sDenominator := make(map[docKey]float64, len(m.satisfaction))
+
+		for _, session := range sessions {
+			n := len(session.DocIDs)
+			keys := make([]docKey, n)
+			a := make([]float64, n)
+			for i, docID := range session.DocIDs {
+				keys[i] = docKey{session.Query, docID}
+				a[i] = m.attractiveness[keys[i]]
+			}
+
+			// noClicks[i] is the probability of no clicks at or below i given i is examined
+			noClicks := make([]float64, n+1)
+			noClicks[n] = 1
+			for i := n - 1; i >= 0; i-- {
+				noClicks[i] = (1 - a[i]) * ((1 - m.Gamma) + m.Gamma*noClicks[i+1])
+			}
+
+			// Every document up to the last click was examined. After it, examination continues
+			// with probability continued, which is 1 for sessions without clicks.
+			last := session.lastClick()
+			continued := 1.0
+			if last >= 0 {
+				s := m.satisfaction[keys[last]]
+				continued = m.Gamma * (1 - s)
+
+				// Satisfaction is only observable at the last click, earlier clicks were followed by more clicks
+				for i := 0; i < last; i++ {
+					if session.Clicks[i] {
+						sDenominator[keys[i]]++
+					}
+				}
+				sNumerator[keys[last]] += s / (s + (1-s)*((1-m.Gamma)+m.Gamma*noClicks[last+1]))
+				sDenominator[keys[last]]++
+			}
+
+			// Probability of the observed absence of clicks after the last click
+			noLaterClicks := (1 - continued) + continued*noClicks[last+1]
+
+			// reach is the probability of examining i without clicks between the last click and i
+			reach := continued
+			for i := 0; i < n; i++ {
+				aDenominator[keys[i]]++
+				switch {
+				case session.Clicks[i]:
+					aNumerator[keys[i]]++
+				case i < last:
+					// Examined and not attractive
+				default:
+					examined := reach * noClicks[i] / noLaterClicks
+					aNumerator[keys[i]] += (1 - examined) * a[i]
+				}
+				if i > last {
+					reach *= (1 - a[i]) * m.Gamma
+				}
+			}
+		}
+
+		for key := range m.attractiveness {
+			m.attractiveness[key] = ratio(aNumerator[key], aDenominator[key], 0.5)
+			m.satisfaction[key] = ratio(sNumerator[key], sDenominator[key], 0.5)
+		}
+	}
+}
+
+// Relevance returns the probability that the document is clicked and satisfies the user once examined
+func (m *DBN) Relevance(query, docID string) float64 {
+	key := docKey{query, docID}
+	return m.attractiveness[key] * m.satisfaction[key]
+}
@@ -0,0 +1,77 @@
+package clickmodel
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Model estimates the relevance of documents to queries from position-biased clicks
+type Model interface {
+	// Fit estimates the model parameters from the sessions
+	Fit(sessions []Session)
+	// Relevance returns the estimated relevance of a document to a normalized query, between 0 and 1
+	Relevance(query, docID string) float64
+}
+
+// New returns the click model with the given name: cascade, dbn or pbm.
+// gamma is the continuation probability of dbn and iterations the number of EM iterations of dbn and pbm.
+func New(name string, gamma float64, iterations int) (Model, error) {
+	switch name {
+	case "cascade":
+		return NewCascade(), nil
+	case "dbn":
+		return NewDBN(gamma, iterations), nil
+	case "pbm":
+		return NewPBM(iterations), nil
+	}
+	return nil, fmt.Errorf("unknown click model %q", name)
+}
+
+// Estimate is the estimated relevance of a document to a query
+type Estimate struct {
+	Query       string
+	DocID       string
+	Relevance   float64
+	Impressions int // number of sessions that showed the document for the query
+}
+
+// Estimates returns the relevance of every document shown at least minImpressions times for a query,
+// sorted by query and descending relevance
+func Estimates(model Model, sessions []Session, minImpressions int) []Estimate {
+	byKey := make(map[docKey]*Estimate)
+	for _, session := range sessions {
+		for _, docID := range session.DocIDs {
+			key := docKey{session.Query, docID}
+			estimate, ok := byKey[key]
+			if !ok {
+				estimate = &Estimate{Query: session.Query, DocID: docID}
+				byKey[key] = estimate
+			}
+			estimate.Impressions++
+		}
+	}
+
+	var estimates []Estimate
+	for key, estimate := range byKey {
+		if estimate.Impressions < minImpressions {
+			continue
+		}
+		estimate.Relevance = model.Relevance(key.query, key.docID)
+		estimates = append(estimates, *estimate)
+	}
+	sort.Slice(estimates, func(i, j int) bool {
+		if estimates[i].Query != estimates[j].Query {
+			return estimates[i].Query < estimates[j].Query
+		}
+		if estimates[i].Relevance != estimates[j].Relevance {
+			return estimates[i].Relevance > estimates[j].Relevance
+		}
+		return estimates[i].DocID < estimates[j].DocID
+	})
+	return estimates
+}
+
+// ratio is a smoothed fraction used for parameter estimates, starting from prior with the weight of one observation
+func ratio(numerator, denominator, prior float64) float64 {
+	return (numerator + prior) / (denominator + 1)
+}
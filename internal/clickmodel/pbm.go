@@ -0,0 +1,77 @@
+package clickmodel
+
+// PBM is the position-based model: a document is clicked if its position is examined, with probability
+// gamma[position], and it is attractive, with probability alpha[query, document]
+type PBM struct {
+	Iterations int // EM iterations
+
+	alpha map[docKey]float64
+	gamma []float64
+}
+
+// NewPBM creates an unfitted position-based model
+func NewPBM(iterations int) *PBM {
+	return &PBM{Iterations: iterations, alpha: make(map[docKey]float64)}
+}
+
+// Fit estimates attractiveness and examination probabilities by EM
+func (m *PBM) Fit(sessions []Session) {
+	m.alpha = make(map[docKey]float64)
+	maxLength := 0
+	for _, session := range sessions {
+		maxLength = max(maxLength, len(session.DocIDs))
+		for _, docID := range session.DocIDs {
+			m.alpha[docKey{session.Query, docID}] = 0.5
+		}
+	}
+	m.gamma = make([]float64, maxLength)
+	for i := range m.gamma {
+		m.gamma[i] = 0.5
+	}
+
+	for iteration := 0; iteration < m.Iterations; iteration++ {
+		alphaNumerator := make(map[docKey]float64, len(m.alpha))
+		alphaDenominator := make(map[docKey]float64, len(m.alpha))
+		gammaNumerator := make([]float64, maxLength)
+		gammaDenominator := make([]float64, maxLength)
+
+		for _, session := range sessions {
+			for i, docID := range session.DocIDs {
+				key := docKey{session.Query, docID}
+				alpha, gamma := m.alpha[key], m.gamma[i]
+
+				// A click means the document was examined and attractive. Without a click, either was not.
+				attractive, examined := 1.0, 1.0
+				if !session.Clicks[i] {
+					noClick := 1 - gamma*alpha
+					attractive = (1 - gamma) * alpha / noClick
+					examined = gamma * (1 - alpha) / noClick
+				}
+				alphaNumerator[key] += attractive
+				alphaDenominator[key]++
+				gammaNumerator[i] += examined
+				gammaDenominator[i]++
+			}
+		}
+
+		for key := range m.alpha {
+			m.alpha[key] = ratio(alphaNumerator[key], alphaDenominator[key], 0.5)
+		}
+		for i := range m.gamma {
+			m.gamma[i] = ratio(gammaNumerator[i], gammaDenominator[i], 0.5)
+		}
+	}
+}
+
+// Relevance returns the attractiveness of the document
+func (m *PBM) Relevance(query, docID string) float64 {
+	return m.alpha[docKey{query, docID}]
+}
+
+// Examination returns the examination probability of a 0-based position
+func (m *PBM) Examination(position int) float64 {
+	if position < 0 || position >= len(m.gamma) {
+		return 0
+	}
+	return m.gamma[position]
+}
@@ -0,0 +1,106 @@
+package clickmodel
+
+import (
+	"rpi-search-ranking/internal/feedback"
+	"sort"
+	"strings"
+)
+
+// Session is a single impression with the clicks observed on it
+type Session struct {
+	Query  string   // normalized query text, documents are judged per query
+	DocIDs []string // documents in the order they were shown
+	Clicks []bool   // whether each document was clicked
+}
+
+// docKey identifies a document shown for a query
+type docKey struct {
+	query string
+	docID string
+}
+
+// NormalizeQuery maps query texts that differ only in case and spacing to the same query
+func NormalizeQuery(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// LoadSessions builds sessions from the impressions and feedback logged in dir. Clicks and dwells count as clicks.
+// With requireFeedback, impressions without any feedback are skipped, since their clicks were never reported.
+func LoadSessions(dir string, requireFeedback bool) ([]Session, error) {
+	impressions := make(map[string]feedback.Event)
+	clicks := make(map[string]map[string]bool) // impression ID -> clicked document IDs
+	err := feedback.ReadEvents(dir, func(event feedback.Event) error {
+		switch event.Type {
+		case feedback.EventImpression:
+			impressions[event.ImpressionID] = event
+		case feedback.EventFeedback:
+			if clicks[event.ImpressionID] == nil {
+				clicks[event.ImpressionID] = make(map[string]bool)
+			}
+			for _, interaction := range event.Interactions {
+				if interaction.Action == feedback.ActionClick || interaction.Action == feedback.ActionDwell {
+					clicks[event.ImpressionID][interaction.DocID] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort impression IDs so sessions do not depend on map order
+	impressionIDs := make([]string, 0, len(impressions))
+	for impressionID := range impressions {
+		impressionIDs = append(impressionIDs, impressionID)
+	}
+	sort.Strings(impressionIDs)
+
+	var sessions []Session
+	for _, impressionID := range impressionIDs {
+		clicked, ok := clicks[impressionID]
+		if requireFeedback && !ok {
+			continue
+		}
+		sessions = append(sessions, newSession(impressions[impressionID], clicked))
+	}
+	return sessions, nil
+}
+
+func newSession(impression feedback.Event, clicked map[string]bool) Session {
+	documents := append([]feedback.ImpressionDocument(nil), impression.Documents...)
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].Position < documents[j].Position
+	})
+
+	session := Session{
+		Query:  NormalizeQuery(impression.QueryText),
+		DocIDs: make([]string, len(documents)),
+		Clicks: make([]bool, len(documents)),
+	}
+	for i, doc := range documents {
+		session.DocIDs[i] = doc.DocID
+		session.Clicks[i] = clicked[doc.DocID]
+	}
+	return session
+}
+
+// lastClick returns the index of the last clicked document, or -1 without clicks
+func (s Session) lastClick() int {
+	for i := len(s.Clicks) - 1; i >= 0; i-- {
+		if s.Clicks[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstClick returns the index of the first clicked document, or -1 without clicks
+func (s Session) firstClick() int {
+	for i, clicked := range s.Clicks {
+		if clicked {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,9 +3,11 @@ package datagen
 import (
 	"encoding/csv"
 	"encoding/gob"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"rpi-search-ranking/internal/features"
 	"rpi-search-ranking/internal/ranking"
 	"strconv"
 )
@@ -66,7 +68,46 @@ func LoadData(filename string, X, Y interface{}) error {
 	return nil
 }
 
-func SaveDataToCSV(filename string, X []ranking.Features, Y []int) error {
+// Dataset is a set of pairwise examples with the schema naming the entries of their feature vectors
+type Dataset struct {
+	Schema features.Schema
+	X      []features.Vector
+	Y      []int
+	QIDs   []int // query of each example, nil for datasets written before queries were kept
+}
+
+// SaveDataset saves a dataset to a gob file
+func SaveDataset(filename string, dataset Dataset) error {
+	return SaveData(filename, dataset.Schema, datasetExamples{X: dataset.X, Y: dataset.Y, QIDs: dataset.QIDs})
+}
+
+// datasetExamples holds the examples of a dataset file, written after its schema
+type datasetExamples struct {
+	X    []features.Vector
+	Y    []int
+	QIDs []int
+}
+
+// LoadDataset loads a dataset saved by SaveDataset. Files written by SaveData with []ranking.Features examples,
+// the format before feature vectors, are converted to RankingSchema vectors.
+func LoadDataset(filename string) (Dataset, error) {
+	var schema features.Schema
+	var examples datasetExamples
+	err := LoadData(filename, &schema, &examples)
+	if err == nil {
+		return Dataset{Schema: schema, X: examples.X, Y: examples.Y, QIDs: examples.QIDs}, nil
+	}
+
+	var legacyX []ranking.Features
+	var legacyY []int
+	if legacyErr := LoadData(filename, &legacyX, &legacyY); legacyErr != nil {
+		return Dataset{}, fmt.Errorf("error loading dataset %s: %v", filename, err)
+	}
+	return Dataset{Schema: features.RankingSchema(), X: features.FromFeaturesList(legacyX), Y: legacyY}, nil
+}
+
+// SaveDataToCSV saves examples to a CSV file with a column per schema feature followed by the label Y
+func SaveDataToCSV(filename string, schema features.Schema, X []features.Vector, Y []int) error {
 	// Ensure the directory exists
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
@@ -90,49 +131,26 @@ func SaveDataToCSV(filename string, X []ranking.Features, Y []int) error {
 	defer writer.Flush()
 
 	// Write CSV header (columns)
-	header := []string{
-		"CoveredQueryTermNumber", "CoveredQueryTermRatio",
-		"SumTermFrequency", "MinTermFrequency", "MaxTermFrequency", "MeanTermFrequency", "VarianceTermFrequency",
-		"StreamLength", "SumStreamLengthNormalizedTF", "MinStreamLengthNormalizedTF", "MaxStreamLengthNormalizedTF",
-		"MeanStreamLengthNormalizedTF", "VarianceStreamLengthNormalizedTF",
-		"SumTFIDF", "MinTFIDF", "MaxTFIDF", "MeanTFIDF", "VarianceTFIDF",
-		"BM25", "NumSlashesInURL", "LengthOfURL",
-		"InlinkCount", "OutlinkCount", "PageRank", "Y",
-	}
+	header := append(append([]string(nil), schema.Names...), "Y")
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
-	// Write data rows
+	// Write data rows, with integer features such as counts written as integers
+	types := make([]ranking.FeatureType, schema.Len())
+	for j := range types {
+		types[j] = schema.Type(j)
+	}
 	for i := 0; i < len(X); i++ {
-		// Convert Features values to strings
-		record := []string{
-			strconv.Itoa(X[i].CoveredQueryTermNumber),
-			strconv.FormatFloat(X[i].CoveredQueryTermRatio, 'f', 6, 64),
-			strconv.Itoa(X[i].SumTermFrequency),
-			strconv.Itoa(X[i].MinTermFrequency),
-			strconv.Itoa(X[i].MaxTermFrequency),
-			strconv.FormatFloat(X[i].MeanTermFrequency, 'f', 6, 64),
-			strconv.FormatFloat(X[i].VarianceTermFrequency, 'f', 6, 64),
-			strconv.Itoa(X[i].StreamLength),
-			strconv.FormatFloat(X[i].SumStreamLengthNormalizedTF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MinStreamLengthNormalizedTF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MaxStreamLengthNormalizedTF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MeanStreamLengthNormalizedTF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].VarianceStreamLengthNormalizedTF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].SumTFIDF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MinTFIDF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MaxTFIDF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].MeanTFIDF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].VarianceTFIDF, 'f', 6, 64),
-			strconv.FormatFloat(X[i].BM25, 'f', 6, 64),
-			strconv.Itoa(X[i].NumSlashesInURL),
-			strconv.Itoa(X[i].LengthOfURL),
-			strconv.Itoa(X[i].InlinkCount),
-			strconv.Itoa(X[i].OutlinkCount),
-			strconv.FormatFloat(X[i].PageRank, 'f', 6, 64),
-			strconv.Itoa(Y[i]),
+		record := make([]string, 0, len(X[i])+1)
+		for j, value := range X[i] {
+			if types[j] == ranking.IntFeature {
+				record = append(record, strconv.Itoa(int(value)))
+			} else {
+				record = append(record, strconv.FormatFloat(value, 'f', 6, 64))
+			}
 		}
+		record = append(record, strconv.Itoa(Y[i]))
 
 		// Write the record to the CSV file
 		if err := writer.Write(record); err != nil {
@@ -140,5 +158,6 @@ func SaveDataToCSV(filename string, X []ranking.Features, Y []int) error {
 		}
 	}
 
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
@@ -0,0 +1,28 @@
+package datagen
+
+import (
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestSaveDataToCSV(t *testing.T) {
+	schema, err := features.ParseSchema("15,110,128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "train.csv")
+	if err := SaveDataToCSV(filename, schema, []features.Vector{{100, 2.5, 3}}, []int{1}); err != nil {
+		t.Fatalf("SaveDataToCSV() error = %v", err)
+	}
+
+	// Counts are written as integers, like the Features fields they come from
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "StreamLength,BM25,InlinkCount,Y\n100,2.500000,3,1\n"; string(data) != want {
+		t.Errorf("SaveDataToCSV() wrote %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,150 @@
+package datagen
+
+import (
+	"fmt"
+	"rpi-search-ranking/internal/features"
+	"strconv"
+	"strings"
+)
+
+// Format is a learning-to-rank dataset format. All supported formats use the SVMlight line layout,
+// "relevance qid:<qid> <id>:<value> ... # comment", and differ in their feature numbering.
+type Format struct {
+	Name        string
+	NumFeatures int                    // highest feature ID, 0 if any ID is allowed
+	RequireQID  bool                   // whether every line must have a qid
+	all         func() features.Schema // schema of every feature, nil if the features are not fixed
+}
+
+var (
+	// MSLR is the format of MSLR-WEB10K and MSLR-WEB30K
+	MSLR = Format{Name: "mslr", NumFeatures: features.NumMSLRFeatures, RequireQID: true, all: features.MSLRSchema}
+	// LETOR is the format of LETOR 4.0 (MQ2007 and MQ2008), with "#docid = <docid> ..." comments
+	LETOR = Format{Name: "letor", NumFeatures: features.NumLETORFeatures, RequireQID: true, all: features.LETORSchema}
+	// Yahoo is the format of the Yahoo! Learning to Rank Challenge datasets, with 700 anonymous features
+	Yahoo = Format{Name: "yahoo", NumFeatures: 700, RequireQID: true, all: func() features.Schema { return features.NumberedSchema(700) }}
+	// SVMlight is the generic SVMlight and RankLib format. Lines without a qid belong to query 0.
+	SVMlight = Format{Name: "svmlight"}
+)
+
+// Formats lists the supported dataset formats
+var Formats = []Format{MSLR, LETOR, Yahoo, SVMlight}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if format.Name == name {
+			return format, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown dataset format %q", name)
+}
+
+// Schema returns the schema selected by a flag value for files of the format: "all" for every feature, "ranking" for
+// the features computed by the ranker (MSLR only), or a comma separated list of feature IDs and ranges
+func (f Format) Schema(value string) (features.Schema, error) {
+	if f.Name == MSLR.Name {
+		return features.ParseSchema(value)
+	}
+	switch value {
+	case "", "ranking":
+		return features.Schema{}, fmt.Errorf("the ranking features are MSLR features, list the %s feature IDs to keep", f.Name)
+	case "all":
+		if f.all == nil {
+			return features.Schema{}, fmt.Errorf("%s files have no fixed features, list the feature IDs to keep", f.Name)
+		}
+		return f.all(), nil
+	}
+
+	ids, err := features.ParseIDs(value)
+	if err != nil {
+		return features.Schema{}, err
+	}
+	if f.all != nil {
+		return f.all().Subset(ids)
+	}
+	return features.NumberedSchema(ids[len(ids)-1]).Subset(ids)
+}
+
+// Record is a document of a dataset line
+type Record struct {
+	Relevance int
+	QID       int
+	DocID     string // from the comment, empty if the line has none
+	Features  features.Sparse
+}
+
+// ParseLine parses a dataset line of the format, keeping every feature
+func (f Format) ParseLine(line string) (Record, error) {
+	var record Record
+	data, comment, _ := strings.Cut(line, "#")
+	parts := strings.Fields(data)
+	if len(parts) < 1 || (f.RequireQID && len(parts) < 2) {
+		return record, fmt.Errorf("invalid line: %q", line)
+	}
+
+	// First column is the relevance label
+	relevance, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return record, err
+	}
+	record.Relevance = relevance
+	parts = parts[1:]
+
+	// Parse the query ID (qid:X)
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "qid:") {
+		if record.QID, err = strconv.Atoi(strings.TrimPrefix(parts[0], "qid:")); err != nil {
+			return record, err
+		}
+		parts = parts[1:]
+	} else if f.RequireQID {
+		return record, fmt.Errorf("invalid query ID format: %s", parts[0])
+	}
+
+	// Iterate over the feature columns, which are in ascending ID order
+	record.Features.IDs = make([]int, 0, len(parts))
+	record.Features.Values = make([]float64, 0, len(parts))
+	for _, part := range parts {
+		id, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return record, fmt.Errorf("invalid feature format: %s", part)
+		}
+		featureID, err := strconv.Atoi(id)
+		if err != nil {
+			return record, err
+		}
+		if featureID < 1 || (f.NumFeatures > 0 && featureID > f.NumFeatures) {
+			return record, fmt.Errorf("feature ID %d is out of range for %s files", featureID, f.Name)
+		}
+		if n := len(record.Features.IDs); n > 0 && featureID <= record.Features.IDs[n-1] {
+			return record, fmt.Errorf("feature IDs are not ascending: %s", part)
+		}
+		featureValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return record, fmt.Errorf("failed to parse feature value as float: %s", part)
+		}
+		record.Features.IDs = append(record.Features.IDs, featureID)
+		record.Features.Values = append(record.Features.Values, featureValue)
+	}
+
+	record.DocID = parseDocID(comment)
+	return record, nil
+}
+
+// parseDocID returns the document ID of a line comment, given as "docid = <docid>" in LETOR files or as the first
+// word of the comment, as written by many SVMlight tools
+func parseDocID(comment string) string {
+	fields := strings.Fields(comment)
+	for i, field := range fields {
+		if field == "docid" && i+2 < len(fields) && fields[i+1] == "=" {
+			return fields[i+2]
+		}
+		if docID, ok := strings.CutPrefix(field, "docid="); ok && docID != "" {
+			return docID
+		}
+	}
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "docid") {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package datagen
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestFormat_ParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		line    string
+		want    Record
+		wantErr bool
+	}{
+		{
+			name:   "mslr",
+			format: MSLR,
+			line:   "2 qid:10 1:3 110:12.5 136:0",
+			want:   Record{Relevance: 2, QID: 10, Features: features.Sparse{IDs: []int{1, 110, 136}, Values: []float64{3, 12.5, 0}}},
+		},
+		{
+			name:   "letor",
+			format: LETOR,
+			line:   "1 qid:10032 1:0.056537 46:0.076923 #docid = GX029-35-5894638 inc = 0.0119 prob = 0.1398",
+			want:   Record{Relevance: 1, QID: 10032, DocID: "GX029-35-5894638", Features: features.Sparse{IDs: []int{1, 46}, Values: []float64{0.056537, 0.076923}}},
+		},
+		{
+			name:   "yahoo",
+			format: Yahoo,
+			line:   "4 qid:1 11:0.5 700:1",
+			want:   Record{Relevance: 4, QID: 1, Features: features.Sparse{IDs: []int{11, 700}, Values: []float64{0.5, 1}}},
+		},
+		{
+			name:   "svmlight without qid",
+			format: SVMlight,
+			line:   "-1 3:1 2000:0.25 # doc-7",
+			want:   Record{Relevance: -1, DocID: "doc-7", Features: features.Sparse{IDs: []int{3, 2000}, Values: []float64{1, 0.25}}},
+		},
+		{name: "letor feature out of range", format: LETOR, line: "0 qid:1 47:1", wantErr: true},
+		{name: "mslr without qid", format: MSLR, line: "0 1:1", wantErr: true},
+		{name: "descending IDs", format: SVMlight, line: "0 qid:1 5:1 4:1", wantErr: true},
+		{name: "bad value", format: MSLR, line: "0 qid:1 1:x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.ParseLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Schema(t *testing.T) {
+	if schema, err := LETOR.Schema("all"); err != nil || schema.Len() != features.NumLETORFeatures || schema.Names[24] != "BM25" {
+		t.Errorf("LETOR.Schema(all) = %v, %v, want 46 features with BM25 as feature 25", schema, err)
+	}
+	if schema, err := SVMlight.Schema("2,5-6"); err != nil || !reflect.DeepEqual(schema.IDs, []int{2, 5, 6}) || schema.Names[0] != "Feature2" {
+		t.Errorf("SVMlight.Schema(2,5-6) = %v, %v", schema, err)
+	}
+	for _, value := range []string{"ranking", "0"} {
+		if _, err := Yahoo.Schema(value); err == nil {
+			t.Errorf("Yahoo.Schema(%s) succeeded", value)
+		}
+	}
+	if _, err := SVMlight.Schema("all"); err == nil {
+		t.Errorf("SVMlight.Schema(all) succeeded")
+	}
+}
+
+func TestSVMlightWriter_RoundTrip(t *testing.T) {
+	// The writer keeps dataset IDs in ascending order whatever the schema order
+	schema := features.Schema{IDs: []int{25, 1}, Names: []string{"BM25", "SumTermFrequencyBody"}}
+	groups := []QueryGroup{
+		{QID: 7, Relevances: []int{2, 0}, Features: []features.Vector{{1.5, 3}, {0, 1}}, DocIDs: []string{"a", "b"}},
+		{QID: 9, Relevances: []int{1}, Features: []features.Vector{{0.25, 0}}, DocIDs: []string{""}},
+	}
+
+	var b bytes.Buffer
+	w := NewSVMlightWriter(&b, schema)
+	for _, group := range groups {
+		if err := w.WriteGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteExample(features.Vector{-1, 2}, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "2 qid:7 1:3 25:1.5 #docid = a\n0 qid:7 1:1 25:0 #docid = b\n1 qid:9 1:0 25:0.25\n-1 1:2 25:-1\n"
+	if b.String() != want {
+		t.Fatalf("written lines = %q, want %q", b.String(), want)
+	}
+
+	// Reading the groups back with the LETOR format restores them
+	path := filepath.Join(t.TempDir(), "groups.txt")
+	if err := SaveQueryGroups(path, schema, groups); err != nil {
+		t.Fatal(err)
+	}
+	var got []QueryGroup
+	err := StreamQueryGroups(context.Background(), path, schema, StreamOptions{Format: LETOR}, func(group QueryGroup) error {
+		got = append(got, group)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamQueryGroups() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, groups) {
+		t.Errorf("read back %+v, want %+v", got, groups)
+	}
+}
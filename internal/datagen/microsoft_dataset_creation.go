@@ -1,256 +1,190 @@
 package datagen
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"log"
 	"math"
 	"math/rand"
-	"os"
-	"strconv"
-	"strings"
 
+	"rpi-search-ranking/internal/features"
 	"rpi-search-ranking/internal/ranking"
 )
 
-func CreateExamples(filePath string, maxExamples, minDiff int) ([]ranking.Features, []int, error) {
-	relevances, qids, features, err := loadDataset(filePath)
+// CreateExamples creates a shuffled dataset of pairwise examples with the features of the schema from a dataset file
+// of the format in options (MSLR by default), pairing documents of the same query whose relevance differs by at least
+// minDiff. The file is streamed one query at a time and pairs are sampled online, so memory is bounded by
+// maxExamples rather than by the size of the file.
+func CreateExamples(ctx context.Context, filePath string, schema features.Schema, maxExamples, minDiff int, options StreamOptions) (Dataset, error) {
+	sampler := newPairSampler(maxExamples, float64(minDiff))
+	err := StreamQueryGroups(ctx, filePath, schema, options, func(group QueryGroup) error {
+		sampler.add(group.QID, relevanceScores(group.Relevances), group.Features)
+		return nil
+	})
 	if err != nil {
-		return nil, nil, err
+		return Dataset{}, err
 	}
 
-	X, Y := createComparisons(relevances, qids, features, maxExamples, minDiff)
+	dataset := sampler.dataset(schema)
 
-	if len(Y) < maxExamples {
-		return X, Y, fmt.Errorf("error: Not enough examples in dataset, found %v, expected %v", len(Y), maxExamples)
+	if len(dataset.Y) < maxExamples {
+		return dataset, fmt.Errorf("error: Not enough examples in dataset, found %v, expected %v", len(dataset.Y), maxExamples)
 	}
 
-	shuffleData(X, Y)
+	shuffleData(dataset)
 
-	return X, Y, nil
+	return dataset, nil
 }
 
 // shuffleData Helper function to shuffle the data
-func shuffleData(X []ranking.Features, Y []int) {
-	for i := len(X) - 1; i > 0; i-- {
+func shuffleData(dataset Dataset) {
+	for i := len(dataset.X) - 1; i > 0; i-- {
 		j := rand.Intn(i + 1)
-		X[i], X[j] = X[j], X[i]
-		Y[i], Y[j] = Y[j], Y[i]
+		dataset.X[i], dataset.X[j] = dataset.X[j], dataset.X[i]
+		dataset.Y[i], dataset.Y[j] = dataset.Y[j], dataset.Y[i]
+		dataset.QIDs[i], dataset.QIDs[j] = dataset.QIDs[j], dataset.QIDs[i]
 	}
 }
 
-func parseLine(line string) (relevance int, qid int, features ranking.Features, err error) {
-	// Split the line into components
-	parts := strings.Fields(line)
-
-	// First column is the relevance label
-	relevance, err = strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, ranking.Features{}, err
-	}
+// QueryGroup holds the documents of a single query in dataset order
+type QueryGroup struct {
+	QID        int
+	Relevances []int
+	Features   []features.Vector
+	DocIDs     []string // document IDs of the line comments, empty strings if the format has none
+}
 
-	// Parse the query ID (qid:X)
-	qidParts := strings.Split(parts[1], ":")
-	if len(qidParts) != 2 || qidParts[0] != "qid" {
-		return 0, 0, ranking.Features{}, fmt.Errorf("invalid query ID format: %s", parts[1])
-	}
-	qid, err = strconv.Atoi(qidParts[1])
+// LoadQueryGroups loads a dataset file of the format with the features of the schema and groups its documents by
+// query, in file order. Use StreamQueryGroups to process large files one query at a time.
+func LoadQueryGroups(filePath string, format Format, schema features.Schema) ([]QueryGroup, error) {
+	var groups []QueryGroup
+	err := StreamQueryGroups(context.Background(), filePath, schema, StreamOptions{Format: format}, func(group QueryGroup) error {
+		groups = append(groups, group)
+		return nil
+	})
 	if err != nil {
-		return 0, 0, ranking.Features{}, err
+		return nil, err
 	}
-
-	// Create the Features struct
-	features = ranking.Features{}
-
-	// Iterate over the feature columns (1:136)
-	for _, part := range parts[2:] {
-		featureParts := strings.Split(part, ":")
-		if len(featureParts) != 2 {
-			return 0, 0, ranking.Features{}, fmt.Errorf("invalid feature format: %s", part)
-		}
-		featureID, err := strconv.Atoi(featureParts[0])
-		if err != nil {
-			return 0, 0, ranking.Features{}, err
-		}
-		featureValue, err := strconv.ParseFloat(featureParts[1], 64)
-		if err != nil {
-			return 0, 0, ranking.Features{}, fmt.Errorf("failed to parse feature value as float: %s", part)
-		}
-
-		// Map the feature values to the appropriate fields in the Features struct
-		switch featureID {
-		case 5:
-			features.CoveredQueryTermNumber = int(featureValue)
-		case 10:
-			features.CoveredQueryTermRatio = featureValue
-		case 15:
-			features.StreamLength = int(featureValue)
-		case 25:
-			features.SumTermFrequency = int(featureValue)
-		case 30:
-			features.MinTermFrequency = int(featureValue)
-		case 35:
-			features.MaxTermFrequency = int(featureValue)
-		case 40:
-			features.MeanTermFrequency = featureValue
-		case 45:
-			features.VarianceTermFrequency = featureValue
-		case 50:
-			features.SumStreamLengthNormalizedTF = featureValue
-		case 55:
-			features.MinStreamLengthNormalizedTF = featureValue
-		case 60:
-			features.MaxStreamLengthNormalizedTF = featureValue
-		case 65:
-			features.MeanStreamLengthNormalizedTF = featureValue
-		case 70:
-			features.VarianceStreamLengthNormalizedTF = featureValue
-		case 75:
-			features.SumTFIDF = featureValue
-		case 80:
-			features.MinTFIDF = featureValue
-		case 85:
-			features.MaxTFIDF = featureValue
-		case 90:
-			features.MeanTFIDF = featureValue
-		case 95:
-			features.VarianceTFIDF = featureValue
-		case 110:
-			features.BM25 = featureValue
-		case 126:
-			features.NumSlashesInURL = int(featureValue)
-		case 127:
-			features.LengthOfURL = int(featureValue)
-		case 128:
-			features.InlinkCount = int(featureValue)
-		case 129:
-			features.OutlinkCount = int(featureValue)
-		case 130:
-			features.PageRank = featureValue
-		}
-	}
-
-	return relevance, qid, features, nil
+	return groups, nil
 }
 
-func loadDataset(filePath string) ([]int, []int, []ranking.Features, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			log.Printf("warning: failed to close file: %v\n", err)
-		}
-	}(file)
+// CreateScoredExamples creates a shuffled dataset in the format of CreateExamples from vectors of the schema with
+// real-valued relevance scores, such as click model estimates, pairing documents of the same query whose scores
+// differ by at least minDiff
+func CreateScoredExamples(schema features.Schema, scores []float64, qids []int, vectors []features.Vector, maxExamples int, minDiff float64) Dataset {
+	dataset := createScoredComparisons(scores, qids, vectors, maxExamples, minDiff).dataset(schema)
+	shuffleData(dataset)
+	return dataset
+}
 
-	var relevances []int
-	var qids []int
-	var featureVectors []ranking.Features
+// ScoredDocument is a document of a query with a real-valued relevance score and the features the ranker computed
+type ScoredDocument struct {
+	Query     string
+	Relevance float64
+	Features  ranking.Features
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		relevance, qid, features, err := parseLine(line)
-		if err != nil {
-			return nil, nil, nil, err
+// ExamplesFromScores turns scored documents into a dataset of pairwise examples of features.RankingSchema vectors,
+// pairing documents of the same query whose relevance differs by at least minDiff. Queries are numbered in order of
+// first appearance.
+func ExamplesFromScores(docs []ScoredDocument, maxExamples int, minDiff float64) Dataset {
+	scores := make([]float64, len(docs))
+	qids := make([]int, len(docs))
+	vectors := make([]features.Vector, len(docs))
+	queryIDs := make(map[string]int)
+	for i, doc := range docs {
+		qid, ok := queryIDs[doc.Query]
+		if !ok {
+			qid = len(queryIDs)
+			queryIDs[doc.Query] = qid
 		}
-
-		relevances = append(relevances, relevance)
-		qids = append(qids, qid)
-		featureVectors = append(featureVectors, features)
+		scores[i] = doc.Relevance
+		qids[i] = qid
+		vectors[i] = features.FromFeatures(doc.Features)
 	}
+	return CreateScoredExamples(features.RankingSchema(), scores, qids, vectors, maxExamples, minDiff)
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, nil, nil, err
+// relevanceScores converts relevance grades to scores for pair sampling
+func relevanceScores(relevances []int) []float64 {
+	scores := make([]float64, len(relevances))
+	for i, relevance := range relevances {
+		scores[i] = float64(relevance)
 	}
-
-	return relevances, qids, featureVectors, nil
+	return scores
 }
 
-func createComparisons(relevances []int, qids []int, features []ranking.Features, maxExamples, minDiff int) ([]ranking.Features, []int) {
-	var pairwiseFeatures []ranking.Features
-	var labels []int
-
+func createScoredComparisons(scores []float64, qids []int, vectors []features.Vector, maxExamples int, minDiff float64) *pairSampler {
 	// Group documents by QID
+	var order []int
 	qidGroups := make(map[int][]int) // QID -> indices
 	for i, qid := range qids {
+		if _, ok := qidGroups[qid]; !ok {
+			order = append(order, qid)
+		}
 		qidGroups[qid] = append(qidGroups[qid], i)
 	}
 
-	// Reservoir to hold pairwise examples
-	type example struct {
-		feature ranking.Features
-		label   int
+	sampler := newPairSampler(maxExamples, minDiff)
+	for _, qid := range order {
+		indices := qidGroups[qid]
+		groupScores := make([]float64, len(indices))
+		groupVectors := make([]features.Vector, len(indices))
+		for i, index := range indices {
+			groupScores[i] = scores[index]
+			groupVectors[i] = vectors[index]
+		}
+		sampler.add(qid, groupScores, groupVectors)
 	}
-	reservoir := make([]example, 0, maxExamples)
-	exampleCount := 0
+	return sampler
+}
 
-	// Generate pairwise comparisons for each QID
-	for _, indices := range qidGroups {
-		n := len(indices)
-		for i := 0; i < n; i++ {
-			for j := 0; j < n; j++ {
-				if i == j || math.Abs(float64(relevances[indices[i]]-relevances[indices[j]])) < float64(minDiff) {
-					continue // Skip same document or less than min relevance difference
-				}
+// pairSampler reservoir samples pairwise examples from one query at a time
+type pairSampler struct {
+	maxExamples  int
+	minDiff      float64
+	exampleCount int // pairs seen so far
+	X            []features.Vector
+	Y            []int
+	QIDs         []int
+}
 
-				// Compute pairwise features
-				diff := ranking.Features{
-					CoveredQueryTermNumber:           features[indices[i]].CoveredQueryTermNumber - features[indices[j]].CoveredQueryTermNumber,
-					CoveredQueryTermRatio:            features[indices[i]].CoveredQueryTermRatio - features[indices[j]].CoveredQueryTermRatio,
-					SumTermFrequency:                 features[indices[i]].SumTermFrequency - features[indices[j]].SumTermFrequency,
-					MinTermFrequency:                 features[indices[i]].MinTermFrequency - features[indices[j]].MinTermFrequency,
-					MaxTermFrequency:                 features[indices[i]].MaxTermFrequency - features[indices[j]].MaxTermFrequency,
-					MeanTermFrequency:                features[indices[i]].MeanTermFrequency - features[indices[j]].MeanTermFrequency,
-					VarianceTermFrequency:            features[indices[i]].VarianceTermFrequency - features[indices[j]].VarianceTermFrequency,
-					StreamLength:                     features[indices[i]].StreamLength - features[indices[j]].StreamLength,
-					SumStreamLengthNormalizedTF:      features[indices[i]].SumStreamLengthNormalizedTF - features[indices[j]].SumStreamLengthNormalizedTF,
-					MinStreamLengthNormalizedTF:      features[indices[i]].MinStreamLengthNormalizedTF - features[indices[j]].MinStreamLengthNormalizedTF,
-					MaxStreamLengthNormalizedTF:      features[indices[i]].MaxStreamLengthNormalizedTF - features[indices[j]].MaxStreamLengthNormalizedTF,
-					MeanStreamLengthNormalizedTF:     features[indices[i]].MeanStreamLengthNormalizedTF - features[indices[j]].MeanStreamLengthNormalizedTF,
-					VarianceStreamLengthNormalizedTF: features[indices[i]].VarianceStreamLengthNormalizedTF - features[indices[j]].VarianceStreamLengthNormalizedTF,
-					SumTFIDF:                         features[indices[i]].SumTFIDF - features[indices[j]].SumTFIDF,
-					MinTFIDF:                         features[indices[i]].MinTFIDF - features[indices[j]].MinTFIDF,
-					MaxTFIDF:                         features[indices[i]].MaxTFIDF - features[indices[j]].MaxTFIDF,
-					MeanTFIDF:                        features[indices[i]].MeanTFIDF - features[indices[j]].MeanTFIDF,
-					VarianceTFIDF:                    features[indices[i]].VarianceTFIDF - features[indices[j]].VarianceTFIDF,
-					BM25:                             features[indices[i]].BM25 - features[indices[j]].BM25,
-					NumSlashesInURL:                  features[indices[i]].NumSlashesInURL - features[indices[j]].NumSlashesInURL,
-					LengthOfURL:                      features[indices[i]].LengthOfURL - features[indices[j]].LengthOfURL,
-					InlinkCount:                      features[indices[i]].InlinkCount - features[indices[j]].InlinkCount,
-					OutlinkCount:                     features[indices[i]].OutlinkCount - features[indices[j]].OutlinkCount,
-					PageRank:                         features[indices[i]].PageRank - features[indices[j]].PageRank,
-				}
+func newPairSampler(maxExamples int, minDiff float64) *pairSampler {
+	return &pairSampler{maxExamples: maxExamples, minDiff: minDiff}
+}
 
-				// Determine label
-				label := 1
-				if relevances[indices[i]] < relevances[indices[j]] {
-					label = -1
-				}
+// add samples the pairs of documents of a single query whose scores differ by at least minDiff
+func (s *pairSampler) add(qid int, scores []float64, vectors []features.Vector) {
+	n := len(scores)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j || math.Abs(scores[i]-scores[j]) < s.minDiff {
+				continue // Skip same document or less than min relevance difference
+			}
 
-				// Add example to the reservoir using reservoir sampling
-				exampleCount++
-				if len(reservoir) < maxExamples {
-					// Fill the reservoir initially
-					reservoir = append(reservoir, example{feature: diff, label: label})
-				} else {
-					// Replace an existing element with decreasing probability
-					r := rand.Intn(exampleCount)
-					if r < maxExamples {
-						reservoir[r] = example{feature: diff, label: label}
-					}
-				}
+			// Determine label
+			label := 1
+			if scores[i] < scores[j] {
+				label = -1
 			}
-		}
-	}
 
-	// Separate reservoir into features and labels
-	for _, ex := range reservoir {
-		pairwiseFeatures = append(pairwiseFeatures, ex.feature)
-		labels = append(labels, ex.label)
+			// Add example to the reservoir using reservoir sampling
+			s.exampleCount++
+			if len(s.X) < s.maxExamples {
+				// Fill the reservoir initially
+				s.X = append(s.X, features.Diff(vectors[i], vectors[j]))
+				s.Y = append(s.Y, label)
+				s.QIDs = append(s.QIDs, qid)
+			} else if r := rand.Intn(s.exampleCount); r < s.maxExamples {
+				// Replace an existing element with decreasing probability, computing the difference only when kept
+				s.X[r] = features.Diff(vectors[i], vectors[j])
+				s.Y[r] = label
+				s.QIDs[r] = qid
+			}
+		}
 	}
+}
 
-	return pairwiseFeatures, labels
+// dataset returns the sampled examples as a dataset of vectors of the schema
+func (s *pairSampler) dataset(schema features.Schema) Dataset {
+	return Dataset{Schema: schema, X: s.X, Y: s.Y, QIDs: s.QIDs}
 }
@@ -0,0 +1,226 @@
+package datagen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"rpi-search-ranking/internal/features"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Number of lines parsed together by a worker
+const linesPerBatch = 512
+
+// StreamOptions configures StreamQueryGroups
+type StreamOptions struct {
+	Format           Format         // format of the file, defaults to MSLR
+	Workers          int            // goroutines parsing lines, defaults to GOMAXPROCS
+	Progress         func(Progress) // called with the progress at most every ProgressInterval and once at the end
+	ProgressInterval time.Duration  // defaults to 5 seconds
+}
+
+// Progress reports how much of a dataset file has been processed
+type Progress struct {
+	Lines      int64 // lines parsed
+	Queries    int64 // query groups completed
+	Bytes      int64 // bytes read
+	TotalBytes int64 // size of the file
+}
+
+// Fraction returns the share of the file read, between 0 and 1
+func (p Progress) Fraction() float64 {
+	if p.TotalBytes == 0 {
+		return 1
+	}
+	return float64(p.Bytes) / float64(p.TotalBytes)
+}
+
+// batch is a run of consecutive lines and, once parsed, their documents
+type batch struct {
+	firstLine  int64
+	lines      []string
+	bytes      int64
+	relevances []int
+	qids       []int
+	vectors    []features.Vector
+	docIDs     []string
+	err        error
+	done       chan struct{} // closed once the batch is parsed
+}
+
+// StreamQueryGroups parses a dataset file in parallel and calls fn with the documents of each query, with the
+// features of the schema, in file order. Only a bounded number of lines and a single query are held in memory.
+// The documents of a query must be contiguous, as in the MSLR, LETOR and Yahoo files.
+// Returns ctx.Err() when ctx is cancelled.
+func StreamQueryGroups(ctx context.Context, filePath string, schema features.Schema, options StreamOptions, fn func(QueryGroup) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("warning: failed to close file: %v\n", err)
+		}
+	}(file)
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	format := options.Format
+	if format.Name == "" {
+		format = MSLR
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	interval := options.ProgressInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The reader hands batches to the workers and, in the same order, to the collector below,
+	// so at most 2 * workers batches are held at once
+	pending := make(chan *batch, workers)
+	ordered := make(chan *batch, workers)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		defer close(ordered)
+		readErr <- readBatches(ctx, file, pending, ordered)
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for b := range pending {
+				parseBatch(b, format, schema)
+			}
+		}()
+	}
+
+	progress := Progress{TotalBytes: info.Size()}
+	lastReport := time.Now()
+	seen := make(map[int]bool)
+	var group *QueryGroup
+	flush := func() error {
+		if group == nil {
+			return nil
+		}
+		seen[group.QID] = true
+		progress.Queries++
+		err := fn(*group)
+		group = nil
+		return err
+	}
+
+	for b := range ordered {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if b.err != nil {
+			return b.err
+		}
+		for i, qid := range b.qids {
+			if group == nil || group.QID != qid {
+				if err := flush(); err != nil {
+					return err
+				}
+				if seen[qid] {
+					return fmt.Errorf("documents of qid %d are not contiguous", qid)
+				}
+				group = &QueryGroup{QID: qid}
+			}
+			group.Relevances = append(group.Relevances, b.relevances[i])
+			group.Features = append(group.Features, b.vectors[i])
+			group.DocIDs = append(group.DocIDs, b.docIDs[i])
+		}
+
+		progress.Lines += int64(len(b.qids))
+		progress.Bytes += b.bytes
+		if options.Progress != nil && time.Since(lastReport) >= interval {
+			options.Progress(progress)
+			lastReport = time.Now()
+		}
+	}
+	if err := <-readErr; err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err // cancelled while the last batches were in flight
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if options.Progress != nil {
+		options.Progress(progress)
+	}
+	return nil
+}
+
+// readBatches splits the file into batches of lines, sending each to both channels until the file ends or ctx is done
+func readBatches(ctx context.Context, r io.Reader, pending, ordered chan<- *batch) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := int64(0)
+	next := &batch{firstLine: 1, done: make(chan struct{})}
+	send := func(b *batch) error {
+		for _, ch := range []chan<- *batch{ordered, pending} {
+			select {
+			case ch <- b:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		next.lines = append(next.lines, scanner.Text())
+		next.bytes += int64(len(scanner.Bytes())) + 1
+		if len(next.lines) == linesPerBatch {
+			if err := send(next); err != nil {
+				return err
+			}
+			next = &batch{firstLine: lineNumber + 1, done: make(chan struct{})}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(next.lines) > 0 {
+		return send(next)
+	}
+	return nil
+}
+
+// parseBatch parses the lines of a batch, skipping blank lines
+func parseBatch(b *batch, format Format, schema features.Schema) {
+	defer close(b.done)
+	for i, line := range b.lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		record, err := format.ParseLine(line)
+		if err != nil {
+			b.err = fmt.Errorf("line %d: %v", b.firstLine+int64(i), err)
+			return
+		}
+		b.relevances = append(b.relevances, record.Relevance)
+		b.qids = append(b.qids, record.QID)
+		b.vectors = append(b.vectors, record.Features.Dense(schema))
+		b.docIDs = append(b.docIDs, record.DocID)
+	}
+	b.lines = nil
+}
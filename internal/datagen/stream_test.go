@@ -0,0 +1,152 @@
+package datagen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+	"strings"
+	"testing"
+)
+
+// writeDataset writes MSLR lines with one document per relevance grade and feature 110 set to the grade
+func writeDataset(t *testing.T, qids []int, relevances []int) string {
+	t.Helper()
+	var b strings.Builder
+	for i, qid := range qids {
+		fmt.Fprintf(&b, "%d qid:%d 1:1 110:%d\n", relevances[i], qid, relevances[i])
+	}
+	path := filepath.Join(t.TempDir(), "train.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStreamQueryGroups(t *testing.T) {
+	// More documents than a batch so queries span batches and workers
+	var qids, relevances []int
+	for i := 0; i < 3*linesPerBatch; i++ {
+		qids = append(qids, i/100)
+		relevances = append(relevances, i%5)
+	}
+	path := writeDataset(t, qids, relevances)
+	schema, err := features.ParseSchema("1,110")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var groups []QueryGroup
+	var last Progress
+	options := StreamOptions{Workers: 4, Progress: func(p Progress) { last = p }}
+	err = StreamQueryGroups(context.Background(), path, schema, options, func(group QueryGroup) error {
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamQueryGroups() error = %v", err)
+	}
+
+	wantGroups := (len(qids)-1)/100 + 1
+	if len(groups) != wantGroups {
+		t.Fatalf("StreamQueryGroups() returned %d groups, want %d", len(groups), wantGroups)
+	}
+	line := 0
+	for i, group := range groups {
+		if group.QID != i {
+			t.Errorf("group %d has qid %d, want %d", i, group.QID, i)
+		}
+		for j, relevance := range group.Relevances {
+			if relevance != relevances[line] || group.Features[j][1] != float64(relevance) {
+				t.Fatalf("line %d = relevance %d, features %v, want relevance %d", line+1, relevance, group.Features[j], relevances[line])
+			}
+			line++
+		}
+	}
+	if last.Lines != int64(len(qids)) || last.Queries != int64(wantGroups) || last.Fraction() != 1 {
+		t.Errorf("final progress = %+v, want %d lines, %d queries, all bytes", last, len(qids), wantGroups)
+	}
+}
+
+func TestStreamQueryGroups_Errors(t *testing.T) {
+	schema := features.RankingSchema()
+
+	path := writeDataset(t, []int{1, 2, 1}, []int{0, 1, 2})
+	err := StreamQueryGroups(context.Background(), path, schema, StreamOptions{}, func(QueryGroup) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "not contiguous") {
+		t.Errorf("StreamQueryGroups() with a split query error = %v, want not contiguous", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	path = writeDataset(t, []int{1, 2}, []int{0, 1})
+	err = StreamQueryGroups(ctx, path, schema, StreamOptions{}, func(QueryGroup) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("StreamQueryGroups() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	stop := errors.New("stop")
+	err = StreamQueryGroups(context.Background(), path, schema, StreamOptions{}, func(QueryGroup) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("StreamQueryGroups() with a failing callback error = %v, want %v", err, stop)
+	}
+}
+
+func TestCreateExamples(t *testing.T) {
+	// Query 1 has 2 pairs with a relevance difference of at least 2 (in each order), query 2 has none
+	path := writeDataset(t, []int{1, 1, 1, 2, 2}, []int{0, 1, 2, 3, 3})
+	schema, err := features.ParseSchema("110")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := CreateExamples(context.Background(), path, schema, 2, 2, StreamOptions{})
+	if err != nil {
+		t.Fatalf("CreateExamples() error = %v", err)
+	}
+	X, Y := dataset.X, dataset.Y
+	if len(X) != 2 {
+		t.Fatalf("CreateExamples() returned %d examples, want 2", len(X))
+	}
+	for i := range X {
+		if (X[i][0] > 0) != (Y[i] == 1) || (X[i][0] != 2 && X[i][0] != -2) || dataset.QIDs[i] != 1 {
+			t.Errorf("example %d = %v, label %d, qid %d", i, X[i], Y[i], dataset.QIDs[i])
+		}
+	}
+
+	if _, err := CreateExamples(context.Background(), path, schema, 3, 2, StreamOptions{}); err == nil {
+		t.Errorf("CreateExamples() with too few pairs succeeded")
+	}
+}
+
+func TestExamplesFromScores(t *testing.T) {
+	// Each query has one pair whose scores differ by at least 0.5, the documents of "b" are too close
+	docs := []ScoredDocument{
+		{Query: "a", Relevance: 0.9, Features: ranking.Features{BM25: 2}},
+		{Query: "b", Relevance: 0.5, Features: ranking.Features{BM25: 5}},
+		{Query: "a", Relevance: 0.1, Features: ranking.Features{BM25: 1}},
+		{Query: "b", Relevance: 0.4, Features: ranking.Features{BM25: 7}},
+		{Query: "c", Relevance: 0.0, Features: ranking.Features{BM25: 3}},
+		{Query: "c", Relevance: 1.0, Features: ranking.Features{BM25: 4}},
+	}
+	dataset := ExamplesFromScores(docs, 10, 0.5)
+	if !dataset.Schema.Equal(features.RankingSchema()) {
+		t.Errorf("ExamplesFromScores() schema = %v, want the ranking schema", dataset.Schema)
+	}
+	if len(dataset.X) != 4 {
+		t.Fatalf("ExamplesFromScores() returned %d examples, want both orders of the pairs of a and c", len(dataset.X))
+	}
+
+	// Queries are numbered in order of first appearance, so a is 0 and c is 2, and both pairs differ by 1 in BM25
+	bm25, _ := dataset.Schema.Index(110)
+	for i, x := range dataset.X {
+		qid := dataset.QIDs[i]
+		if (qid != 0 && qid != 2) || math.Abs(x[bm25]) != 1 || (x[bm25] > 0) != (dataset.Y[i] == 1) {
+			t.Errorf("example %d = BM25 difference %v, label %d, qid %d", i, x[bm25], dataset.Y[i], qid)
+		}
+	}
+}
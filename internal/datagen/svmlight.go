@@ -0,0 +1,125 @@
+package datagen
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"sort"
+	"strconv"
+)
+
+// SVMlightWriter writes documents and pairwise examples as SVMlight lines, the format read by SVMlight, RankLib and
+// most LTR tools. Features keep their dataset IDs, so the files can be read back with the format they came from.
+type SVMlightWriter struct {
+	w      *bufio.Writer
+	schema features.Schema
+	order  []int // entries of the schema vectors in ascending feature ID order
+}
+
+// NewSVMlightWriter returns a writer of vectors of the schema. Call Flush when done.
+func NewSVMlightWriter(w io.Writer, schema features.Schema) *SVMlightWriter {
+	order := make([]int, schema.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return schema.IDs[order[a]] < schema.IDs[order[b]] })
+	return &SVMlightWriter{w: bufio.NewWriter(w), schema: schema, order: order}
+}
+
+// WriteGroup writes a line per document of a query, "relevance qid:<qid> <id>:<value> ... #docid = <docid>",
+// the listwise layout of LETOR and RankLib. The comment is omitted for documents without an ID.
+func (w *SVMlightWriter) WriteGroup(group QueryGroup) error {
+	for i, relevance := range group.Relevances {
+		docID := ""
+		if i < len(group.DocIDs) {
+			docID = group.DocIDs[i]
+		}
+		if err := w.writeLine(relevance, "qid:"+strconv.Itoa(group.QID), group.Features[i], docID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteExample writes a pairwise example as a classification line, "<label> <id>:<value> ...", with label 1 or -1
+func (w *SVMlightWriter) WriteExample(x features.Vector, y int) error {
+	return w.writeLine(y, "", x, "")
+}
+
+// Flush writes any buffered lines to the underlying writer
+func (w *SVMlightWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *SVMlightWriter) writeLine(target int, qid string, v features.Vector, docID string) error {
+	line := strconv.AppendInt(nil, int64(target), 10)
+	if qid != "" {
+		line = append(append(line, ' '), qid...)
+	}
+	for _, i := range w.order {
+		line = append(line, ' ')
+		line = strconv.AppendInt(line, int64(w.schema.IDs[i]), 10)
+		line = append(line, ':')
+		line = strconv.AppendFloat(line, v[i], 'g', -1, 64)
+	}
+	if docID != "" {
+		line = append(append(line, " #docid = "...), docID...)
+	}
+	line = append(line, '\n')
+	_, err := w.w.Write(line)
+	return err
+}
+
+// SaveDataToSVMlight saves pairwise examples to an SVMlight file, one classification line per example
+func SaveDataToSVMlight(filename string, schema features.Schema, X []features.Vector, Y []int) error {
+	return writeSVMlightFile(filename, schema, func(w *SVMlightWriter) error {
+		for i := range X {
+			if err := w.WriteExample(X[i], Y[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveQueryGroups saves the documents of queries to a listwise SVMlight file, as used by RankLib
+func SaveQueryGroups(filename string, schema features.Schema, groups []QueryGroup) error {
+	return writeSVMlightFile(filename, schema, func(w *SVMlightWriter) error {
+		for _, group := range groups {
+			if err := w.WriteGroup(group); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeSVMlightFile creates a file, creating its directory if needed, and writes it with fn
+func writeSVMlightFile(filename string, schema features.Schema, fn func(*SVMlightWriter) error) error {
+	// Ensure the directory exists
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Create the file
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Close the file and handle errors if they occur
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("warning: failed to close file: %v\n", closeErr)
+		}
+	}()
+
+	w := NewSVMlightWriter(file, schema)
+	if err := fn(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
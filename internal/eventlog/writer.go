@@ -0,0 +1,215 @@
+package eventlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Extension of log files
+const extension = ".jsonl"
+
+// compressedSuffix marks rotated files gzipped by the storage manager, which are still read
+const compressedSuffix = ".gz"
+
+// Writer appends JSON events, one per line, to <dir>/<name>.jsonl.
+// The active file is renamed to <name>-<timestamp>.jsonl once it reaches MaxBytes,
+// and the oldest rotated files beyond MaxFiles are deleted.
+type Writer struct {
+	dir      string
+	name     string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewWriter opens the active log file of name in dir, creating the directory if needed.
+// maxBytes <= 0 disables rotation and maxFiles <= 0 keeps every rotated file.
+func NewWriter(dir, name string, maxBytes int64, maxFiles int) (*Writer, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	w := &Writer{dir: dir, name: name, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) activePath() string {
+	return filepath.Join(w.dir, w.name+extension)
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.activePath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends an event as a single JSON line, rotating the file first if the line would exceed MaxBytes
+func (w *Writer) Write(event interface{}) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error serializing event: %v", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("error rotating event log: %v", err)
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// rotate renames the active file and opens a new one
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	rotatedPath := filepath.Join(w.dir, fmt.Sprintf("%s-%s%s", w.name, time.Now().UTC().Format("20060102T150405.000000000"), extension))
+	if err := os.Rename(w.activePath(), rotatedPath); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.prune()
+}
+
+// prune deletes the oldest rotated files beyond maxFiles
+func (w *Writer) prune() error {
+	if w.maxFiles <= 0 {
+		return nil
+	}
+	rotated, err := rotatedFiles(w.dir, w.name)
+	if err != nil {
+		return err
+	}
+	for len(rotated) > w.maxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Close closes the active file
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotatedFiles returns the rotated files of name in dir from oldest to newest, including gzipped ones
+func rotatedFiles(dir, name string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*"+extension))
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := filepath.Glob(filepath.Join(dir, name+"-*"+extension+compressedSuffix))
+	if err != nil {
+		return nil, err
+	}
+	matches = append(matches, compressed...)
+	// Timestamps sort lexically, so the file names sort chronologically
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Files returns every log file of name in dir in the order the events were written
+func Files(dir, name string) ([]string, error) {
+	files, err := rotatedFiles(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	active := filepath.Join(dir, name+extension)
+	if _, err := os.Stat(active); err == nil {
+		files = append(files, active)
+	}
+	return files, nil
+}
+
+// Read calls fn with every event of name in dir, oldest first, stopping at the first error
+func Read(dir, name string, fn func(line []byte) error) error {
+	files, err := Files(dir, name)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := readFile(path, fn); err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func readFile(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, compressedSuffix) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,124 @@
+package eventlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type event struct {
+	N int `json:"n"`
+}
+
+func TestWriter_RotateAndRead(t *testing.T) {
+	dir := t.TempDir()
+
+	// Each line is 8 bytes, so every file holds two events
+	w, err := NewWriter(dir, "events", 16, 2)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := w.Write(event{N: i}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := w.Write(event{}); err == nil {
+		t.Errorf("Write() after Close() succeeded")
+	}
+
+	files, err := Files(dir, "events")
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Files() = %v, want 2 rotated files and the active file", files)
+	}
+
+	// The oldest rotated file with events 0 and 1 was pruned
+	var got []int
+	err = Read(dir, "events", func(line []byte) error {
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		got = append(got, e.N)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []int{2, 3, 4, 5, 6}
+	if len(got) != len(want) {
+		t.Fatalf("Read() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Read() = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestWriter_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		w, err := NewWriter(dir, "events", 0, 0)
+		if err != nil {
+			t.Fatalf("NewWriter() error = %v", err)
+		}
+		if err := w.Write(event{N: i}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		w.Close()
+	}
+
+	var count int
+	if err := Read(dir, "events", func([]byte) error { count++; return nil }); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Read() returned %d events after reopening, want 2", count)
+	}
+}
+
+func TestRead_Compressed(t *testing.T) {
+	dir := t.TempDir()
+
+	// A rotated file gzipped by retention is read before the newer plain files
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"n":1}` + "\n"))
+	gz.Close()
+	if err := os.WriteFile(filepath.Join(dir, "events-20260101T000000.000000000.jsonl.gz"), compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events-20260102T000000.000000000.jsonl"), []byte(`{"n":2}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events.jsonl"), []byte(`{"n":3}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	err := Read(dir, "events", func(line []byte) error {
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		got = append(got, e.N)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("Read() = %v, want [1 2 3]", got)
+	}
+}
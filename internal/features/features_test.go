@@ -0,0 +1,130 @@
+package features
+
+import (
+	"reflect"
+	"rpi-search-ranking/internal/ranking"
+	"testing"
+)
+
+func TestMSLRSchema(t *testing.T) {
+	schema := MSLRSchema()
+	if schema.Len() != NumMSLRFeatures {
+		t.Fatalf("MSLRSchema() has %d features, want %d", schema.Len(), NumMSLRFeatures)
+	}
+	names := map[int]string{
+		1:   "CoveredQueryTermNumberBody",
+		5:   "CoveredQueryTermNumber",
+		16:  "IDFBody",
+		110: "BM25",
+		113: "LMIRABSTitle",
+		125: "LMIRJM",
+		126: "NumSlashesInURL",
+		130: "PageRank",
+		136: "URLDwellTime",
+	}
+	for id, want := range names {
+		if got := schema.Names[id-1]; got != want {
+			t.Errorf("name of feature %d = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestRankingSchema(t *testing.T) {
+	// The ranking schema names match the Features fields they are converted from
+	schema := RankingSchema()
+	fields := reflect.TypeOf(ranking.Features{})
+	if schema.Len() != fields.NumField() {
+		t.Fatalf("RankingSchema() has %d features, Features has %d fields", schema.Len(), fields.NumField())
+	}
+	for i, name := range schema.Names {
+		if _, ok := fields.FieldByName(name); !ok {
+			t.Errorf("RankingSchema() feature %d is named %q, which is not a Features field", schema.IDs[i], name)
+		}
+	}
+
+	// The registry maps each feature to the MSLR ID of the same name
+	if mismatched := schema.Mismatched(MSLRSchema()); len(mismatched) > 0 {
+		t.Errorf("RankingSchema() names differ from MSLR: %v", mismatched)
+	}
+	if got := LETORSchema().Mismatched(schema); len(got) != 8 || got[0] != "5: SumTermFrequency, expected CoveredQueryTermNumber" {
+		t.Errorf("LETORSchema().Mismatched(RankingSchema()) = %v", got)
+	}
+
+	// Types follow the Features fields, and features the ranker does not compute are floats
+	mslr := MSLRSchema()
+	for id, want := range map[int]ranking.FeatureType{15: ranking.IntFeature, 110: ranking.FloatFeature, 128: ranking.IntFeature, 1: ranking.FloatFeature} {
+		if got := mslr.Type(id - 1); got != want {
+			t.Errorf("Type() of MSLR feature %d = %v, want %v", id, got, want)
+		}
+	}
+	if got := LETORSchema().Type(4); got != ranking.FloatFeature {
+		t.Errorf("Type() of LETOR feature 5 = %v, want float64 since it is not CoveredQueryTermNumber", got)
+	}
+
+	v := FromFeatures(ranking.Features{BM25: 2.5, PageRank: 0.1, StreamLength: 100})
+	for id, want := range map[int]float64{110: 2.5, 130: 0.1, 15: 100} {
+		index, _ := schema.Index(id)
+		if v[index] != want {
+			t.Errorf("FromFeatures() feature %d = %v, want %v", id, v[index], want)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantIDs []int
+		wantErr bool
+	}{
+		{"", RankingSchema().IDs, false},
+		{"ranking", RankingSchema().IDs, false},
+		{"1-3,110, 2", []int{1, 2, 3, 110}, false},
+		{"130-126", nil, true},
+		{"137", nil, true},
+		{"bm25", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSchema(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.IDs, tt.wantIDs) {
+				t.Errorf("ParseSchema() IDs = %v, want %v", got.IDs, tt.wantIDs)
+			}
+		})
+	}
+	if all, _ := ParseSchema("all"); all.Len() != NumMSLRFeatures {
+		t.Errorf("ParseSchema(all) has %d features, want %d", all.Len(), NumMSLRFeatures)
+	}
+}
+
+func TestSparseDenseAndProject(t *testing.T) {
+	schema, _ := ParseSchema("2,4,7")
+	tests := []struct {
+		name   string
+		sparse Sparse
+		want   Vector
+	}{
+		{"All features in order", Sparse{IDs: []int{1, 2, 3, 4, 5, 6, 7}, Values: []float64{1, 2, 3, 4, 5, 6, 7}}, Vector{2, 4, 7}},
+		{"Missing features are 0", Sparse{IDs: []int{4, 9}, Values: []float64{4, 9}}, Vector{0, 4, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sparse.Dense(schema); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dense() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	wider, _ := ParseSchema("1,2,7")
+	if got := Project(Vector{2, 4, 7}, schema, wider); !reflect.DeepEqual(got, Vector{0, 2, 7}) {
+		t.Errorf("Project() = %v, want [0 2 7]", got)
+	}
+	if got := Diff(Vector{3, 1}, Vector{1, 2}); !reflect.DeepEqual(got, Vector{2, -1}) {
+		t.Errorf("Diff() = %v, want [2 -1]", got)
+	}
+	if got := wider.Missing(schema); !reflect.DeepEqual(got, []string{"CoveredQueryTermNumberBody"}) {
+		t.Errorf("Missing() = %v, want [CoveredQueryTermNumberBody]", got)
+	}
+}
@@ -0,0 +1,219 @@
+package features
+
+import (
+	"fmt"
+	"rpi-search-ranking/internal/ranking"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NumMSLRFeatures is the number of features of the MSLR-WEB10K and MSLR-WEB30K datasets
+const NumMSLRFeatures = 136
+
+// mslrGroups are the per-stream feature groups of MSLR, features 1 to 125, named like the Features fields
+var mslrGroups = []string{
+	"CoveredQueryTermNumber", "CoveredQueryTermRatio", "StreamLength", "IDF",
+	"SumTermFrequency", "MinTermFrequency", "MaxTermFrequency", "MeanTermFrequency", "VarianceTermFrequency",
+	"SumStreamLengthNormalizedTF", "MinStreamLengthNormalizedTF", "MaxStreamLengthNormalizedTF",
+	"MeanStreamLengthNormalizedTF", "VarianceStreamLengthNormalizedTF",
+	"SumTFIDF", "MinTFIDF", "MaxTFIDF", "MeanTFIDF", "VarianceTFIDF",
+	"BooleanModel", "VectorSpaceModel", "BM25", "LMIRABS", "LMIRDIR", "LMIRJM",
+}
+
+// mslrStreams are the streams of each group in ID order. The whole document has no suffix, matching Features.
+var mslrStreams = []string{"Body", "Anchor", "Title", "URL", ""}
+
+// mslrDocumentFeatures are the query-independent features 126 to 136
+var mslrDocumentFeatures = []string{
+	"NumSlashesInURL", "LengthOfURL", "InlinkCount", "OutlinkCount", "PageRank", "SiteRank",
+	"QualityScore", "QualityScore2", "QueryURLClickCount", "URLClickCount", "URLDwellTime",
+}
+
+// NumLETORFeatures is the number of features of the LETOR 4.0 datasets MQ2007 and MQ2008
+const NumLETORFeatures = 46
+
+// letorGroups are the per-stream feature groups of LETOR 4.0, features 1 to 40, named like the MSLR features.
+// Streams are in the same order as MSLR.
+var letorGroups = []string{"SumTermFrequency", "IDF", "SumTFIDF", "StreamLength", "BM25", "LMIRABS", "LMIRDIR", "LMIRJM"}
+
+// letorDocumentFeatures are the query-independent features 41 to 46 of LETOR 4.0
+var letorDocumentFeatures = []string{"PageRank", "InlinkCount", "OutlinkCount", "NumSlashesInURL", "LengthOfURL", "ChildPageCount"}
+
+// Schema names the entries of dense feature vectors by feature ID
+type Schema struct {
+	IDs   []int    // feature ID of each entry
+	Names []string // name of each entry
+}
+
+// mslrName returns the name of an MSLR feature ID
+func mslrName(id int) string {
+	if id <= len(mslrGroups)*len(mslrStreams) {
+		return mslrGroups[(id-1)/len(mslrStreams)] + mslrStreams[(id-1)%len(mslrStreams)]
+	}
+	return mslrDocumentFeatures[id-1-len(mslrGroups)*len(mslrStreams)]
+}
+
+// MSLRSchema returns the schema of all 136 MSLR features
+func MSLRSchema() Schema {
+	schema := Schema{IDs: make([]int, NumMSLRFeatures), Names: make([]string, NumMSLRFeatures)}
+	for i := range schema.IDs {
+		schema.IDs[i] = i + 1
+		schema.Names[i] = mslrName(i + 1)
+	}
+	return schema
+}
+
+// LETORSchema returns the schema of all 46 LETOR 4.0 features
+func LETORSchema() Schema {
+	schema := Schema{IDs: make([]int, NumLETORFeatures), Names: make([]string, NumLETORFeatures)}
+	for i := range schema.IDs {
+		schema.IDs[i] = i + 1
+		if i < len(letorGroups)*len(mslrStreams) {
+			schema.Names[i] = letorGroups[i/len(mslrStreams)] + mslrStreams[i%len(mslrStreams)]
+		} else {
+			schema.Names[i] = letorDocumentFeatures[i-len(letorGroups)*len(mslrStreams)]
+		}
+	}
+	return schema
+}
+
+// NumberedSchema returns the schema of features 1 to n, named Feature1 to Featuren, for datasets with anonymous
+// features such as Yahoo! LTR
+func NumberedSchema(n int) Schema {
+	schema := Schema{IDs: make([]int, n), Names: make([]string, n)}
+	for i := range schema.IDs {
+		schema.IDs[i] = i + 1
+		schema.Names[i] = "Feature" + strconv.Itoa(i+1)
+	}
+	return schema
+}
+
+// RankingSchema returns the schema of the features computed by the ranker, generated from ranking.FeatureRegistry
+func RankingSchema() Schema {
+	schema := Schema{IDs: make([]int, len(ranking.FeatureRegistry)), Names: make([]string, len(ranking.FeatureRegistry))}
+	for i, def := range ranking.FeatureRegistry {
+		schema.IDs[i], schema.Names[i] = def.MSLRID, def.Name
+	}
+	return schema
+}
+
+// Mismatched describes the features that s and other name differently under the same ID, such as the features of
+// a model trained on LETOR IDs checked against RankingSchema
+func (s Schema) Mismatched(other Schema) []string {
+	var mismatched []string
+	for i, id := range s.IDs {
+		if j, ok := other.Index(id); ok && s.Names[i] != other.Names[j] {
+			mismatched = append(mismatched, fmt.Sprintf("%d: %s, expected %s", id, s.Names[i], other.Names[j]))
+		}
+	}
+	return mismatched
+}
+
+// Len returns the number of features of the schema
+func (s Schema) Len() int {
+	return len(s.IDs)
+}
+
+// Index returns the position of a feature ID in the schema
+func (s Schema) Index(id int) (int, bool) {
+	for i, schemaID := range s.IDs {
+		if schemaID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Type returns the type the ranker computes the i-th feature of the schema with. Features the ranker does not
+// compute are FloatFeature.
+func (s Schema) Type(i int) ranking.FeatureType {
+	for _, def := range ranking.FeatureRegistry {
+		if def.MSLRID == s.IDs[i] && def.Name == s.Names[i] {
+			return def.Type
+		}
+	}
+	return ranking.FloatFeature
+}
+
+// Equal reports whether two schemas have the same feature IDs in the same order
+func (s Schema) Equal(other Schema) bool {
+	if len(s.IDs) != len(other.IDs) {
+		return false
+	}
+	for i := range s.IDs {
+		if s.IDs[i] != other.IDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Missing returns the names of the features of s that are not in other
+func (s Schema) Missing(other Schema) []string {
+	var missing []string
+	for i, id := range s.IDs {
+		if _, ok := other.Index(id); !ok {
+			missing = append(missing, s.Names[i])
+		}
+	}
+	return missing
+}
+
+// Subset returns the schema restricted to the given feature IDs, in ascending ID order
+func (s Schema) Subset(ids []int) (Schema, error) {
+	ids = append([]int(nil), ids...)
+	sort.Ints(ids)
+	var subset Schema
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		index, ok := s.Index(id)
+		if !ok {
+			return Schema{}, fmt.Errorf("feature %d is not in the schema", id)
+		}
+		subset.IDs = append(subset.IDs, id)
+		subset.Names = append(subset.Names, s.Names[index])
+	}
+	return subset, nil
+}
+
+// ParseSchema returns the schema selected by a flag value: "ranking" for the features computed by the ranker,
+// "all" for every MSLR feature, or a comma separated list of MSLR feature IDs and ranges such as "1-5,110,126-130"
+func ParseSchema(value string) (Schema, error) {
+	switch value {
+	case "", "ranking":
+		return RankingSchema(), nil
+	case "all":
+		return MSLRSchema(), nil
+	}
+
+	ids, err := ParseIDs(value)
+	if err != nil {
+		return Schema{}, err
+	}
+	return MSLRSchema().Subset(ids)
+}
+
+// ParseIDs parses a comma separated list of feature IDs and ranges such as "1-5,110,126-130"
+func ParseIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("invalid feature ID %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid feature range %q", part)
+			}
+		}
+		for id := from; id <= to; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
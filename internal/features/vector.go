@@ -0,0 +1,72 @@
+package features
+
+import (
+	"rpi-search-ranking/internal/ranking"
+	"sort"
+)
+
+// Vector is a dense feature vector whose entries are named by a Schema
+type Vector []float64
+
+// Sparse is a feature vector holding only the features present in a dataset line, by ascending feature ID
+type Sparse struct {
+	IDs    []int
+	Values []float64
+}
+
+// Dense returns the vector of the schema features, with features missing from s set to 0
+func (s Sparse) Dense(schema Schema) Vector {
+	v := make(Vector, schema.Len())
+	for i, id := range schema.IDs {
+		// Dataset lines list features in ascending order, so the position of a feature is usually its ID minus one
+		if j := id - 1; j >= 0 && j < len(s.IDs) && s.IDs[j] == id {
+			v[i] = s.Values[j]
+			continue
+		}
+		if j := sort.SearchInts(s.IDs, id); j < len(s.IDs) && s.IDs[j] == id {
+			v[i] = s.Values[j]
+		}
+	}
+	return v
+}
+
+// Diff returns the element-wise difference a - b, the input of pairwise models
+func Diff(a, b Vector) Vector {
+	diff := make(Vector, len(a))
+	for i := range a {
+		diff[i] = a[i] - b[i]
+	}
+	return diff
+}
+
+// Project maps a vector of schema from to the features of schema to, setting features missing from from to 0
+func Project(v Vector, from, to Schema) Vector {
+	if from.Equal(to) {
+		return v
+	}
+	projected := make(Vector, to.Len())
+	for i, id := range to.IDs {
+		if j, ok := from.Index(id); ok {
+			projected[i] = v[j]
+		}
+	}
+	return projected
+}
+
+// FromFeatures converts the features computed by the ranker to a vector of RankingSchema
+func FromFeatures(f ranking.Features) Vector {
+	v := make(Vector, len(ranking.FeatureRegistry))
+	for i, def := range ranking.FeatureRegistry {
+		v[i] = def.Value(&f)
+	}
+	return v
+}
+
+// FromFeaturesList converts a list of ranker features to vectors of RankingSchema
+func FromFeaturesList(list []ranking.Features) []Vector {
+	vectors := make([]Vector, len(list))
+	for i, f := range list {
+		vectors[i] = FromFeatures(f)
+	}
+	return vectors
+}
@@ -0,0 +1,292 @@
+package feedback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rpi-search-ranking/internal/eventlog"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/monitoring"
+	"sync"
+	"time"
+)
+
+// LogName is the name of the event log files written by a Recorder
+const LogName = "events"
+
+var logger = logging.For("feedback")
+
+// Event types
+const (
+	EventImpression = "impression"
+	EventFeedback   = "feedback"
+	EventShadow     = "shadow" // ranking of a shadow ranker that was not served
+)
+
+// Interaction actions
+const (
+	ActionClick = "click" // the user opened the document
+	ActionSkip  = "skip"  // the user saw the document and moved past it
+	ActionDwell = "dwell" // the user returned after DwellMillis on the document
+)
+
+var (
+	// ErrInvalidFeedback is wrapped by errors caused by malformed feedback
+	ErrInvalidFeedback = errors.New("invalid feedback")
+	// ErrDropped is returned for events dropped because the queue of the event log is full or the Recorder is closed
+	ErrDropped = errors.New("event queue is full")
+)
+
+// ImpressionDocument is a document shown to the user. Its features are in the ranking captures, which training
+// data joins feedback with.
+type ImpressionDocument struct {
+	DocID    string  `json:"docID"`
+	Position int     `json:"position"` // 1-based rank in the response
+	Score    float64 `json:"score"`
+	Team     string  `json:"team,omitempty"` // ranker that contributed the document in interleaving experiments
+}
+
+// Interaction is a single user action on a document of an impression
+type Interaction struct {
+	DocID       string `json:"docID"`
+	Position    int    `json:"position,omitempty"`    // 1-based rank, filled from the impression when omitted
+	Action      string `json:"action"`                // "click", "skip" or "dwell"
+	DwellMillis int64  `json:"dwellMillis,omitempty"` // time spent on the document, for dwell actions
+}
+
+// Event is a single line of the event log, either an impression or feedback on one
+type Event struct {
+	Type         string               `json:"type"`
+	ImpressionID string               `json:"impressionID"`
+	Time         time.Time            `json:"time"`
+	QueryID      string               `json:"queryID,omitempty"`
+	QueryText    string               `json:"queryText,omitempty"`
+	Model        string               `json:"model,omitempty"`
+	Experiment   string               `json:"experiment,omitempty"`
+	Bucket       string               `json:"bucket,omitempty"`
+	Shadow       string               `json:"shadow,omitempty"`
+	Documents    []ImpressionDocument `json:"documents,omitempty"`
+	Interactions []Interaction        `json:"interactions,omitempty"`
+	// Unverified feedback is on an impression the Recorder did not remember, such as one served by another
+	// replica or before a restart. Readers match it to the impression in the log, ignoring other documents.
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+// Config configures a Recorder
+type Config struct {
+	Dir       string // directory of the event log
+	MaxBytes  int64  // size at which the event log is rotated
+	MaxFiles  int    // rotated files kept
+	MaxRecent int    // impressions remembered to validate feedback against
+	QueueSize int    // events buffered before new ones are dropped
+}
+
+// DefaultConfig returns the feedback settings used by the ranking API
+func DefaultConfig() Config {
+	return Config{
+		Dir:       "./data/feedback",
+		MaxBytes:  64 << 20,
+		MaxFiles:  50,
+		MaxRecent: 100000,
+		QueueSize: 10000,
+	}
+}
+
+// Recorder writes impressions and feedback to an event log from a background goroutine, so logging never delays
+// a response. It remembers the document positions of the most recent impressions to validate feedback against them.
+type Recorder struct {
+	log       *eventlog.Writer
+	maxRecent int
+
+	mu     sync.Mutex                // guards recent, order, closed and sends on queue
+	recent map[string]map[string]int // impression ID -> document ID -> position
+	order  []string                  // impression IDs from oldest to newest
+	closed bool
+	queue  chan Event
+	done   chan struct{} // closed once the last event has been written
+}
+
+// NewRecorder opens the event log in config.Dir and starts writing events
+func NewRecorder(config Config) (*Recorder, error) {
+	log, err := eventlog.NewWriter(config.Dir, LogName, config.MaxBytes, config.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		log:       log,
+		maxRecent: config.MaxRecent,
+		recent:    make(map[string]map[string]int),
+		queue:     make(chan Event, config.QueueSize),
+		done:      make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Impression is a ranked list shown to the user
+type Impression struct {
+	ImpressionID string
+	QueryID      string
+	QueryText    string
+	Model        string               // model that ranked the documents
+	Experiment   string               // interleaving experiment, empty for regular traffic
+	Bucket       string               // experiment bucket that chose the ranker, empty outside experiments
+	Documents    []ImpressionDocument // documents in the order they were shown
+}
+
+// RecordImpression queues the documents shown for a query without blocking
+func (r *Recorder) RecordImpression(impression Impression) error {
+	positions := make(map[string]int, len(impression.Documents))
+	for _, doc := range impression.Documents {
+		positions[doc.DocID] = doc.Position
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.enqueue(Event{
+		Type:         EventImpression,
+		ImpressionID: impression.ImpressionID,
+		Time:         time.Now().UTC(),
+		QueryID:      impression.QueryID,
+		QueryText:    impression.QueryText,
+		Model:        impression.Model,
+		Experiment:   impression.Experiment,
+		Bucket:       impression.Bucket,
+		Documents:    impression.Documents,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only logged impressions accept feedback
+	if _, ok := r.recent[impression.ImpressionID]; !ok {
+		r.order = append(r.order, impression.ImpressionID)
+	}
+	r.recent[impression.ImpressionID] = positions
+	for len(r.order) > r.maxRecent {
+		delete(r.recent, r.order[0])
+		r.order = r.order[1:]
+	}
+	return nil
+}
+
+// RecordShadow queues the ranking of a shadow ranker for a served impression, for offline comparison
+func (r *Recorder) RecordShadow(impressionID, shadow, model string, documents []ImpressionDocument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enqueue(Event{
+		Type:         EventShadow,
+		ImpressionID: impressionID,
+		Time:         time.Now().UTC(),
+		Model:        model,
+		Shadow:       shadow,
+		Documents:    documents,
+	})
+}
+
+// RecordFeedback validates interactions and queues them. Interactions on a recent impression must name its
+// documents and positions; feedback on impressions the Recorder does not remember is logged as unverified.
+func (r *Recorder) RecordFeedback(impressionID string, interactions []Interaction) error {
+	if impressionID == "" || len(interactions) == 0 {
+		return fmt.Errorf("%w: impressionID and at least one interaction are required", ErrInvalidFeedback)
+	}
+
+	r.mu.Lock()
+	positions, ok := r.recent[impressionID]
+	r.mu.Unlock()
+
+	validated := make([]Interaction, len(interactions))
+	for i, interaction := range interactions {
+		switch interaction.Action {
+		case ActionClick, ActionSkip, ActionDwell:
+		default:
+			return fmt.Errorf("%w: unknown action %q", ErrInvalidFeedback, interaction.Action)
+		}
+		if interaction.DwellMillis < 0 {
+			return fmt.Errorf("%w: dwellMillis cannot be negative", ErrInvalidFeedback)
+		}
+		if interaction.Position < 0 {
+			return fmt.Errorf("%w: position cannot be negative", ErrInvalidFeedback)
+		}
+		if ok {
+			position, found := positions[interaction.DocID]
+			if !found {
+				return fmt.Errorf("%w: document %q was not part of the impression", ErrInvalidFeedback, interaction.DocID)
+			}
+			if interaction.Position != 0 && interaction.Position != position {
+				return fmt.Errorf("%w: document %q was shown at position %d, not %d", ErrInvalidFeedback, interaction.DocID, position, interaction.Position)
+			}
+			interaction.Position = position
+		}
+		validated[i] = interaction
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enqueue(Event{
+		Type:         EventFeedback,
+		ImpressionID: impressionID,
+		Time:         time.Now().UTC(),
+		Interactions: validated,
+		Unverified:   !ok,
+	})
+}
+
+// enqueue queues an event for the log without blocking, dropping it when the queue is full. r.mu must be held.
+func (r *Recorder) enqueue(event Event) error {
+	if r.closed {
+		monitoring.FeedbackEvents.Inc("dropped")
+		return ErrDropped
+	}
+	select {
+	case r.queue <- event:
+		return nil
+	default:
+		monitoring.FeedbackEvents.Inc("dropped")
+		return ErrDropped
+	}
+}
+
+// run writes queued events until the queue is closed
+func (r *Recorder) run() {
+	defer close(r.done)
+	for event := range r.queue {
+		if err := r.log.Write(event); err != nil {
+			logger.Warn("failed to write feedback event", "type", event.Type, "impressionID", event.ImpressionID, "error", err)
+			monitoring.FeedbackEvents.Inc("failed")
+			continue
+		}
+		monitoring.FeedbackEvents.Inc("written")
+	}
+}
+
+// Close stops accepting events, writes the queued ones and closes the event log
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		<-r.done
+		return nil
+	}
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+
+	<-r.done
+	return r.log.Close()
+}
+
+// ReadEvents calls fn with every logged event in dir, oldest first
+func ReadEvents(dir string, fn func(Event) error) error {
+	return eventlog.Read(dir, LogName, func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		return fn(event)
+	})
+}
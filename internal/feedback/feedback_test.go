@@ -0,0 +1,76 @@
+package feedback
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(Config{Dir: dir, MaxRecent: 1, QueueSize: 10})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	documents := []ImpressionDocument{{DocID: "doc1", Position: 1}, {DocID: "doc2", Position: 2}}
+	if err := r.RecordImpression(Impression{ImpressionID: "old", QueryID: "q0", QueryText: "old query", Model: "bm25", Documents: documents}); err != nil {
+		t.Fatalf("RecordImpression() error = %v", err)
+	}
+	if err := r.RecordImpression(Impression{ImpressionID: "imp1", QueryID: "q1", QueryText: "rpi", Model: "bm25", Documents: documents}); err != nil {
+		t.Fatalf("RecordImpression() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		impressionID string
+		interactions []Interaction
+		wantErr      error
+	}{
+		{"Click without position", "imp1", []Interaction{{DocID: "doc2", Action: ActionClick}}, nil},
+		{"Dwell", "imp1", []Interaction{{DocID: "doc2", Position: 2, Action: ActionDwell, DwellMillis: 1500}}, nil},
+		{"Evicted impression", "old", []Interaction{{DocID: "doc1", Action: ActionClick}}, nil},
+		{"Unknown impression", "other", []Interaction{{DocID: "doc3", Position: 7, Action: ActionClick}}, nil},
+		{"Unknown impression with negative position", "other", []Interaction{{DocID: "doc3", Position: -1, Action: ActionClick}}, ErrInvalidFeedback},
+		{"No interactions", "imp1", nil, ErrInvalidFeedback},
+		{"Unknown action", "imp1", []Interaction{{DocID: "doc1", Action: "hover"}}, ErrInvalidFeedback},
+		{"Unknown document", "imp1", []Interaction{{DocID: "doc3", Action: ActionClick}}, ErrInvalidFeedback},
+		{"Wrong position", "imp1", []Interaction{{DocID: "doc1", Position: 2, Action: ActionSkip}}, ErrInvalidFeedback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.RecordFeedback(tt.impressionID, tt.interactions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RecordFeedback() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := r.RecordImpression(Impression{ImpressionID: "late", Documents: documents}); !errors.Is(err, ErrDropped) {
+		t.Errorf("RecordImpression() after Close() error = %v, want %v", err, ErrDropped)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+
+	var events []Event
+	if err := ReadEvents(dir, func(e Event) error { events = append(events, e); return nil }); err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 6 {
+		t.Fatalf("ReadEvents() returned %d events, want 2 impressions and 4 feedback events", len(events))
+	}
+	if events[1].Type != EventImpression || events[1].QueryText != "rpi" || len(events[1].Documents) != 2 {
+		t.Errorf("ReadEvents()[1] = %+v, want the impression of imp1", events[1])
+	}
+	if got := events[2].Interactions[0]; events[2].Type != EventFeedback || got.Position != 2 {
+		t.Errorf("feedback position = %d, want 2 filled from the impression", got.Position)
+	}
+	if events[2].Unverified || !events[4].Unverified || !events[5].Unverified {
+		t.Errorf("feedback unverified = %v, %v, %v, want only feedback on impressions that are not remembered unverified",
+			events[2].Unverified, events[4].Unverified, events[5].Unverified)
+	}
+	if got := events[5].Interactions[0]; got.Position != 7 {
+		t.Errorf("unverified feedback position = %d, want 7 as reported", got.Position)
+	}
+}
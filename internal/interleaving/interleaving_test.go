@@ -0,0 +1,117 @@
+package interleaving
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"rpi-search-ranking/internal/feedback"
+	"testing"
+)
+
+func TestTeamDraft(t *testing.T) {
+	a := []string{"d1", "d2", "d3", "d4"}
+	b := []string{"d2", "d5", "d1"}
+	for seed := int64(0); seed < 20; seed++ {
+		merged, teams := TeamDraft(a, b, 5, rand.New(rand.NewSource(seed)))
+		if len(merged) != 5 || len(teams) != 5 {
+			t.Fatalf("TeamDraft() returned %d documents and %d teams, want 5", len(merged), len(teams))
+		}
+
+		seen := map[string]bool{}
+		picks := map[string]int{}
+		for i, docID := range merged {
+			if seen[docID] {
+				t.Fatalf("TeamDraft() = %v contains %s twice", merged, docID)
+			}
+			seen[docID] = true
+			picks[teams[i]]++
+
+			// Every pick is the highest ranked unused document of its team
+			ranking := a
+			if teams[i] == TeamB {
+				ranking = b
+			}
+			for _, candidate := range ranking {
+				if !seen[candidate] || candidate == docID {
+					if candidate != docID {
+						t.Errorf("TeamDraft() team %s picked %s before %s", teams[i], docID, candidate)
+					}
+					break
+				}
+			}
+		}
+		// Teams alternate in rounds while both have documents left
+		if diff := picks[TeamA] - picks[TeamB]; diff < -1 || diff > 1 {
+			t.Errorf("TeamDraft() picks = %v, want balanced teams", picks)
+		}
+	}
+
+	merged, _ := TeamDraft([]string{"d1"}, []string{"d1"}, 3, rand.New(rand.NewSource(1)))
+	if !reflect.DeepEqual(merged, []string{"d1"}) {
+		t.Errorf("TeamDraft() of identical single rankings = %v, want [d1]", merged)
+	}
+}
+
+func TestCredit(t *testing.T) {
+	teams := []string{TeamA, TeamB, TeamA, TeamB}
+	tests := []struct {
+		name   string
+		clicks []bool
+		want   string
+	}{
+		{"A wins", []bool{true, false, true, true}, OutcomeA},
+		{"B wins", []bool{false, true, false, false}, OutcomeB},
+		{"Tie", []bool{true, true, false, false}, OutcomeTie},
+		{"No clicks", []bool{false, false, false, false}, OutcomeTie},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Credit(teams, tt.clicks); got != tt.want {
+				t.Errorf("Credit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := feedback.NewRecorder(feedback.Config{Dir: dir, MaxRecent: 100, QueueSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents := []feedback.ImpressionDocument{{DocID: "a", Position: 1, Team: TeamA}, {DocID: "b", Position: 2, Team: TeamB}}
+	impressions := []struct {
+		id      string
+		clicked string
+	}{{"i1", "b"}, {"i2", "b"}, {"i3", "a"}, {"i4", ""}}
+	for _, impression := range impressions {
+		r.RecordImpression(feedback.Impression{ImpressionID: impression.id, Experiment: "exp", Documents: documents})
+		if impression.clicked != "" {
+			r.RecordFeedback(impression.id, []feedback.Interaction{{DocID: impression.clicked, Action: feedback.ActionClick}})
+		}
+	}
+	r.RecordImpression(feedback.Impression{ImpressionID: "regular", Documents: documents})
+	r.Close()
+
+	results, err := Summarize(dir)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Summarize() = %+v, want one experiment", results)
+	}
+	got := results[0]
+	if got.Experiment != "exp" || got.Impressions != 4 || got.WinsA != 1 || got.WinsB != 2 || got.Ties != 0 {
+		t.Errorf("Summarize() = %+v", got)
+	}
+	if math.Abs(got.WinRateB-2.0/3) > 1e-9 || got.Lower >= got.WinRateB || got.Upper <= got.WinRateB {
+		t.Errorf("Summarize() win rate = %v in [%v, %v]", got.WinRateB, got.Lower, got.Upper)
+	}
+}
+
+func TestWilson(t *testing.T) {
+	rate, lower, upper := wilson(50, 100)
+	if rate != 0.5 || math.Abs(lower-0.4038) > 1e-3 || math.Abs(upper-0.5962) > 1e-3 {
+		t.Errorf("wilson(50, 100) = %v [%v, %v], want 0.5 [0.4038, 0.5962]", rate, lower, upper)
+	}
+}
@@ -0,0 +1,103 @@
+package interleaving
+
+import (
+	"math"
+	"rpi-search-ranking/internal/feedback"
+	"sort"
+)
+
+// z-score of a two-sided 95% confidence interval
+const z95 = 1.959964
+
+// Result summarizes the clicked impressions of an experiment
+type Result struct {
+	Experiment  string  `json:"experiment"`
+	Impressions int     `json:"impressions"` // interleaved impressions, with or without clicks
+	WinsA       int     `json:"winsA"`
+	WinsB       int     `json:"winsB"`
+	Ties        int     `json:"ties"` // clicked impressions with as many clicks for both teams
+	WinRateB    float64 `json:"winRateB"`
+	Lower       float64 `json:"lower"` // bounds of the 95% Wilson interval of WinRateB
+	Upper       float64 `json:"upper"`
+}
+
+// Summarize credits the clicks logged in dir to the teams of each interleaving experiment.
+// WinRateB is the share of decided impressions won by ranker B; above 0.5 means B is preferred.
+func Summarize(dir string) ([]Result, error) {
+	impressions := make(map[string]feedback.Event)
+	clicks := make(map[string]map[string]bool) // impression ID -> clicked document IDs
+	err := feedback.ReadEvents(dir, func(event feedback.Event) error {
+		switch event.Type {
+		case feedback.EventImpression:
+			if event.Experiment != "" {
+				impressions[event.ImpressionID] = event
+			}
+		case feedback.EventFeedback:
+			for _, interaction := range event.Interactions {
+				if interaction.Action != feedback.ActionClick && interaction.Action != feedback.ActionDwell {
+					continue
+				}
+				if clicks[event.ImpressionID] == nil {
+					clicks[event.ImpressionID] = make(map[string]bool)
+				}
+				clicks[event.ImpressionID][interaction.DocID] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byExperiment := make(map[string]*Result)
+	for impressionID, impression := range impressions {
+		result, ok := byExperiment[impression.Experiment]
+		if !ok {
+			result = &Result{Experiment: impression.Experiment}
+			byExperiment[impression.Experiment] = result
+		}
+		result.Impressions++
+
+		clicked, ok := clicks[impressionID]
+		if !ok {
+			continue
+		}
+		teams := make([]string, len(impression.Documents))
+		docClicks := make([]bool, len(impression.Documents))
+		for i, doc := range impression.Documents {
+			teams[i] = doc.Team
+			docClicks[i] = clicked[doc.DocID]
+		}
+		switch Credit(teams, docClicks) {
+		case OutcomeA:
+			result.WinsA++
+		case OutcomeB:
+			result.WinsB++
+		default:
+			result.Ties++
+		}
+	}
+
+	results := make([]Result, 0, len(byExperiment))
+	for _, result := range byExperiment {
+		result.WinRateB, result.Lower, result.Upper = wilson(result.WinsB, result.WinsA+result.WinsB)
+		results = append(results, *result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Experiment < results[j].Experiment
+	})
+	return results, nil
+}
+
+// wilson returns the success rate and its 95% Wilson score interval, which stays within [0, 1] for small samples
+func wilson(successes, trials int) (rate, lower, upper float64) {
+	if trials == 0 {
+		return 0, 0, 1
+	}
+	n := float64(trials)
+	rate = float64(successes) / n
+	denominator := 1 + z95*z95/n
+	center := (rate + z95*z95/(2*n)) / denominator
+	margin := z95 * math.Sqrt(rate*(1-rate)/n+z95*z95/(4*n*n)) / denominator
+	return rate, center - margin, center + margin
+}
@@ -0,0 +1,86 @@
+package interleaving
+
+import "math/rand"
+
+// Teams of an interleaved list
+const (
+	TeamA = "A"
+	TeamB = "B"
+)
+
+// Outcomes of an interleaved impression
+const (
+	OutcomeA   = "A"
+	OutcomeB   = "B"
+	OutcomeTie = "tie"
+)
+
+// TeamDraft merges two rankings of document IDs with team-draft interleaving (Radlinski et al., 2008).
+// In each round the team with fewer picks, or a coin flip on a tie, contributes its highest ranked document
+// that is not yet in the merged list. It returns at most length documents and the team of each.
+func TeamDraft(a, b []string, length int, rng *rand.Rand) ([]string, []string) {
+	merged := make([]string, 0, length)
+	teams := make([]string, 0, length)
+	used := make(map[string]bool, length)
+	picksA, picksB := 0, 0
+	nextA, nextB := 0, 0
+
+	// next returns the index of the first unused document of ranking from start, or len(ranking)
+	next := func(ranking []string, start int) int {
+		for start < len(ranking) && used[ranking[start]] {
+			start++
+		}
+		return start
+	}
+
+	for len(merged) < length {
+		nextA, nextB = next(a, nextA), next(b, nextB)
+		if nextA == len(a) && nextB == len(b) {
+			break
+		}
+
+		pickA := picksA < picksB || (picksA == picksB && rng.Intn(2) == 0)
+		// A ranking that ran out of documents lets the other one continue
+		if nextA == len(a) {
+			pickA = false
+		} else if nextB == len(b) {
+			pickA = true
+		}
+
+		if pickA {
+			merged = append(merged, a[nextA])
+			teams = append(teams, TeamA)
+			used[a[nextA]] = true
+			picksA++
+		} else {
+			merged = append(merged, b[nextB])
+			teams = append(teams, TeamB)
+			used[b[nextB]] = true
+			picksB++
+		}
+	}
+	return merged, teams
+}
+
+// Credit returns the team whose documents received more clicks, or a tie
+func Credit(teams []string, clicks []bool) string {
+	clicksA, clicksB := 0, 0
+	for i, clicked := range clicks {
+		if !clicked {
+			continue
+		}
+		switch teams[i] {
+		case TeamA:
+			clicksA++
+		case TeamB:
+			clicksB++
+		}
+	}
+	switch {
+	case clicksA > clicksB:
+		return OutcomeA
+	case clicksB > clicksA:
+		return OutcomeB
+	}
+	return OutcomeTie
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"net"
+	"rpi-search-ranking/internal/monitoring"
+	"sync"
+	"time"
+)
+
+// Maximum time spent resolving a single address
+const lookupTimeout = 2 * time.Second
+
+// HostnameCache resolves client IP addresses to hostnames in the background so logging never waits on DNS
+type HostnameCache struct {
+	ttl        time.Duration
+	maxEntries int
+	lookup     func(ctx context.Context, addr string) ([]string, error)
+
+	mu       sync.Mutex
+	entries  map[string]hostnameEntry
+	inFlight map[string]struct{}
+	slots    chan struct{} // limits the number of concurrent lookups
+}
+
+type hostnameEntry struct {
+	hostname string
+	expires  time.Time
+}
+
+// NewHostnameCache creates a cache keeping resolved hostnames, including failed lookups, for ttl.
+// At most maxEntries addresses are cached and at most maxLookups lookups run at once.
+func NewHostnameCache(ttl time.Duration, maxEntries, maxLookups int) *HostnameCache {
+	return &HostnameCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		lookup:     net.DefaultResolver.LookupAddr,
+		entries:    make(map[string]hostnameEntry),
+		inFlight:   make(map[string]struct{}),
+		slots:      make(chan struct{}, maxLookups),
+	}
+}
+
+// Lookup returns the cached hostname of an IP address without blocking.
+// On a miss it starts a background lookup and returns false; later calls return the result once resolved.
+// Addresses without a reverse DNS entry resolve to an empty hostname.
+func (c *HostnameCache) Lookup(ip string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[ip]; ok && time.Now().Before(entry.expires) {
+		monitoring.RecordCacheLookup("reverse_dns", true)
+		return entry.hostname, true
+	}
+	monitoring.RecordCacheLookup("reverse_dns", false)
+
+	if _, ok := c.inFlight[ip]; ok {
+		return "", false
+	}
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		return "", false // too many lookups running, try again on a later request
+	}
+	c.inFlight[ip] = struct{}{}
+	go c.resolve(ip)
+	return "", false
+}
+
+// resolve performs a reverse lookup and stores the result
+func (c *HostnameCache) resolve(ip string) {
+	defer func() { <-c.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	var hostname string
+	if names, err := c.lookup(ctx, ip); err == nil && len(names) > 0 {
+		hostname = names[0]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, ip)
+	if len(c.entries) >= c.maxEntries {
+		c.evictExpired()
+	}
+	if len(c.entries) < c.maxEntries {
+		c.entries[ip] = hostnameEntry{hostname: hostname, expires: time.Now().Add(c.ttl)}
+	}
+}
+
+// evictExpired removes expired entries, or all entries when none have expired, so the cache stays bounded
+func (c *HostnameCache) evictExpired() {
+	now := time.Now()
+	for ip, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, ip)
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		clear(c.entries)
+	}
+}
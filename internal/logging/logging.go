@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RequestIDHeader carries the request ID between clients, this service and upstream services
+const RequestIDHeader = "X-Request-ID"
+
+var (
+	mu             sync.RWMutex
+	output         io.Writer = os.Stderr
+	defaultLevel             = slog.LevelInfo
+	componentLevel           = map[string]slog.Level{}
+)
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID, which is added to every record logged with it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Configure sets the log levels from a comma separated spec such as "info,ranking=debug,api=warn".
+// An entry without a component sets the default level of every component without its own level.
+// It also routes the standard library logger and slog.Default through the "default" component.
+func Configure(spec string) error {
+	level := slog.LevelInfo
+	levels := map[string]slog.Level{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		component, levelName, found := strings.Cut(entry, "=")
+		if !found {
+			component, levelName = "", entry
+		}
+		var parsed slog.Level
+		if err := parsed.UnmarshalText([]byte(levelName)); err != nil {
+			return fmt.Errorf("invalid log level %q: %v", entry, err)
+		}
+		if component == "" {
+			level = parsed
+		} else {
+			levels[component] = parsed
+		}
+	}
+
+	mu.Lock()
+	defaultLevel = level
+	componentLevel = levels
+	mu.Unlock()
+
+	slog.SetDefault(For("default"))
+	return nil
+}
+
+// SetOutput changes where all loggers write their JSON records
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// For returns a JSON logger for a component, filtered by the component's configured level
+func For(component string) *slog.Logger {
+	inner := slog.NewJSONHandler(currentOutput{}, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(&handler{component: component, inner: inner}).With("component", component)
+}
+
+// currentOutput writes to the output set by SetOutput, so that loggers created earlier follow it
+type currentOutput struct{}
+
+func (currentOutput) Write(p []byte) (int, error) {
+	mu.RLock()
+	w := output
+	mu.RUnlock()
+	return w.Write(p)
+}
+
+// levelOf returns the minimum level logged for a component
+func levelOf(component string) slog.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+	if level, ok := componentLevel[component]; ok {
+		return level
+	}
+	return defaultLevel
+}
+
+// handler filters records by component level and adds the request ID of the context
+type handler struct {
+	component string
+	inner     slog.Handler // JSON handler with the attributes and groups of the logger
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelOf(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{component: h.component, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{component: h.component, inner: h.inner.WithGroup(name)}
+}
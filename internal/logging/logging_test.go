@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFor(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	if err := Configure("warn,ranking=debug"); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	defer Configure("")
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	For("ranking").DebugContext(ctx, "ranked documents", "count", 3)
+	For("api").InfoContext(ctx, "dropped by level")
+	For("api").WarnContext(ctx, "kept", "queryID", "q1")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("record is not JSON: %v", err)
+	}
+	want := map[string]interface{}{"level": "DEBUG", "msg": "ranked documents", "component": "ranking", "request_id": "req-1", "count": 3.0}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("record[%q] = %v, want %v", key, record[key], value)
+		}
+	}
+	if !strings.Contains(lines[1], `"queryID":"q1"`) {
+		t.Errorf("second record = %s, want queryID attribute", lines[1])
+	}
+}
+
+func TestFor_withAttrsAndOutput(t *testing.T) {
+	// Loggers created before SetOutput, and the loggers derived from them, write to the new output
+	logger := For("api").With("model", "bm25").WithGroup("query")
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+
+	logger.Info("ranked", "id", "q1")
+	For("api").Info("plain")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf.String())
+	}
+	if want := `"component":"api","model":"bm25","query":{"id":"q1"}`; !strings.Contains(lines[0], want) {
+		t.Errorf("first record = %s, want %s", lines[0], want)
+	}
+	if strings.Contains(lines[1], "model") {
+		t.Errorf("second record = %s, want no attributes of the other logger", lines[1])
+	}
+}
+
+func TestConfigure_invalid(t *testing.T) {
+	if err := Configure("ranking=loud"); err == nil {
+		t.Errorf("Configure() accepted an invalid level")
+	}
+}
+
+func TestHostnameCache_Lookup(t *testing.T) {
+	cache := NewHostnameCache(time.Minute, 10, 1)
+	resolved := make(chan struct{})
+	cache.lookup = func(ctx context.Context, addr string) ([]string, error) {
+		defer close(resolved)
+		return []string{"client.rpi.edu."}, nil
+	}
+
+	if _, ok := cache.Lookup("10.0.0.1"); ok {
+		t.Fatalf("Lookup() hit before the address was resolved")
+	}
+	<-resolved
+
+	// The result is stored after the lookup function returns
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if hostname, ok := cache.Lookup("10.0.0.1"); ok {
+			if hostname != "client.rpi.edu." {
+				t.Errorf("Lookup() = %q, want client.rpi.edu.", hostname)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("Lookup() never returned the resolved hostname")
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// All functions take the graded relevance labels of a ranked list in rank order, e.g. MSLR grades 0 to 4.
+// A cutoff k <= 0 evaluates the whole list.
+
+// DefaultRelevanceThreshold is the minimum grade counted as relevant by the binary metrics
+const DefaultRelevanceThreshold = 1
+
+// MaxGrade is the highest relevance grade of the MSLR datasets, used to normalize ERR
+const MaxGrade = 4
+
+// cutoff limits k to the length of the list
+func cutoff(n, k int) int {
+	if k <= 0 || k > n {
+		return n
+	}
+	return k
+}
+
+// gain is the exponential gain of a graded label
+func gain(relevance int) float64 {
+	return math.Pow(2, float64(relevance)) - 1
+}
+
+// DCG is the discounted cumulative gain at k with exponential gains
+func DCG(relevances []int, k int) float64 {
+	var dcg float64
+	for i := 0; i < cutoff(len(relevances), k); i++ {
+		dcg += gain(relevances[i]) / math.Log2(float64(i+2))
+	}
+	return dcg
+}
+
+// NDCG is DCG at k divided by the DCG of the ideal ordering, 0 for lists without relevant documents
+func NDCG(relevances []int, k int) float64 {
+	ideal := append([]int(nil), relevances...)
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+	idcg := DCG(ideal, k)
+	if idcg == 0 {
+		return 0
+	}
+	return DCG(relevances, k) / idcg
+}
+
+// AveragePrecision is the mean of the precision at every relevant position, 0 without relevant documents
+func AveragePrecision(relevances []int, threshold int) float64 {
+	var hits int
+	var sum float64
+	for i, relevance := range relevances {
+		if relevance >= threshold {
+			hits++
+			sum += float64(hits) / float64(i+1)
+		}
+	}
+	if hits == 0 {
+		return 0
+	}
+	return sum / float64(hits)
+}
+
+// ReciprocalRank is one over the position of the first relevant document, 0 without relevant documents
+func ReciprocalRank(relevances []int, threshold int) float64 {
+	for i, relevance := range relevances {
+		if relevance >= threshold {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// ERR is the expected reciprocal rank at k under the cascade model, with grades normalized by maxGrade
+func ERR(relevances []int, k, maxGrade int) float64 {
+	maxGain := math.Pow(2, float64(maxGrade))
+	var err float64
+	notSatisfied := 1.0
+	for i := 0; i < cutoff(len(relevances), k); i++ {
+		satisfied := gain(relevances[i]) / maxGain
+		err += notSatisfied * satisfied / float64(i+1)
+		notSatisfied *= 1 - satisfied
+	}
+	return err
+}
+
+// PrecisionAt is the fraction of relevant documents among the first k
+func PrecisionAt(relevances []int, k, threshold int) float64 {
+	n := cutoff(len(relevances), k)
+	if k <= 0 {
+		k = n
+	}
+	if k == 0 {
+		return 0
+	}
+	var hits int
+	for _, relevance := range relevances[:n] {
+		if relevance >= threshold {
+			hits++
+		}
+	}
+	// Missing positions below the end of a short list count as non-relevant
+	return float64(hits) / float64(k)
+}
+
+// QueryMetrics holds all metrics of a single ranked query
+type QueryMetrics struct {
+	NDCG      float64 `json:"ndcg"`
+	AP        float64 `json:"ap"`
+	RR        float64 `json:"rr"`
+	ERR       float64 `json:"err"`
+	Precision float64 `json:"precision"`
+}
+
+// Evaluate computes all metrics of a ranked list, using k for NDCG, ERR and precision
+func Evaluate(relevances []int, k, threshold int) QueryMetrics {
+	return QueryMetrics{
+		NDCG:      NDCG(relevances, k),
+		AP:        AveragePrecision(relevances, threshold),
+		RR:        ReciprocalRank(relevances, threshold),
+		ERR:       ERR(relevances, k, MaxGrade),
+		Precision: PrecisionAt(relevances, k, threshold),
+	}
+}
+
+// Mean averages metrics over queries, e.g. AP into MAP and RR into MRR
+func Mean(queries []QueryMetrics) QueryMetrics {
+	var mean QueryMetrics
+	if len(queries) == 0 {
+		return mean
+	}
+	for _, q := range queries {
+		mean.NDCG += q.NDCG
+		mean.AP += q.AP
+		mean.RR += q.RR
+		mean.ERR += q.ERR
+		mean.Precision += q.Precision
+	}
+	n := float64(len(queries))
+	mean.NDCG /= n
+	mean.AP /= n
+	mean.RR /= n
+	mean.ERR /= n
+	mean.Precision /= n
+	return mean
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func TestNDCG(t *testing.T) {
+	tests := []struct {
+		name       string
+		relevances []int
+		k          int
+		want       float64
+	}{
+		{"Ideal order", []int{3, 2, 1, 0}, 0, 1},
+		{"No relevant documents", []int{0, 0, 0}, 0, 0},
+		{"Empty list", nil, 10, 0},
+		{
+			"Swapped top two",
+			[]int{1, 2},
+			0,
+			(1 + 3/math.Log2(3)) / (3 + 1/math.Log2(3)),
+		},
+		{"Cutoff", []int{0, 2, 2}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NDCG(tt.relevances, tt.k); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("NDCG() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAveragePrecision(t *testing.T) {
+	tests := []struct {
+		name       string
+		relevances []int
+		want       float64
+	}{
+		{"Relevant at 1 and 3", []int{2, 0, 1, 0}, (1 + 2.0/3) / 2},
+		{"No relevant documents", []int{0, 0}, 0},
+		{"All relevant", []int{1, 1, 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AveragePrecision(tt.relevances, DefaultRelevanceThreshold); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("AveragePrecision() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReciprocalRank(t *testing.T) {
+	if got := ReciprocalRank([]int{0, 0, 3}, DefaultRelevanceThreshold); math.Abs(got-1.0/3) > epsilon {
+		t.Errorf("ReciprocalRank() = %v, want 1/3", got)
+	}
+	if got := ReciprocalRank([]int{1, 0, 3}, 2); math.Abs(got-1.0/3) > epsilon {
+		t.Errorf("ReciprocalRank() with threshold 2 = %v, want 1/3", got)
+	}
+	if got := ReciprocalRank([]int{0}, DefaultRelevanceThreshold); got != 0 {
+		t.Errorf("ReciprocalRank() = %v, want 0", got)
+	}
+}
+
+func TestERR(t *testing.T) {
+	// A perfect document at the top satisfies the user with probability 15/16
+	r1 := 15.0 / 16
+	r2 := 1.0 / 16
+	tests := []struct {
+		name       string
+		relevances []int
+		k          int
+		want       float64
+	}{
+		{"Perfect first", []int{4, 1}, 0, r1 + (1-r1)*r2/2},
+		{"Cutoff", []int{4, 1}, 1, r1},
+		{"Not relevant", []int{0, 0}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ERR(tt.relevances, tt.k, MaxGrade); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("ERR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrecisionAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		relevances []int
+		k          int
+		want       float64
+	}{
+		{"Top two", []int{1, 0, 1}, 2, 0.5},
+		{"Short list", []int{1}, 4, 0.25},
+		{"Whole list", []int{1, 0, 1, 1}, 0, 0.75},
+		{"Empty list", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrecisionAt(tt.relevances, tt.k, DefaultRelevanceThreshold); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("PrecisionAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMean(t *testing.T) {
+	got := Mean([]QueryMetrics{{NDCG: 1, AP: 0.5, RR: 1}, {NDCG: 0, AP: 0.5, RR: 0.5}})
+	want := QueryMetrics{NDCG: 0.5, AP: 0.5, RR: 0.75}
+	if got != want {
+		t.Errorf("Mean() = %v, want %v", got, want)
+	}
+	if got := Mean(nil); got != (QueryMetrics{}) {
+		t.Errorf("Mean(nil) = %v, want zero metrics", got)
+	}
+}
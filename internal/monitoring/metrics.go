@@ -0,0 +1,70 @@
+package monitoring
+
+import (
+	"net/http"
+	"time"
+)
+
+// Default is the registry served by the ranking API on /metrics
+var Default = NewRegistry()
+
+// Metrics reported by the ranking API
+var (
+	HTTPRequests = Default.NewCounterVec("ranking_http_requests_total",
+		"Number of HTTP requests handled, by route, method and status code.", "route", "method", "status")
+	HTTPRequestDuration = Default.NewHistogramVec("ranking_http_request_duration_seconds",
+		"HTTP request latency in seconds, by route, method and status code.", DefaultBuckets, "route", "method", "status")
+	UpstreamRequests = Default.NewCounterVec("ranking_upstream_requests_total",
+		"Number of requests sent to upstream services, by upstream and result (success or error).", "upstream", "result")
+	UpstreamRequestDuration = Default.NewHistogramVec("ranking_upstream_request_duration_seconds",
+		"Upstream request latency in seconds, by upstream.", DefaultBuckets, "upstream")
+	CandidateDocuments = Default.NewHistogramVec("ranking_candidate_documents",
+		"Number of documents matching any query term per ranked query.", []float64{0, 1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000})
+	CacheRequests = Default.NewCounterVec("ranking_cache_requests_total",
+		"Number of cache lookups, by cache and result (hit or miss).", "cache", "result")
+	ModelInferenceDuration = Default.NewHistogramVec("ranking_model_inference_duration_seconds",
+		"Time spent ordering documents with a learned model in seconds, by model.", DefaultBuckets, "model")
+	EvaluationReports = Default.NewCounterVec("ranking_evaluation_reports_total",
+		"Number of evaluation records handled by the reporter, by result (sent, dropped, spilled, failed).", "result")
+	Captures = Default.NewCounterVec("ranking_captures_total",
+		"Number of sampled rankings handled by the capture writer, by result (written, dropped, skipped, failed).", "result")
+	FeedbackEvents = Default.NewCounterVec("ranking_feedback_events_total",
+		"Number of impression, shadow and feedback events handled by the feedback recorder, by result (written, dropped, failed).", "result")
+)
+
+// RecordCacheLookup counts a hit or miss of the named cache
+func RecordCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.Inc(cache, result)
+}
+
+// InstrumentTransport wraps an HTTP transport to record the latency and error rate of upstream requests.
+// upstream names the service a request is sent to.
+func InstrumentTransport(base http.RoundTripper, upstream func(*http.Request) string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		name := upstream(req)
+		startTime := time.Now()
+		resp, err := base.RoundTrip(req)
+		UpstreamRequestDuration.Observe(time.Since(startTime).Seconds(), name)
+
+		result := "success"
+		if err != nil || resp.StatusCode >= http.StatusBadRequest {
+			result = "error"
+		}
+		UpstreamRequests.Inc(name, result)
+		return resp, err
+	})
+}
+
+// roundTripperFunc allows creating a RoundTripper from a function
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,90 @@
+package monitoring
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("requests_total", "Number of requests.", "route", "status")
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+
+	requests.Inc("/v1/rank", "200")
+	requests.Add(2, "/v1/rank", "200")
+	requests.Inc(`/quoted"route`, "500")
+	latency.Observe(0.05, "/v1/rank")
+	latency.Observe(0.5, "/v1/rank")
+	latency.Observe(3, "/v1/rank")
+
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/quoted\"route",status="500"} 1
+requests_total{route="/v1/rank",status="200"} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/v1/rank",le="0.1"} 1
+latency_seconds_bucket{route="/v1/rank",le="1"} 2
+latency_seconds_bucket{route="/v1/rank",le="+Inf"} 3
+latency_seconds_sum{route="/v1/rank"} 3.55
+latency_seconds_count{route="/v1/rank"} 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterVec_Add(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("events_total", "Events.")
+	counter.Add(2)
+	counter.Add(-1) // counters never decrease
+	if got := counter.Value(); got != 2 {
+		t.Errorf("Value() = %v, want 2", got)
+	}
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		wantResult string
+	}{
+		{"Success", http.StatusOK, nil, "success"},
+		{"Server error", http.StatusInternalServerError, nil, "error"},
+		{"Network error", 0, io.ErrUnexpectedEOF, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &http.Response{StatusCode: tt.statusCode, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			upstream := "test_" + strings.ReplaceAll(tt.name, " ", "_")
+			client := &http.Client{Transport: InstrumentTransport(base, func(*http.Request) string { return upstream })}
+
+			resp, _ := client.Get("http://example.com")
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			if got := UpstreamRequests.Value(upstream, tt.wantResult); got != 1 {
+				t.Errorf("UpstreamRequests{%s,%s} = %v, want 1", upstream, tt.wantResult, got)
+			}
+			if got := UpstreamRequestDuration.Count(upstream); got != 1 {
+				t.Errorf("UpstreamRequestDuration{%s} count = %v, want 1", upstream, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package ranking
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Default base URLs of the upstream services, matching the endpoint constants
+const (
+	DefaultIndexURL        = "http://lspt-index-ranking.cs.rpi.edu:8080"
+	DefaultLinkAnalysisURL = "http://lspt-link-analysis.cs.rpi.edu:1234"
+)
+
+// Backend holds the base URLs of the services ranking fetches documents from
+type Backend struct {
+	IndexURL        string // serves the invertible index, document metadata and statistics
+	LinkAnalysisURL string // serves PageRank
+}
+
+// DefaultBackend is the course project deployment
+var DefaultBackend = Backend{IndexURL: DefaultIndexURL, LinkAnalysisURL: DefaultLinkAnalysisURL}
+
+// Transport returns a round tripper that sends requests for the default services to this backend instead,
+// so the same ranking code can run against a local or staging deployment
+func (b Backend) Transport(base http.RoundTripper) (http.RoundTripper, error) {
+	rewrites := make(map[string]*url.URL, 2)
+	for defaultURL, target := range map[string]string{DefaultIndexURL: b.IndexURL, DefaultLinkAnalysisURL: b.LinkAnalysisURL} {
+		if target == "" || target == defaultURL {
+			continue
+		}
+		from, _ := url.Parse(defaultURL)
+		to, err := url.Parse(target)
+		if err != nil || to.Scheme == "" || to.Host == "" {
+			return nil, fmt.Errorf("invalid backend URL %q", target)
+		}
+		rewrites[from.Host] = to
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if len(rewrites) == 0 {
+		return base, nil
+	}
+
+	return backendTransport{base: base, rewrites: rewrites}, nil
+}
+
+type backendTransport struct {
+	base     http.RoundTripper
+	rewrites map[string]*url.URL // default host -> backend base URL
+}
+
+func (t backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	to, ok := t.rewrites[req.URL.Host]
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = to.Scheme
+	req.URL.Host = to.Host
+
+	// Join the escaped paths too, since the old RawPath would no longer match the joined Path
+	escapedPath := to.EscapedPath() + req.URL.EscapedPath()
+	req.URL.Path = to.Path + req.URL.Path
+	req.URL.RawPath = escapedPath
+	req.Host = to.Host
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,49 @@
+package ranking
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackend_Transport(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.RequestURI()
+		io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+
+	transport, err := Backend{IndexURL: server.URL + "/staging"}.Transport(nil)
+	if err != nil {
+		t.Fatalf("Transport() error = %v", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(StatisticsEndpoint)
+	if err != nil {
+		t.Fatalf("request to rewritten backend failed: %v", err)
+	}
+	resp.Body.Close()
+	if want := "/staging/get-total-doc-statistics"; gotPath != want {
+		t.Errorf("backend received path %q, want %q", gotPath, want)
+	}
+
+	// Escaped characters of the path are kept under the backend base path
+	resp, err = client.Get(DefaultIndexURL + "/documents/a%2Fb%20c")
+	if err != nil {
+		t.Fatalf("request to rewritten backend failed: %v", err)
+	}
+	resp.Body.Close()
+	if want := "/staging/documents/a%2Fb%20c"; gotPath != want {
+		t.Errorf("backend received path %q, want %q", gotPath, want)
+	}
+
+	if _, err := (Backend{IndexURL: "not a url"}).Transport(nil); err == nil {
+		t.Errorf("Transport() accepted an invalid backend URL")
+	}
+	if transport, err := DefaultBackend.Transport(http.DefaultTransport); err != nil || transport != http.DefaultTransport {
+		t.Errorf("Transport() of the default backend = %v, %v, want the base transport", transport, err)
+	}
+}
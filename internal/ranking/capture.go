@@ -0,0 +1,45 @@
+package ranking
+
+import (
+	"sync"
+	"time"
+)
+
+// Capture is a ranking served for a query, recorded to build training data from live traffic
+type Capture struct {
+	Time            time.Time
+	Query           Query
+	Model           string     // name of the model that ordered the documents
+	ModelVersion    string     // version of the model, empty for BM25
+	Experiment      string     // experiment bucket the query was ranked in, empty outside experiments
+	BM25            BM25Params // parameters of the BM25 feature
+	TotalCandidates int
+	Documents       Documents // every ranked document in ranked order, before paging
+	Teams           []string  // team that contributed each document of an interleaved ranking, nil otherwise
+}
+
+// Capturer records rankings. Capture is called on the request path, so it must not block or keep Documents.
+type Capturer interface {
+	Capture(c Capture)
+}
+
+var (
+	capturerMu sync.RWMutex
+	capturer   Capturer
+)
+
+// SetCapturer sets the capturer receiving every ranking, nil disables captures
+func SetCapturer(c Capturer) {
+	capturerMu.Lock()
+	defer capturerMu.Unlock()
+	capturer = c
+}
+
+// capture passes a ranking to the capturer, if any
+func capture(c Capture) {
+	capturerMu.RLock()
+	defer capturerMu.RUnlock()
+	if capturer != nil {
+		capturer.Capture(c)
+	}
+}
@@ -1,17 +1,75 @@
 package ranking
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"strings"
 )
 
-const InvertibleIndexEndpoint = "http://lspt-index-ranking.cs.rpi.edu:8080/get-invertible-index?term="
-const MetadataEndpoint = "http://lspt-index-ranking.cs.rpi.edu:8080/get-document-metadata?docID="
-const StatisticsEndpoint = "http://lspt-index-ranking.cs.rpi.edu:8080/get-total-doc-statistics"
-const PagerankEndpoint = "http://lspt-link-analysis.cs.rpi.edu:1234/ranking/"
+const InvertibleIndexEndpoint = DefaultIndexURL + "/get-invertible-index?term="
+const MetadataEndpoint = DefaultIndexURL + "/get-document-metadata?docID="
+const StatisticsEndpoint = DefaultIndexURL + "/get-total-doc-statistics"
+const PagerankEndpoint = DefaultLinkAnalysisURL + "/ranking/"
+
+// probeTerm is the term used to check that the invertible index API is reachable
+const probeTerm = "rpi"
+
+// Upstream describes an upstream API and the URL used to check that it is reachable
+type Upstream struct {
+	Name     string
+	ProbeURL string
+}
+
+// Upstreams lists the upstream APIs ranking depends on
+var Upstreams = []Upstream{
+	{Name: "invertible_index", ProbeURL: InvertibleIndexEndpoint + probeTerm},
+	{Name: "document_metadata", ProbeURL: MetadataEndpoint + "probe"},
+	{Name: "doc_statistics", ProbeURL: StatisticsEndpoint},
+	{Name: "pagerank", ProbeURL: PagerankEndpoint + "https://www.rpi.edu"},
+}
+
+// ProbeUpstream checks that an upstream API answers within the context deadline.
+// Any response below 500 counts as reachable since probe IDs need not exist upstream.
+func ProbeUpstream(ctx context.Context, client *http.Client, upstream Upstream) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.ProbeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Warn("failed to close response body", "error", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s is unhealthy: %v", upstream.Name, resp.Status)
+	}
+	return nil
+}
+
+// UpstreamName returns the name of the upstream API an endpoint URL belongs to, used to label metrics
+func UpstreamName(apiURL string) string {
+	switch {
+	case strings.HasPrefix(apiURL, InvertibleIndexEndpoint):
+		return "invertible_index"
+	case strings.HasPrefix(apiURL, MetadataEndpoint):
+		return "document_metadata"
+	case strings.HasPrefix(apiURL, StatisticsEndpoint):
+		return "doc_statistics"
+	case strings.HasPrefix(apiURL, PagerankEndpoint):
+		return "pagerank"
+	}
+	return "unknown"
+}
 
 // getInvertibleIndex fetches the unique inverted index for all terms in the given query.
 func getInvertibleIndex(client *http.Client, query Query) (invertibleIndex, error) {
@@ -52,7 +110,7 @@ func fetchInvertibleIndexForTerm(client *http.Client, term string) ([]documentIn
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("warning: failed to close response body: %v\n", err)
+			logger.Warn("failed to close response body", "error", err)
 		}
 	}(resp.Body)
 
@@ -88,7 +146,7 @@ func fetchDocumentMetadata(client *http.Client, docID string) (DocumentMetadata,
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("warning: failed to close response body: %v\n", err)
+			logger.Warn("failed to close response body", "error", err)
 		}
 	}(resp.Body)
 
@@ -124,7 +182,7 @@ func fetchTotalDocStatistics(client *http.Client) (totalDocStatistics, error) {
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("warning: failed to close response body: %v\n", err)
+			logger.Warn("failed to close response body", "error", err)
 		}
 	}(resp.Body)
 
@@ -156,7 +214,7 @@ func fetchPageRank(client *http.Client, url string) (PageRankInfo, error) {
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("warning: failed to close response body: %v\n", err)
+			logger.Warn("failed to close response body", "error", err)
 		}
 	}(resp.Body)
 
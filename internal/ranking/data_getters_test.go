@@ -1,6 +1,7 @@
 package ranking
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -541,3 +542,60 @@ func Test_fetchPageRank(t *testing.T) {
 		})
 	}
 }
+
+func TestUpstreamName(t *testing.T) {
+	tests := []struct {
+		apiURL string
+		want   string
+	}{
+		{InvertibleIndexEndpoint + "term", "invertible_index"},
+		{MetadataEndpoint + "doc1", "document_metadata"},
+		{StatisticsEndpoint, "doc_statistics"},
+		{PagerankEndpoint + "https://example.com", "pagerank"},
+		{"http://example.com", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := UpstreamName(tt.apiURL); got != tt.want {
+				t.Errorf("UpstreamName(%q) = %v, want %v", tt.apiURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeUpstream(t *testing.T) {
+	upstream := Upstream{Name: "doc_statistics", ProbeURL: StatisticsEndpoint}
+	tests := []struct {
+		name    string
+		client  *http.Client
+		wantErr bool
+	}{
+		{
+			name:    "Healthy",
+			client:  createMockHTTPClient(map[string]string{StatisticsEndpoint: `{}`}, map[string]error{}, http.StatusOK),
+			wantErr: false,
+		},
+		{
+			name:    "Reachable with unknown probe ID",
+			client:  createMockHTTPClient(map[string]string{}, map[string]error{}, http.StatusOK),
+			wantErr: false,
+		},
+		{
+			name:    "Server error",
+			client:  createMockHTTPClient(map[string]string{StatisticsEndpoint: `{}`}, map[string]error{}, http.StatusServiceUnavailable),
+			wantErr: true,
+		},
+		{
+			name:    "Network error",
+			client:  createMockHTTPClient(map[string]string{}, map[string]error{StatisticsEndpoint: fmt.Errorf("network error")}, http.StatusOK),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ProbeUpstream(context.Background(), tt.client, upstream); (err != nil) != tt.wantErr {
+				t.Errorf("ProbeUpstream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
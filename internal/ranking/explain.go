@@ -0,0 +1,56 @@
+package ranking
+
+// Explanation describes why a document received its rank
+type Explanation struct {
+	Features           Features              `json:"features"`                     // all ranking features of the document
+	BM25Terms          []BM25TermExplanation `json:"bm25Terms"`                    // BM25 contribution of each matched query term
+	ModelContributions []FeatureContribution `json:"modelContributions,omitempty"` // per-feature contributions of linear models
+	PairwiseWins       *int                  `json:"pairwiseWins,omitempty"`       // comparisons won, pairwise models only
+	PairwiseLosses     *int                  `json:"pairwiseLosses,omitempty"`     // comparisons lost, pairwise models only
+}
+
+// BM25TermExplanation holds the BM25 components of a single query term
+type BM25TermExplanation struct {
+	Term                string  `json:"term"`
+	TF                  int     `json:"tf"`                  // term frequency in the document
+	IDF                 float64 `json:"idf"`                 // smoothed inverse document frequency
+	LengthNormalization float64 `json:"lengthNormalization"` // k1 * (1 - b + b * docLength / avgDocLength)
+	Contribution        float64 `json:"contribution"`        // idf * tf * (k1 + 1) / (tf + lengthNormalization)
+}
+
+// FeatureContribution is the share of a model's decision value attributed to one feature
+type FeatureContribution struct {
+	Feature           string  `json:"feature"`
+	Value             float64 `json:"value"`             // raw feature value
+	StandardizedValue float64 `json:"standardizedValue"` // value after the model's standardization
+	Weight            float64 `json:"weight"`            // model weight of the feature
+	Contribution      float64 `json:"contribution"`      // weight * standardizedValue
+}
+
+// FeatureContributor is implemented by models that can attribute their decision value to individual features
+type FeatureContributor interface {
+	FeatureContributions(features Features) []FeatureContribution
+}
+
+// explain builds the explanation of every document ranked against the query.
+// ranked holds all documents the model compared, so pairwise losses can be derived from the wins.
+func (docs Documents) explain(query Query, idf map[string]float64, avgDocLength float64, params BM25Params, model PairwiseModel, ranked int) {
+	contributor, _ := model.(FeatureContributor)
+	for i := range docs {
+		doc := &docs[i]
+		explanation := &Explanation{
+			Features:  doc.Features,
+			BM25Terms: params.termContributions(query, doc.TermFrequencies, idf, doc.Metadata.DocLength, avgDocLength),
+		}
+		if contributor != nil {
+			explanation.ModelContributions = contributor.FeatureContributions(doc.Features)
+		}
+		if model != nil {
+			wins := int(doc.Score)
+			losses := ranked - 1 - wins
+			explanation.PairwiseWins = &wins
+			explanation.PairwiseLosses = &losses
+		}
+		doc.Explanation = explanation
+	}
+}
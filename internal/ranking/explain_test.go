@@ -0,0 +1,101 @@
+package ranking
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// bm25Contributor is a pairwise model that also reports a single feature contribution
+type bm25Contributor struct{}
+
+func (bm25Contributor) PredictClass(diff Features) int {
+	if diff.BM25 > 0 {
+		return 1
+	}
+	return -1
+}
+
+func (bm25Contributor) FeatureContributions(features Features) []FeatureContribution {
+	return []FeatureContribution{{Feature: "BM25", Value: features.BM25, StandardizedValue: features.BM25, Weight: 1, Contribution: features.BM25}}
+}
+
+func TestBM25Params_termContributions(t *testing.T) {
+	query := Query{Terms: []string{"term1", "term2", "term3"}}
+	termFrequencies := map[string]int{"term1": 3, "term2": 2}
+	idf := map[string]float64{"term1": 1.2, "term2": 1.5, "term3": 0.5}
+
+	got := DefaultBM25Params.termContributions(query, termFrequencies, idf, 100, 120)
+	if len(got) != 2 {
+		t.Fatalf("termContributions() returned %d terms, want 2", len(got))
+	}
+
+	lengthNormalization := k1 * ((1 - b) + b*(100.0/120.0))
+	want := BM25TermExplanation{
+		Term:                "term1",
+		TF:                  3,
+		IDF:                 1.2,
+		LengthNormalization: lengthNormalization,
+		Contribution:        1.2 * ((3 * (k1 + 1)) / (3 + lengthNormalization)),
+	}
+	if got[0].Term != want.Term || got[0].TF != want.TF || got[0].IDF != want.IDF ||
+		math.Abs(got[0].LengthNormalization-want.LengthNormalization) > epsilon ||
+		math.Abs(got[0].Contribution-want.Contribution) > epsilon {
+		t.Errorf("termContributions()[0] = %v, want %v", got[0], want)
+	}
+
+	sum := got[0].Contribution + got[1].Contribution
+	if total := calculateBM25(query, termFrequencies, idf, 100, 120); math.Abs(sum-total) > epsilon {
+		t.Errorf("term contributions sum to %v, calculateBM25() = %v", sum, total)
+	}
+}
+
+func TestDocuments_explain(t *testing.T) {
+	query := Query{Terms: []string{"term1"}}
+	idf := map[string]float64{"term1": 1.0}
+	newDocs := func() Documents {
+		return Documents{
+			{DocID: "doc1", TermFrequencies: map[string]int{"term1": 2}, Metadata: DocumentMetadata{DocLength: 100}, Features: Features{BM25: 2.0}},
+			{DocID: "doc2", TermFrequencies: map[string]int{"term1": 1}, Metadata: DocumentMetadata{DocLength: 100}, Features: Features{BM25: 1.0}},
+		}
+	}
+
+	t.Run("BM25", func(t *testing.T) {
+		docs := newDocs()
+		SortDocuments(docs, nil)
+		docs.explain(query, idf, 100, DefaultBM25Params, nil, len(docs))
+		for _, doc := range docs {
+			if doc.Explanation == nil {
+				t.Fatalf("explain() did not set an explanation for %s", doc.DocID)
+			}
+			if !reflect.DeepEqual(doc.Explanation.Features, doc.Features) {
+				t.Errorf("explain() features = %v, want %v", doc.Explanation.Features, doc.Features)
+			}
+			if len(doc.Explanation.BM25Terms) != 1 {
+				t.Errorf("explain() returned %d BM25 terms, want 1", len(doc.Explanation.BM25Terms))
+			}
+			if doc.Explanation.PairwiseWins != nil || doc.Explanation.ModelContributions != nil {
+				t.Errorf("explain() set model explanations without a model")
+			}
+		}
+	})
+
+	t.Run("Pairwise model", func(t *testing.T) {
+		docs := newDocs()
+		model := bm25Contributor{}
+		SortDocuments(docs, model)
+		docs.explain(query, idf, 100, DefaultBM25Params, model, len(docs))
+		wantWins := []int{1, 0}
+		for i, doc := range docs {
+			if doc.Explanation.PairwiseWins == nil || *doc.Explanation.PairwiseWins != wantWins[i] {
+				t.Errorf("explain() wins of %s = %v, want %d", doc.DocID, doc.Explanation.PairwiseWins, wantWins[i])
+			}
+			if wantLosses := 1 - wantWins[i]; *doc.Explanation.PairwiseLosses != wantLosses {
+				t.Errorf("explain() losses of %s = %d, want %d", doc.DocID, *doc.Explanation.PairwiseLosses, wantLosses)
+			}
+			if len(doc.Explanation.ModelContributions) != 1 || doc.Explanation.ModelContributions[0].Contribution != doc.Features.BM25 {
+				t.Errorf("explain() contributions of %s = %v", doc.DocID, doc.Explanation.ModelContributions)
+			}
+		}
+	})
+}
@@ -116,8 +116,25 @@ func calculateNormalizedTFStats(query Query, termFrequencies map[string]int, doc
 }
 
 func calculateBM25(query Query, termFrequencies map[string]int, idf map[string]float64, docLength int, avgDocLength float64) float64 {
+	return calculateBM25WithParams(DefaultBM25Params, query, termFrequencies, idf, docLength, avgDocLength)
+}
+
+// calculateBM25WithParams scores a document with the given BM25 parameters
+func calculateBM25WithParams(params BM25Params, query Query, termFrequencies map[string]int, idf map[string]float64, docLength int, avgDocLength float64) float64 {
 	var bm25Score float64
 
+	// Sum the BM25 contributions of the query terms
+	for _, term := range params.termContributions(query, termFrequencies, idf, docLength, avgDocLength) {
+		bm25Score += term.Contribution
+	}
+
+	return bm25Score
+}
+
+// termContributions returns the BM25 components of every query term found in the document
+func (p BM25Params) termContributions(query Query, termFrequencies map[string]int, idf map[string]float64, docLength int, avgDocLength float64) []BM25TermExplanation {
+	var contributions []BM25TermExplanation
+
 	// Loop over query terms and calculate BM25 contributions
 	for _, term := range query.Terms {
 		tf, exists := termFrequencies[term] // Term frequency in the document
@@ -130,12 +147,19 @@ func calculateBM25(query Query, termFrequencies map[string]int, idf map[string]f
 		}
 
 		// BM25 formula components
-		numerator := float64(tf) * (k1 + 1)
-		denominator := float64(tf) + k1*(1-b+b*(float64(docLength)/avgDocLength))
-		bm25Score += idfValue * (numerator / denominator)
+		lengthNormalization := p.K1 * (1 - p.B + p.B*(float64(docLength)/avgDocLength))
+		numerator := float64(tf) * (p.K1 + 1)
+		denominator := float64(tf) + lengthNormalization
+		contributions = append(contributions, BM25TermExplanation{
+			Term:                term,
+			TF:                  tf,
+			IDF:                 idfValue,
+			LengthNormalization: lengthNormalization,
+			Contribution:        idfValue * (numerator / denominator),
+		})
 	}
 
-	return bm25Score
+	return contributions
 }
 
 func calculateIDFMetrics(query Query, termFrequencies map[string]int, idf map[string]float64) (sum, min, max, mean, variance float64) {
@@ -196,63 +220,79 @@ func analyzeURL(url string) (numSlashes, length int) {
 	return
 }
 
-// Main feature initialization function
-func (doc *Document) calculateFeatures(query Query, idf map[string]float64, avgDocLength float64, client *http.Client) error {
-	// Query term coverage metrics
-	coveredTerms := 0
-	for _, term := range query.Terms {
-		if _, found := doc.TermFrequencies[term]; found {
-			coveredTerms++
+// termStats are the sum, minimum, maximum, mean and variance of a per query term value
+type termStats struct {
+	sum, min, max, mean, variance float64
+}
+
+// featureInput is what the extractors of FeatureRegistry compute the features of a document from. Statistics
+// shared by several features are computed on first use.
+type featureInput struct {
+	doc          *Document
+	query        Query
+	idf          map[string]float64
+	avgDocLength float64
+	links        *PageRankInfo // nil when the link analysis could not be fetched
+
+	tf, normalizedTF, tfidfStats *termStats
+}
+
+// coveredTerms returns the number of query terms found in the document
+func (in *featureInput) coveredTerms() int {
+	covered := 0
+	for _, term := range in.query.Terms {
+		if _, found := in.doc.TermFrequencies[term]; found {
+			covered++
 		}
 	}
-	doc.Features.CoveredQueryTermNumber = coveredTerms
-	doc.Features.CoveredQueryTermRatio = float64(coveredTerms) / float64(len(query.Terms))
-
-	// Term frequency statistics
-	sumTF, minTF, maxTF, meanTF, varianceTF := calculateTermFrequencyStats(query, doc.TermFrequencies)
-	doc.Features.SumTermFrequency = sumTF
-	doc.Features.MinTermFrequency = minTF
-	doc.Features.MaxTermFrequency = maxTF
-	doc.Features.MeanTermFrequency = meanTF
-	doc.Features.VarianceTermFrequency = varianceTF
-
-	// Normalized TF statistics
-	sumNormTF, minNormTF, maxNormTF, meanNormTF, varNormTF := calculateNormalizedTFStats(query, doc.TermFrequencies, doc.Metadata.DocLength)
-	doc.Features.StreamLength = doc.Metadata.DocLength
-	doc.Features.SumStreamLengthNormalizedTF = sumNormTF
-	doc.Features.MinStreamLengthNormalizedTF = minNormTF
-	doc.Features.MaxStreamLengthNormalizedTF = maxNormTF
-	doc.Features.MeanStreamLengthNormalizedTF = meanNormTF
-	doc.Features.VarianceStreamLengthNormalizedTF = varNormTF
-
-	// IDF-based metrics
-	sumTFIDF, minTFIDF, maxTFIDF, meanTFIDF, varTFIDF := calculateIDFMetrics(query, doc.TermFrequencies, idf)
-	doc.Features.SumTFIDF = sumTFIDF
-	doc.Features.MinTFIDF = minTFIDF
-	doc.Features.MaxTFIDF = maxTFIDF
-	doc.Features.MeanTFIDF = meanTFIDF
-	doc.Features.VarianceTFIDF = varTFIDF
-
-	// BM25 score
-	doc.Features.BM25 = calculateBM25(query, doc.TermFrequencies, idf, doc.Metadata.DocLength, avgDocLength)
-
-	// URL characteristics
-	numSlashes, urlLength := analyzeURL(doc.Metadata.URL)
-	doc.Features.NumSlashesInURL = numSlashes
-	doc.Features.LengthOfURL = urlLength
+	return covered
+}
+
+// termFrequencies returns the statistics of the term frequencies of the query terms
+func (in *featureInput) termFrequencies() termStats {
+	if in.tf == nil {
+		sum, min, max, mean, variance := calculateTermFrequencyStats(in.query, in.doc.TermFrequencies)
+		in.tf = &termStats{float64(sum), float64(min), float64(max), mean, variance}
+	}
+	return *in.tf
+}
+
+// normalizedTermFrequencies returns the statistics of the term frequencies normalized by the document length
+func (in *featureInput) normalizedTermFrequencies() termStats {
+	if in.normalizedTF == nil {
+		sum, min, max, mean, variance := calculateNormalizedTFStats(in.query, in.doc.TermFrequencies, in.doc.Metadata.DocLength)
+		in.normalizedTF = &termStats{sum, min, max, mean, variance}
+	}
+	return *in.normalizedTF
+}
+
+// tfidf returns the statistics of the TF-IDF of the query terms
+func (in *featureInput) tfidf() termStats {
+	if in.tfidfStats == nil {
+		sum, min, max, mean, variance := calculateIDFMetrics(in.query, in.doc.TermFrequencies, in.idf)
+		in.tfidfStats = &termStats{sum, min, max, mean, variance}
+	}
+	return *in.tfidfStats
+}
+
+// Main feature initialization function. Every feature of FeatureRegistry is computed by its extractor; link
+// analysis features keep their value when the link analysis cannot be fetched, and the error is returned.
+func (doc *Document) calculateFeatures(query Query, idf map[string]float64, avgDocLength float64, client *http.Client) error {
+	in := &featureInput{doc: doc, query: query, idf: idf, avgDocLength: avgDocLength}
 
 	// Link analysis
-	// get document metadata
 	pageRank, err := fetchPageRank(client, doc.Metadata.URL)
-	if err != nil {
-		return err
+	if err == nil {
+		in.links = &pageRank
 	}
 
-	doc.Features.InlinkCount = pageRank.InLinkCount
-	doc.Features.OutlinkCount = pageRank.OutLinkCount
-	doc.Features.PageRank = pageRank.PageRank
+	for _, def := range FeatureRegistry {
+		if value, ok := def.extract(in); ok {
+			def.Set(&doc.Features, value)
+		}
+	}
 
-	return nil
+	return err
 }
 
 // Batch initialization for a list of documents
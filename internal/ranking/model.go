@@ -0,0 +1,135 @@
+package ranking
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// PairwiseModel predicts which document of a pair should be ranked higher.
+// PredictClass receives the feature difference of the first and second document and returns
+// 1 if the first document should be ranked higher and -1 otherwise, matching the datagen labels.
+type PairwiseModel interface {
+	PredictClass(diff Features) int
+}
+
+// DiffFeatures returns the element-wise difference a - b of every registered feature, the input of pairwise models
+func DiffFeatures(a, b Features) Features {
+	var diff Features
+	for _, def := range FeatureRegistry {
+		def.Set(&diff, def.Value(&a)-def.Value(&b))
+	}
+	return diff
+}
+
+// SortDocuments scores the documents and sorts them by descending score.
+// A nil model scores each document by BM25, otherwise a document's score is the number of
+// pairwise comparisons it wins against the other documents. Ties are broken by BM25.
+func SortDocuments(docs Documents, model PairwiseModel) {
+	if model == nil {
+		for i := range docs {
+			docs[i].Score = docs[i].Features.BM25
+		}
+	} else {
+		for i := range docs {
+			docs[i].Score = float64(pairwiseWins(docs, model, i))
+		}
+	}
+
+	slices.SortStableFunc(docs, func(a, b Document) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		if a.Features.BM25 > b.Features.BM25 {
+			return -1
+		} else if a.Features.BM25 < b.Features.BM25 {
+			return 1
+		}
+		return 0
+	})
+}
+
+// pairwiseWins counts the documents that the model ranks below the document at index i
+func pairwiseWins(docs Documents, model PairwiseModel, i int) int {
+	wins := 0
+	for j := range docs {
+		if i != j && model.PredictClass(DiffFeatures(docs[i].Features, docs[j].Features)) == 1 {
+			wins++
+		}
+	}
+	return wins
+}
+
+// matches reports whether the document metadata satisfies the filter
+func (f Filter) matches(metadata DocumentMetadata) bool {
+	if len(f.FileTypes) > 0 && !slices.ContainsFunc(f.FileTypes, func(fileType string) bool {
+		return strings.EqualFold(fileType, metadata.FileType)
+	}) {
+		return false
+	}
+	if f.MinDocLength > 0 && metadata.DocLength < f.MinDocLength {
+		return false
+	}
+	if f.MaxDocLength > 0 && metadata.DocLength > f.MaxDocLength {
+		return false
+	}
+	if f.URLPrefix != "" && !strings.HasPrefix(metadata.URL, f.URLPrefix) {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() || !f.UpdatedBefore.IsZero() {
+		updated, err := time.Parse(time.RFC3339, metadata.TimeLastUpdated)
+		if err != nil {
+			return false // documents without a valid timestamp cannot satisfy a time filter
+		}
+		if !f.UpdatedAfter.IsZero() && !updated.After(f.UpdatedAfter) {
+			return false
+		}
+		if !f.UpdatedBefore.IsZero() && !updated.Before(f.UpdatedBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter returns the documents whose metadata satisfies the filter
+func (docs Documents) filter(f Filter) Documents {
+	filtered := docs[:0]
+	for _, doc := range docs {
+		if f.matches(doc.Metadata) {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
+}
+
+// maskedModel hides features from a model by zeroing them in every comparison
+type maskedModel struct {
+	model    PairwiseModel
+	features []FeatureDef // hidden features
+}
+
+func (m maskedModel) PredictClass(diff Features) int {
+	for _, def := range m.features {
+		def.Set(&diff, 0)
+	}
+	return m.model.PredictClass(diff)
+}
+
+// MaskFeatures returns a model that ranks without the named Features fields, e.g. to test a model without PageRank
+func MaskFeatures(model PairwiseModel, names []string) (PairwiseModel, error) {
+	if model == nil || len(names) == 0 {
+		return model, nil
+	}
+	masked := maskedModel{model: model}
+	for _, name := range names {
+		def, err := LookupFeature(name)
+		if err != nil {
+			return nil, err
+		}
+		masked.features = append(masked.features, def)
+	}
+	return masked, nil
+}
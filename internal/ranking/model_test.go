@@ -0,0 +1,135 @@
+package ranking
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pageRankModel prefers the document with the higher PageRank
+type pageRankModel struct{}
+
+func (pageRankModel) PredictClass(diff Features) int {
+	if diff.PageRank > 0 {
+		return 1
+	}
+	return -1
+}
+
+func docIDs(docs Documents) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.DocID
+	}
+	return ids
+}
+
+func TestDiffFeatures(t *testing.T) {
+	a := Features{CoveredQueryTermNumber: 3, BM25: 2.5, PageRank: 0.75, LengthOfURL: 20}
+	b := Features{CoveredQueryTermNumber: 1, BM25: 1.0, PageRank: 0.25, LengthOfURL: 25}
+	want := Features{CoveredQueryTermNumber: 2, BM25: 1.5, PageRank: 0.5, LengthOfURL: -5}
+	if got := DiffFeatures(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffFeatures() = %v, want %v", got, want)
+	}
+}
+
+func TestSortDocuments(t *testing.T) {
+	newDocs := func() Documents {
+		return Documents{
+			{DocID: "doc1", Features: Features{BM25: 1.0, PageRank: 0.9}},
+			{DocID: "doc2", Features: Features{BM25: 3.0, PageRank: 0.1}},
+			{DocID: "doc3", Features: Features{BM25: 2.0, PageRank: 0.5}},
+		}
+	}
+	tests := []struct {
+		name       string
+		model      PairwiseModel
+		wantIDs    []string
+		wantScores []float64
+	}{
+		{
+			name:       "BM25",
+			model:      nil,
+			wantIDs:    []string{"doc2", "doc3", "doc1"},
+			wantScores: []float64{3.0, 2.0, 1.0},
+		},
+		{
+			name:       "Pairwise wins",
+			model:      pageRankModel{},
+			wantIDs:    []string{"doc1", "doc3", "doc2"},
+			wantScores: []float64{2, 1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := newDocs()
+			SortDocuments(docs, tt.model)
+			if got := docIDs(docs); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("SortDocuments() order = %v, want %v", got, tt.wantIDs)
+			}
+			for i, doc := range docs {
+				if doc.Score != tt.wantScores[i] {
+					t.Errorf("SortDocuments() score of %s = %v, want %v", doc.DocID, doc.Score, tt.wantScores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilter_matches(t *testing.T) {
+	metadata := DocumentMetadata{
+		DocLength:       100,
+		TimeLastUpdated: "2024-11-09T15:30:00Z",
+		FileType:        "PDF",
+		URL:             "http://example.com/docs/1",
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"Empty filter", Filter{}, true},
+		{"Matching file type", Filter{FileTypes: []string{"html", "pdf"}}, true},
+		{"Other file type", Filter{FileTypes: []string{"html"}}, false},
+		{"Within length bounds", Filter{MinDocLength: 50, MaxDocLength: 100}, true},
+		{"Too short", Filter{MinDocLength: 101}, false},
+		{"Too long", Filter{MaxDocLength: 99}, false},
+		{"Matching URL prefix", Filter{URLPrefix: "http://example.com/docs"}, true},
+		{"Other URL prefix", Filter{URLPrefix: "http://other.com"}, false},
+		{"Updated after", Filter{UpdatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"Not updated after", Filter{UpdatedAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"Not updated before", Filter{UpdatedBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(metadata); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskFeatures(t *testing.T) {
+	diff := Features{PageRank: 0.5}
+	if got := (pageRankModel{}).PredictClass(diff); got != 1 {
+		t.Fatalf("pageRankModel.PredictClass() = %d, want 1", got)
+	}
+
+	masked, err := MaskFeatures(pageRankModel{}, []string{"PageRank"})
+	if err != nil {
+		t.Fatalf("MaskFeatures() error = %v", err)
+	}
+	if got := masked.PredictClass(diff); got != -1 {
+		t.Errorf("masked PredictClass() = %d, want -1 without PageRank", got)
+	}
+	if diff.PageRank != 0.5 {
+		t.Errorf("masked PredictClass() modified the caller's features")
+	}
+
+	if _, err := MaskFeatures(pageRankModel{}, []string{"Unknown"}); err == nil {
+		t.Errorf("MaskFeatures() accepted an unknown feature")
+	}
+	if model, err := MaskFeatures(nil, []string{"PageRank"}); model != nil || err != nil {
+		t.Errorf("MaskFeatures(nil) = %v, %v, want BM25 ranking unchanged", model, err)
+	}
+}
@@ -1,14 +1,47 @@
 package ranking
 
 import (
-	"log"
 	"net/http"
-
+	"rpi-search-ranking/internal/logging"
 	"slices"
+	"time"
 )
 
+var logger = logging.For("ranking")
+
 // RankDocuments ranks the documents based on the query text
 func RankDocuments(query Query, client *http.Client) ([]Document, error) {
+	result, err := RankDocumentsWithOptions(query, client, RankOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Documents, nil
+}
+
+// RankDocumentsWithOptions ranks the documents based on the query text, ordering the top documents
+// with the model given in the options and returning the requested page of filtered results
+func RankDocumentsWithOptions(query Query, client *http.Client, options RankOptions) (RankResult, error) {
+	candidates, err := FetchCandidates(query, client, options.Filter, options.BM25)
+	if err != nil {
+		return RankResult{}, err
+	}
+	return candidates.Rank(options), nil
+}
+
+// Candidates are the top documents of a query by BM25 with their features, fetched once to be ranked by one
+// or more models
+type Candidates struct {
+	query         Query
+	idf           map[string]float64
+	docStatistics totalDocStatistics
+	bm25Params    BM25Params
+	documents     Documents // filtered documents sorted by BM25, at most maxDocuments
+	total         int       // number of documents matching any query term
+}
+
+// FetchCandidates fetches the documents matching the query, computes their features with the given BM25
+// parameters and keeps the top documents by BM25 that pass the filter
+func FetchCandidates(query Query, client *http.Client, filter Filter, bm25 BM25Params) (*Candidates, error) {
 	query.tokenize()
 
 	// Get invertible index for the query
@@ -22,10 +55,11 @@ func RankDocuments(query Query, client *http.Client) ([]Document, error) {
 	if err != nil {
 		return nil, err
 	}
+	candidates := &Candidates{query: query, bm25Params: bm25.orDefault(), total: len(documents)}
 
 	// Return early if there are no documents
 	if len(documents) == 0 {
-		return nil, nil
+		return candidates, nil
 	}
 
 	// Count and avg length of all documents
@@ -34,32 +68,54 @@ func RankDocuments(query Query, client *http.Client) ([]Document, error) {
 		return nil, err
 	}
 
-	
 	// Add document metadata and features
 	err = documents.initializeFeatures(query, docStatistics, index, client)
 	if err != nil {
-		log.Printf("warning: failed to initialize features: %v\n", err)
+		logger.Warn("failed to initialize features", "queryID", query.Id, "error", err)
 	}
-	// Sort by BM25
-	slices.SortFunc(documents, func(a, b Document) int {
-		if a.Features.BM25 > b.Features.BM25 {
-			return -1
-		} else if a.Features.BM25 < b.Features.BM25 {
-			return 1
+
+	// Drop documents excluded by the metadata filter
+	documents = documents.filter(filter)
+
+	// Recompute the BM25 feature for rankings that override its parameters
+	idf := getIDF(index, docStatistics.DocCount)
+	if candidates.bm25Params != DefaultBM25Params {
+		for i := range documents {
+			documents[i].Features.BM25 = calculateBM25WithParams(candidates.bm25Params, query, documents[i].TermFrequencies, idf,
+				documents[i].Metadata.DocLength, docStatistics.AvgDocLength)
 		}
-		return 0
-	})
+	}
+
+	// Sort by BM25
+	SortDocuments(documents, nil)
 
 	// Only consider top maxDocuments documents
-	documents = documents[:min(maxDocuments, len(documents))]
+	candidates.documents = documents[:min(maxDocuments, len(documents))]
+	candidates.idf = idf
+	candidates.docStatistics = docStatistics
 
-	// TODO: sort by pairwise classification on all features
+	return candidates, nil
+}
 
-	// Save data for training
-	filename := generateUniqueFilename("../../data/raw/examples")
-	err = saveData(filename, documents)
-	if err != nil {
-		log.Printf("warning: failed to write documents to file: %v\n", err)
+// Rank orders a copy of the candidates with the model given in the options, captures the ranking unless
+// options.NoCapture is set, and returns the requested page. The filter and BM25 parameters of the options are
+// those of FetchCandidates and ignored here.
+func (c *Candidates) Rank(options RankOptions) RankResult {
+	result := RankResult{TotalCandidates: c.total}
+
+	// Nothing to rank without documents
+	if c.total == 0 {
+		return result
+	}
+
+	documents := slices.Clone(c.documents)
+	result.TotalRanked = len(documents)
+
+	// Sort by pairwise classification on all features
+	if options.Model != nil {
+		startTime := time.Now()
+		SortDocuments(documents, options.Model)
+		result.InferenceTime = time.Since(startTime)
 	}
 
 	// rank
@@ -67,10 +123,44 @@ func RankDocuments(query Query, client *http.Client) ([]Document, error) {
 		documents[i].Rank = i + 1
 	}
 
-	log.Printf("Ranked documents for query: %s", query)
+	// Record the ranking for training
+	if !options.NoCapture {
+		c.Capture(documents, nil, options)
+	}
+
+	logger.Debug("ranked documents", "queryID", c.query.Id, "queryText", c.query.Text, "candidates", result.TotalCandidates, "ranked", result.TotalRanked)
 
-	// Return the ranked documents
-	return documents, nil
+	// Return the requested page of ranked documents
+	start := min(max(options.Offset, 0), len(documents))
+	end := len(documents)
+	if options.TopK > 0 {
+		end = min(start+options.TopK, end)
+	}
+	result.Documents = documents[start:end]
+
+	// Explain the returned documents
+	if options.Explain {
+		Documents(result.Documents).explain(c.query, c.idf, c.docStatistics.AvgDocLength, c.bm25Params, options.Model, result.TotalRanked)
+	}
+
+	return result
+}
+
+// Capture records a ranking of the candidates, such as the interleaving of the rankings of two models, described
+// by the model name, version and experiment of the options. teams names the team that contributed each document of
+// an interleaved ranking and is nil otherwise.
+func (c *Candidates) Capture(documents Documents, teams []string, options RankOptions) {
+	capture(Capture{
+		Time:            time.Now().UTC(),
+		Query:           c.query,
+		Model:           options.ModelName,
+		ModelVersion:    options.ModelVersion,
+		Experiment:      options.Experiment,
+		BM25:            c.bm25Params,
+		TotalCandidates: c.total,
+		Documents:       documents,
+		Teams:           teams,
+	})
 }
 
 // getDocuments returns a slice of all documents in the invertibleIndex
@@ -84,10 +174,12 @@ func getDocuments(index invertibleIndex) (Documents, error) {
 			// Check if the document already exists in the map
 			doc, exists := documentsMap[docIndex.DocID]
 			if !exists {
-				// If the document doesn't exist, initialize it
+				// If the document doesn't exist, initialize it with the registered default features, which it
+				// keeps when computing them fails
 				doc = Document{
 					DocID:           docIndex.DocID,
 					TermFrequencies: make(map[string]int),
+					Features:        DefaultFeatures(),
 				}
 			}
 
@@ -0,0 +1,170 @@
+package ranking
+
+import "fmt"
+
+// FeatureType is the Go type of a Features field
+type FeatureType int
+
+const (
+	IntFeature FeatureType = iota
+	FloatFeature
+)
+
+func (t FeatureType) String() string {
+	if t == IntFeature {
+		return "int"
+	}
+	return "float64"
+}
+
+// FeatureDef declares a feature computed by the ranker. Feature vectors, pairwise differences, dataset schemas
+// and CSV columns are all generated from the definitions in FeatureRegistry, and calculateFeatures computes every
+// registered feature with its extractor.
+type FeatureDef struct {
+	Name    string      // name of the Features field, which is also the name of the MSLR feature
+	Type    FeatureType // Go type of the field
+	MSLRID  int         // ID of the feature in MSLR datasets
+	Default float64     // value until the ranker computes the feature, kept when computing it fails
+	int     func(f *Features) *int
+	float   func(f *Features) *float64
+	extract featureExtractor
+}
+
+// featureExtractor computes a feature of a document for a query, or reports that its input is unavailable
+type featureExtractor func(in *featureInput) (float64, bool)
+
+// fromDocument declares a feature computed from the document, the query and the corpus statistics
+func fromDocument(value func(in *featureInput) float64) featureExtractor {
+	return func(in *featureInput) (float64, bool) { return value(in), true }
+}
+
+// fromLinks declares a feature computed from the link analysis of the document, which keeps its default when the
+// link analysis cannot be fetched
+func fromLinks(value func(links PageRankInfo) float64) featureExtractor {
+	return func(in *featureInput) (float64, bool) {
+		if in.links == nil {
+			return 0, false
+		}
+		return value(*in.links), true
+	}
+}
+
+// intFeature declares an int field of Features with its default and extractor
+func intFeature(name string, mslrID int, def int, field func(f *Features) *int, extract featureExtractor) FeatureDef {
+	return FeatureDef{Name: name, Type: IntFeature, MSLRID: mslrID, Default: float64(def), int: field, extract: extract}
+}
+
+// floatFeature declares a float64 field of Features with its default and extractor
+func floatFeature(name string, mslrID int, def float64, field func(f *Features) *float64, extract featureExtractor) FeatureDef {
+	return FeatureDef{Name: name, Type: FloatFeature, MSLRID: mslrID, Default: def, float: field, extract: extract}
+}
+
+// Value extracts the feature from f
+func (d FeatureDef) Value(f *Features) float64 {
+	if d.Type == IntFeature {
+		return float64(*d.int(f))
+	}
+	return *d.float(f)
+}
+
+// Set sets the feature of f, rounding the value of int features
+func (d FeatureDef) Set(f *Features, value float64) {
+	if d.Type == IntFeature {
+		*d.int(f) = int(value)
+		return
+	}
+	*d.float(f) = value
+}
+
+// FeatureRegistry lists the features computed by the ranker in the order of their feature vectors. Adding a
+// feature takes a Features field and a definition here.
+var FeatureRegistry = []FeatureDef{
+	// Covered Query Term Metrics
+	intFeature("CoveredQueryTermNumber", 5, 0, func(f *Features) *int { return &f.CoveredQueryTermNumber },
+		fromDocument(func(in *featureInput) float64 { return float64(in.coveredTerms()) })),
+	floatFeature("CoveredQueryTermRatio", 10, 0, func(f *Features) *float64 { return &f.CoveredQueryTermRatio },
+		fromDocument(func(in *featureInput) float64 { return float64(in.coveredTerms()) / float64(len(in.query.Terms)) })),
+
+	// Term Frequency Statistics
+	intFeature("SumTermFrequency", 25, 0, func(f *Features) *int { return &f.SumTermFrequency },
+		fromDocument(func(in *featureInput) float64 { return in.termFrequencies().sum })),
+	intFeature("MinTermFrequency", 30, 0, func(f *Features) *int { return &f.MinTermFrequency },
+		fromDocument(func(in *featureInput) float64 { return in.termFrequencies().min })),
+	intFeature("MaxTermFrequency", 35, 0, func(f *Features) *int { return &f.MaxTermFrequency },
+		fromDocument(func(in *featureInput) float64 { return in.termFrequencies().max })),
+	floatFeature("MeanTermFrequency", 40, 0, func(f *Features) *float64 { return &f.MeanTermFrequency },
+		fromDocument(func(in *featureInput) float64 { return in.termFrequencies().mean })),
+	floatFeature("VarianceTermFrequency", 45, 0, func(f *Features) *float64 { return &f.VarianceTermFrequency },
+		fromDocument(func(in *featureInput) float64 { return in.termFrequencies().variance })),
+
+	// Stream Length Statistics (normalized term frequencies)
+	intFeature("StreamLength", 15, 0, func(f *Features) *int { return &f.StreamLength },
+		fromDocument(func(in *featureInput) float64 { return float64(in.doc.Metadata.DocLength) })),
+	floatFeature("SumStreamLengthNormalizedTF", 50, 0, func(f *Features) *float64 { return &f.SumStreamLengthNormalizedTF },
+		fromDocument(func(in *featureInput) float64 { return in.normalizedTermFrequencies().sum })),
+	floatFeature("MinStreamLengthNormalizedTF", 55, 0, func(f *Features) *float64 { return &f.MinStreamLengthNormalizedTF },
+		fromDocument(func(in *featureInput) float64 { return in.normalizedTermFrequencies().min })),
+	floatFeature("MaxStreamLengthNormalizedTF", 60, 0, func(f *Features) *float64 { return &f.MaxStreamLengthNormalizedTF },
+		fromDocument(func(in *featureInput) float64 { return in.normalizedTermFrequencies().max })),
+	floatFeature("MeanStreamLengthNormalizedTF", 65, 0, func(f *Features) *float64 { return &f.MeanStreamLengthNormalizedTF },
+		fromDocument(func(in *featureInput) float64 { return in.normalizedTermFrequencies().mean })),
+	floatFeature("VarianceStreamLengthNormalizedTF", 70, 0, func(f *Features) *float64 { return &f.VarianceStreamLengthNormalizedTF },
+		fromDocument(func(in *featureInput) float64 { return in.normalizedTermFrequencies().variance })),
+
+	// Inverse Document Frequency (IDF)
+	floatFeature("SumTFIDF", 75, 0, func(f *Features) *float64 { return &f.SumTFIDF },
+		fromDocument(func(in *featureInput) float64 { return in.tfidf().sum })),
+	floatFeature("MinTFIDF", 80, 0, func(f *Features) *float64 { return &f.MinTFIDF },
+		fromDocument(func(in *featureInput) float64 { return in.tfidf().min })),
+	floatFeature("MaxTFIDF", 85, 0, func(f *Features) *float64 { return &f.MaxTFIDF },
+		fromDocument(func(in *featureInput) float64 { return in.tfidf().max })),
+	floatFeature("MeanTFIDF", 90, 0, func(f *Features) *float64 { return &f.MeanTFIDF },
+		fromDocument(func(in *featureInput) float64 { return in.tfidf().mean })),
+	floatFeature("VarianceTFIDF", 95, 0, func(f *Features) *float64 { return &f.VarianceTFIDF },
+		fromDocument(func(in *featureInput) float64 { return in.tfidf().variance })),
+
+	// BM25 score for the document/query
+	floatFeature("BM25", 110, 0, func(f *Features) *float64 { return &f.BM25 },
+		fromDocument(func(in *featureInput) float64 {
+			return calculateBM25(in.query, in.doc.TermFrequencies, in.idf, in.doc.Metadata.DocLength, in.avgDocLength)
+		})),
+
+	// URL characteristics
+	intFeature("NumSlashesInURL", 126, 0, func(f *Features) *int { return &f.NumSlashesInURL },
+		fromDocument(func(in *featureInput) float64 {
+			numSlashes, _ := analyzeURL(in.doc.Metadata.URL)
+			return float64(numSlashes)
+		})),
+	intFeature("LengthOfURL", 127, 0, func(f *Features) *int { return &f.LengthOfURL },
+		fromDocument(func(in *featureInput) float64 {
+			_, length := analyzeURL(in.doc.Metadata.URL)
+			return float64(length)
+		})),
+
+	// Link Analysis Metrics
+	intFeature("InlinkCount", 128, 0, func(f *Features) *int { return &f.InlinkCount },
+		fromLinks(func(links PageRankInfo) float64 { return float64(links.InLinkCount) })),
+	intFeature("OutlinkCount", 129, 0, func(f *Features) *int { return &f.OutlinkCount },
+		fromLinks(func(links PageRankInfo) float64 { return float64(links.OutLinkCount) })),
+	floatFeature("PageRank", 130, 0, func(f *Features) *float64 { return &f.PageRank },
+		fromLinks(func(links PageRankInfo) float64 { return links.PageRank })),
+}
+
+// LookupFeature returns the definition of the feature with the given name
+func LookupFeature(name string) (FeatureDef, error) {
+	for _, def := range FeatureRegistry {
+		if def.Name == name {
+			return def, nil
+		}
+	}
+	return FeatureDef{}, fmt.Errorf("unknown feature %q", name)
+}
+
+// DefaultFeatures returns features set to the default of every feature
+func DefaultFeatures() Features {
+	var f Features
+	for _, def := range FeatureRegistry {
+		def.Set(&f, def.Default)
+	}
+	return f
+}
@@ -0,0 +1,122 @@
+package ranking
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestFeatureRegistry(t *testing.T) {
+	// Every Features field is registered once, with its type, and the registry reads and writes that field
+	fields := reflect.TypeOf(Features{})
+	if len(FeatureRegistry) != fields.NumField() {
+		t.Fatalf("FeatureRegistry has %d features, Features has %d fields", len(FeatureRegistry), fields.NumField())
+	}
+	names := make(map[string]bool)
+	ids := make(map[int]bool)
+	for i, def := range FeatureRegistry {
+		if names[def.Name] || ids[def.MSLRID] {
+			t.Errorf("feature %s (MSLR ID %d) is registered twice", def.Name, def.MSLRID)
+		}
+		names[def.Name], ids[def.MSLRID] = true, true
+		if def.extract == nil {
+			t.Errorf("feature %s has no extractor", def.Name)
+		}
+
+		field, ok := fields.FieldByName(def.Name)
+		if !ok {
+			t.Errorf("feature %s is not a Features field", def.Name)
+			continue
+		}
+		if got := field.Type.Kind().String(); got != def.Type.String() {
+			t.Errorf("feature %s has type %s, the field is %s", def.Name, def.Type, got)
+			continue
+		}
+
+		var f Features
+		def.Set(&f, float64(i+1))
+		value := reflect.ValueOf(f).FieldByIndex(field.Index)
+		if (def.Type == IntFeature && value.Int() != int64(i+1)) || (def.Type == FloatFeature && value.Float() != float64(i+1)) {
+			t.Errorf("Set(%d) of feature %s set the field to %v", i+1, def.Name, value)
+		}
+		if got := def.Value(&f); got != float64(i+1) {
+			t.Errorf("feature %s Value() = %v after Set(%d)", def.Name, got, i+1)
+		}
+		for _, other := range FeatureRegistry {
+			if other.Name != def.Name && other.Value(&f) != 0 {
+				t.Errorf("Set() of %s changed %s", def.Name, other.Name)
+			}
+		}
+	}
+
+	if _, err := LookupFeature("BM25"); err != nil {
+		t.Errorf("LookupFeature(BM25) error = %v", err)
+	}
+	if _, err := LookupFeature("IDF"); err == nil {
+		t.Errorf("LookupFeature(IDF) succeeded")
+	}
+	defaults := DefaultFeatures()
+	for _, def := range FeatureRegistry {
+		if got := def.Value(&defaults); got != def.Default {
+			t.Errorf("DefaultFeatures() has %s = %v, want %v", def.Name, got, def.Default)
+		}
+	}
+}
+
+func TestDefaultFeatures(t *testing.T) {
+	// Give the link analysis features non-zero defaults for this test
+	registry := slices.Clone(FeatureRegistry)
+	t.Cleanup(func() { FeatureRegistry = registry })
+	FeatureRegistry = slices.Clone(registry)
+	for i, def := range FeatureRegistry {
+		switch def.Name {
+		case "InlinkCount":
+			FeatureRegistry[i] = intFeature(def.Name, def.MSLRID, 3, def.int, def.extract)
+		case "PageRank":
+			FeatureRegistry[i] = floatFeature(def.Name, def.MSLRID, 0.25, def.float, def.extract)
+		}
+	}
+
+	want := Features{InlinkCount: 3, PageRank: 0.25}
+	if got := DefaultFeatures(); got != want {
+		t.Errorf("DefaultFeatures() = %+v, want %+v", got, want)
+	}
+
+	// New documents start from the defaults and keep them when their metadata cannot be fetched
+	docs, err := getDocuments(invertibleIndex{"term1": {{DocID: "doc1", Frequency: 1}}})
+	if err != nil {
+		t.Fatalf("getDocuments() error = %v", err)
+	}
+	if got := docs[0].Features; got != want {
+		t.Errorf("getDocuments() features = %+v, want %+v", got, want)
+	}
+	client := createMockHTTPClient(map[string]string{}, map[string]error{}, http.StatusOK)
+	if err := docs.initializeFeatures(Query{Terms: []string{"term1"}}, totalDocStatistics{AvgDocLength: 1, DocCount: 1}, invertibleIndex{}, client); err == nil {
+		t.Fatalf("initializeFeatures() without metadata succeeded")
+	}
+	if got := docs[0].Features; got != want {
+		t.Errorf("initializeFeatures() without metadata features = %+v, want %+v", got, want)
+	}
+}
+
+func TestDocument_calculateFeatures_registry(t *testing.T) {
+	// Features are computed by the extractors of the registry, link features only when the link analysis is available
+	registry := slices.Clone(FeatureRegistry)
+	t.Cleanup(func() { FeatureRegistry = registry })
+	FeatureRegistry = slices.Clone(registry)
+	for i, def := range FeatureRegistry {
+		if def.Name == "BM25" {
+			FeatureRegistry[i].extract = fromDocument(func(in *featureInput) float64 { return float64(len(in.query.Terms)) })
+		}
+	}
+
+	doc := Document{DocID: "doc1", Features: Features{PageRank: 0.25}, Metadata: DocumentMetadata{URL: "https://example.com"}}
+	client := createMockHTTPClient(map[string]string{}, map[string]error{}, http.StatusOK)
+	if err := doc.calculateFeatures(Query{Terms: []string{"term1", "term2"}}, map[string]float64{}, 1, client); err == nil {
+		t.Fatalf("calculateFeatures() without link analysis succeeded")
+	}
+	if doc.Features.BM25 != 2 || doc.Features.LengthOfURL != len("https://example.com") || doc.Features.PageRank != 0.25 {
+		t.Errorf("calculateFeatures() features = %+v, want BM25 2, the URL length and the previous PageRank", doc.Features)
+	}
+}
@@ -2,6 +2,7 @@ package ranking
 
 import (
 	"strings"
+	"time"
 )
 
 // Max number of documents to return
@@ -11,6 +12,23 @@ const maxDocuments = 1000
 const k1 = 1.2
 const b = 0.75
 
+// BM25Params are the term frequency saturation (K1) and length normalization (B) parameters of BM25
+type BM25Params struct {
+	K1 float64 `json:"k1"`
+	B  float64 `json:"b"`
+}
+
+// DefaultBM25Params are the parameters used for the BM25 feature unless a ranking overrides them
+var DefaultBM25Params = BM25Params{K1: k1, B: b}
+
+// orDefault replaces unset parameters with the defaults
+func (p BM25Params) orDefault() BM25Params {
+	if p == (BM25Params{}) {
+		return DefaultBM25Params
+	}
+	return p
+}
+
 // Tolerance for floating-point comparison in tests
 const epsilon = 1e-12
 
@@ -29,13 +47,48 @@ func (q *Query) tokenize() {
 type Document struct {
 	DocID           string           `json:"docID"`
 	Rank            int              `json:"rank"`
+	Score           float64          `json:"score"`
 	Metadata        DocumentMetadata `json:"metadata"`
 	TermFrequencies map[string]int   // helper variable to store the documents terms for efficient feature construction
 	Features        Features         // ranking features
+	Explanation     *Explanation     `json:"explanation,omitempty"` // score breakdown, only set when requested
 }
 
 type Documents []Document
 
+// RankOptions controls how RankDocumentsWithOptions scores and pages the ranked documents
+type RankOptions struct {
+	Model   PairwiseModel // model used to order the top documents, nil ranks by BM25
+	TopK    int           // number of documents to return, 0 returns all ranked documents
+	Offset  int           // number of ranked documents to skip before returning TopK
+	Filter  Filter        // restrictions on the document metadata
+	Explain bool          // attach an Explanation to every returned document
+	BM25    BM25Params    // BM25 parameters, zero uses DefaultBM25Params
+	// ModelName, ModelVersion and Experiment describe the ranking in its capture
+	ModelName    string
+	ModelVersion string
+	Experiment   string // experiment bucket of the request, empty outside experiments
+	NoCapture    bool   // skip the capture, e.g. for rankings that are not served
+}
+
+// Filter restricts which documents are eligible to be ranked. Zero values disable the corresponding check.
+type Filter struct {
+	FileTypes     []string  `json:"fileTypes,omitempty"`     // allowed document types, compared case-insensitively
+	MinDocLength  int       `json:"minDocLength,omitempty"`  // minimum document length
+	MaxDocLength  int       `json:"maxDocLength,omitempty"`  // maximum document length
+	UpdatedAfter  time.Time `json:"updatedAfter,omitempty"`  // only documents last updated after this time
+	UpdatedBefore time.Time `json:"updatedBefore,omitempty"` // only documents last updated before this time
+	URLPrefix     string    `json:"urlPrefix,omitempty"`     // only documents whose URL starts with this prefix
+}
+
+// RankResult holds the ranked documents returned by RankDocumentsWithOptions along with statistics about the run
+type RankResult struct {
+	Documents       []Document    // ranked documents after filtering and paging
+	TotalCandidates int           // number of documents matching any query term
+	TotalRanked     int           // number of documents ranked after filtering and truncation to maxDocuments
+	InferenceTime   time.Duration // time spent ordering documents with the model
+}
+
 // DocumentMetadata holds metadata information about a document
 type DocumentMetadata struct {
 	DocLength       int    `json:"docLength"`
@@ -47,6 +100,7 @@ type DocumentMetadata struct {
 }
 
 // Features holds various statistical and computed features related to a document/query.
+// Each field is declared in FeatureRegistry, which vectors, diffs and schemas are generated from.
 type Features struct {
 	// Covered Query Term Metrics
 	CoveredQueryTermNumber int     // Number of query terms covered
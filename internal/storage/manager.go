@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/logging"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var logger = logging.For("storage")
+
+// compressedSuffix is appended to files compacted by the manager
+const compressedSuffix = ".gz"
+
+// Policy is the quota and retention policy of a subdirectory of the managed root
+type Policy struct {
+	Dir          string        `json:"dir"`          // subdirectory relative to the root
+	MaxBytes     int64         `json:"maxBytes"`     // quota, oldest files are deleted above it, 0 disables
+	MaxAge       time.Duration `json:"maxAge"`       // files older than this are deleted, 0 disables
+	CompactAfter time.Duration `json:"compactAfter"` // files older than this are gzipped, 0 disables
+	Skip         []string      `json:"skip"`         // names of files that are still being written, never compacted or deleted
+}
+
+// Usage is the number of bytes used by the root and each policy subdirectory
+type Usage struct {
+	Total int64            `json:"total"`
+	Dirs  map[string]int64 `json:"dirs"`
+}
+
+// Report summarizes a single retention run
+type Report struct {
+	Compacted  int   `json:"compacted"`  // files gzipped
+	Deleted    int   `json:"deleted"`    // files removed by age or quota
+	FreedBytes int64 `json:"freedBytes"` // bytes reclaimed by compaction and deletion
+	UsageAfter Usage `json:"usageAfter"` // usage after the run
+}
+
+// Manager tracks byte usage below a root directory and applies per-subdirectory policies
+type Manager struct {
+	root     string
+	policies []Policy
+
+	mu    sync.RWMutex
+	usage Usage
+}
+
+// NewManager creates a manager for root and measures its current usage
+func NewManager(root string, policies []Policy) (*Manager, error) {
+	m := &Manager{root: root, policies: policies}
+	if _, err := m.Refresh(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Usage returns the usage measured by the last refresh or retention run
+func (m *Manager) Usage() Usage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.usage
+}
+
+// TotalBytes returns the total bytes used below the root at the last refresh
+func (m *Manager) TotalBytes() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.Usage().Total
+}
+
+// Allow reports whether the subdirectory is below its quota, so new files may be written to it
+func (m *Manager) Allow(dir string) bool {
+	if m == nil {
+		return true
+	}
+	for _, policy := range m.policies {
+		if policy.Dir == dir && policy.MaxBytes > 0 {
+			m.mu.RLock()
+			used := m.usage.Dirs[dir]
+			m.mu.RUnlock()
+			return used < policy.MaxBytes
+		}
+	}
+	return true
+}
+
+// Refresh measures the usage of the root and of every policy subdirectory
+func (m *Manager) Refresh() (Usage, error) {
+	total, err := DirSize(m.root)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage := Usage{Total: total, Dirs: make(map[string]int64, len(m.policies))}
+	for _, policy := range m.policies {
+		size, err := DirSize(filepath.Join(m.root, policy.Dir))
+		if err != nil {
+			return Usage{}, err
+		}
+		usage.Dirs[policy.Dir] = size
+	}
+
+	m.mu.Lock()
+	m.usage = usage
+	m.mu.Unlock()
+	return usage, nil
+}
+
+// Enforce compacts and deletes files according to the policies, oldest first
+func (m *Manager) Enforce(now time.Time) (Report, error) {
+	var report Report
+	for _, policy := range m.policies {
+		if err := m.enforce(policy, now, &report); err != nil {
+			return report, fmt.Errorf("error enforcing policy for %s: %v", policy.Dir, err)
+		}
+	}
+
+	usage, err := m.Refresh()
+	if err != nil {
+		return report, err
+	}
+	report.UsageAfter = usage
+	return report, nil
+}
+
+// Run enforces the policies every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := m.Enforce(time.Now())
+		if err != nil {
+			logger.Warn("storage retention failed", "error", err)
+		} else if report.Compacted > 0 || report.Deleted > 0 {
+			logger.Info("storage retention", "compacted", report.Compacted, "deleted", report.Deleted,
+				"freedBytes", report.FreedBytes, "totalBytes", report.UsageAfter.Total)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// file is a regular file considered for retention
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (m *Manager) enforce(policy Policy, now time.Time, report *Report) error {
+	files, err := listFiles(filepath.Join(m.root, policy.Dir))
+	if err != nil {
+		return err
+	}
+
+	// Delete expired files and compact the ones past the compaction age
+	kept := files[:0]
+	var skipped int64
+	for _, f := range files {
+		if slices.Contains(policy.Skip, filepath.Base(f.path)) {
+			skipped += f.size
+			continue
+		}
+		age := now.Sub(f.modTime)
+		switch {
+		case policy.MaxAge > 0 && age > policy.MaxAge:
+			if err := os.Remove(f.path); err != nil {
+				return err
+			}
+			report.Deleted++
+			report.FreedBytes += f.size
+			continue
+		case policy.CompactAfter > 0 && age > policy.CompactAfter && !strings.HasSuffix(f.path, compressedSuffix):
+			compacted, err := compact(f)
+			if err != nil {
+				return err
+			}
+			report.Compacted++
+			report.FreedBytes += f.size - compacted.size
+			f = compacted
+		}
+		kept = append(kept, f)
+	}
+
+	// Delete the oldest files until the directory is within its quota
+	if policy.MaxBytes <= 0 {
+		return nil
+	}
+	used := skipped
+	for _, f := range kept {
+		used += f.size
+	}
+	for _, f := range kept {
+		if used <= policy.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+		used -= f.size
+		report.Deleted++
+		report.FreedBytes += f.size
+	}
+	return nil
+}
+
+// listFiles returns the regular files below dir sorted from oldest to newest
+func listFiles(dir string) ([]file, error) {
+	var files []file
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	return files, nil
+}
+
+// compact gzips a file next to the original, keeping its modification time, and removes the original
+func compact(f file) (file, error) {
+	compactedPath := f.path + compressedSuffix
+	err := func() error {
+		in, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.Create(compactedPath)
+		if err != nil {
+			return err
+		}
+		writer := gzip.NewWriter(out)
+		if _, err := io.Copy(writer, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}()
+	if err != nil {
+		os.Remove(compactedPath)
+		return file{}, fmt.Errorf("error compacting %s: %v", f.path, err)
+	}
+
+	if err := os.Chtimes(compactedPath, f.modTime, f.modTime); err != nil {
+		return file{}, err
+	}
+	info, err := os.Stat(compactedPath)
+	if err != nil {
+		return file{}, err
+	}
+	if err := os.Remove(f.path); err != nil {
+		return file{}, err
+	}
+	return file{path: compactedPath, size: info.Size(), modTime: f.modTime}, nil
+}
+
+// DirSize returns the total size in bytes of the regular files below dir, 0 if dir does not exist
+func DirSize(dir string) (int64, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	return size, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile creates a file of the given size with its modification time set age before now
+func writeFile(t *testing.T, path string, size int, now time.Time, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("a", size)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := now.Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDirSize(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(root, "a"), 10, now, 0)
+	writeFile(t, filepath.Join(root, "sub", "b"), 20, now, 0)
+
+	tests := []struct {
+		name string
+		dir  string
+		want int64
+	}{
+		{"Nested files", root, 30},
+		{"Subdirectory", filepath.Join(root, "sub"), 20},
+		{"Missing directory", filepath.Join(root, "missing"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DirSize(tt.dir)
+			if err != nil {
+				t.Fatalf("DirSize() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DirSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_Enforce(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	raw := filepath.Join(root, "raw")
+	writeFile(t, filepath.Join(raw, "expired"), 100, now, 10*24*time.Hour)
+	writeFile(t, filepath.Join(raw, "old"), 1000, now, 2*time.Hour)
+	writeFile(t, filepath.Join(raw, "recent1"), 300, now, 3*time.Minute)
+	writeFile(t, filepath.Join(raw, "recent2"), 300, now, 2*time.Minute)
+	writeFile(t, filepath.Join(raw, "recent3"), 300, now, time.Minute)
+	writeFile(t, filepath.Join(raw, "active"), 50, now, 10*24*time.Hour)
+	writeFile(t, filepath.Join(root, "other", "kept"), 50, now, 10*24*time.Hour)
+
+	m, err := NewManager(root, []Policy{{Dir: "raw", MaxBytes: 700, MaxAge: 7 * 24 * time.Hour, CompactAfter: time.Hour, Skip: []string{"active"}}})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if got := m.Usage().Dirs["raw"]; got != 2050 {
+		t.Errorf("initial raw usage = %d, want 2050", got)
+	}
+	if m.Allow("raw") {
+		t.Errorf("Allow() = true for a directory over its quota")
+	}
+
+	report, err := m.Enforce(now)
+	if err != nil {
+		t.Fatalf("Enforce() error = %v", err)
+	}
+
+	// The expired file is deleted, the old file is compacted, then the oldest files go until the quota holds
+	if report.Compacted != 1 {
+		t.Errorf("Enforce() compacted %d files, want 1", report.Compacted)
+	}
+	if exists(filepath.Join(raw, "expired")) || exists(filepath.Join(raw, "old")) {
+		t.Errorf("Enforce() kept an expired or compacted original")
+	}
+	if exists(filepath.Join(raw, "recent1")) || !exists(filepath.Join(raw, "recent2")) || !exists(filepath.Join(raw, "recent3")) {
+		t.Errorf("Enforce() did not delete the oldest files first")
+	}
+	if !exists(filepath.Join(raw, "active")) {
+		t.Errorf("Enforce() deleted a skipped file")
+	}
+	if !exists(filepath.Join(root, "other", "kept")) {
+		t.Errorf("Enforce() deleted a file outside of the policy directories")
+	}
+	if got := report.UsageAfter.Dirs["raw"]; got > 700 {
+		t.Errorf("raw usage after Enforce() = %d, want at most 700", got)
+	}
+	if report.UsageAfter.Total != m.TotalBytes() || !m.Allow("raw") {
+		t.Errorf("Enforce() did not refresh the usage")
+	}
+}
+
+func TestManager_Nil(t *testing.T) {
+	var m *Manager
+	if !m.Allow("raw") || m.TotalBytes() != 0 {
+		t.Errorf("nil Manager should allow writes and report no usage")
+	}
+}
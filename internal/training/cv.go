@@ -0,0 +1,116 @@
+package training
+
+import (
+	"fmt"
+	"math/rand"
+	"rpi-search-ranking/internal/features"
+	"sort"
+)
+
+// Fold holds the indices of the training and validation examples of a cross-validation fold
+type Fold struct {
+	Train      []int
+	Validation []int
+}
+
+// GroupKFold splits n examples into numFolds folds so that every pair of a query is validated in the same fold,
+// keeping pairs of a validated query out of its training examples. qids holds the query of each example; when nil,
+// as in datasets written before queries were kept, every example is its own group. Queries are assigned largest
+// first to the fold with the fewest examples, balancing the folds.
+func GroupKFold(qids []int, n, numFolds int) ([]Fold, error) {
+	if qids != nil && len(qids) != n {
+		return nil, fmt.Errorf("%d query IDs for %d examples", len(qids), n)
+	}
+	groups := exampleGroups(qids, n)
+	if numFolds < 2 || numFolds > len(groups) {
+		return nil, fmt.Errorf("cannot split %d queries into %d folds", len(groups), numFolds)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i]) > len(groups[j]) })
+	validation := make([][]int, numFolds)
+	for _, group := range groups {
+		smallest := 0
+		for fold := range validation {
+			if len(validation[fold]) < len(validation[smallest]) {
+				smallest = fold
+			}
+		}
+		validation[smallest] = append(validation[smallest], group...)
+	}
+
+	folds := make([]Fold, numFolds)
+	for fold := range folds {
+		sort.Ints(validation[fold])
+		folds[fold].Validation = validation[fold]
+		folds[fold].Train = make([]int, 0, n-len(validation[fold]))
+		for other := range validation {
+			if other != fold {
+				folds[fold].Train = append(folds[fold].Train, validation[other]...)
+			}
+		}
+		sort.Ints(folds[fold].Train)
+	}
+	return folds, nil
+}
+
+// GroupSplit holds out the examples of randomly chosen queries, about fraction of all n examples, for validation.
+// qids is as in GroupKFold.
+func GroupSplit(qids []int, n int, fraction float64) (Fold, error) {
+	if qids != nil && len(qids) != n {
+		return Fold{}, fmt.Errorf("%d query IDs for %d examples", len(qids), n)
+	}
+	if fraction <= 0 || fraction >= 1 {
+		return Fold{}, fmt.Errorf("validation fraction must be between 0 and 1, got %v", fraction)
+	}
+	groups := exampleGroups(qids, n)
+	rand.Shuffle(len(groups), func(i, j int) { groups[i], groups[j] = groups[j], groups[i] })
+
+	var split Fold
+	for _, group := range groups {
+		if float64(len(split.Validation)) < fraction*float64(n) {
+			split.Validation = append(split.Validation, group...)
+		} else {
+			split.Train = append(split.Train, group...)
+		}
+	}
+	if len(split.Train) == 0 {
+		return Fold{}, fmt.Errorf("no training examples left after holding out %v of %d queries", fraction, len(groups))
+	}
+	sort.Ints(split.Train)
+	sort.Ints(split.Validation)
+	return split, nil
+}
+
+// exampleGroups returns the indices of the examples of each query, in order of first appearance
+func exampleGroups(qids []int, n int) [][]int {
+	if qids == nil {
+		groups := make([][]int, n)
+		for i := range groups {
+			groups[i] = []int{i}
+		}
+		return groups
+	}
+	var groups [][]int
+	index := make(map[int]int) // QID -> index in groups
+	for i, qid := range qids {
+		g, ok := index[qid]
+		if !ok {
+			g = len(groups)
+			index[qid] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// Subset returns copies of the examples at the indices, leaving X and Y unchanged
+func Subset(X []features.Vector, Y []int, indices []int) ([]features.Vector, []int) {
+	XSubset := make([]features.Vector, len(indices))
+	YSubset := make([]int, len(indices))
+	for i, index := range indices {
+		XSubset[i] = X[index]
+		YSubset[i] = Y[index]
+	}
+	return XSubset, YSubset
+}
@@ -0,0 +1,108 @@
+package training
+
+import (
+	"math/rand"
+	"reflect"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestGroupKFold(t *testing.T) {
+	qids := []int{1, 2, 1, 3, 3, 3, 4, 2, 5, 1}
+	folds, err := GroupKFold(qids, len(qids), 3)
+	if err != nil {
+		t.Fatalf("GroupKFold() error = %v", err)
+	}
+
+	validated := make(map[int]int)
+	for f, fold := range folds {
+		if len(fold.Train)+len(fold.Validation) != len(qids) {
+			t.Errorf("fold %d has %d examples, want %d", f, len(fold.Train)+len(fold.Validation), len(qids))
+		}
+		validationQueries := make(map[int]bool)
+		for _, i := range fold.Validation {
+			validationQueries[qids[i]] = true
+			validated[i]++
+		}
+		for _, i := range fold.Train {
+			if validationQueries[qids[i]] {
+				t.Errorf("fold %d trains on query %d, which it validates", f, qids[i])
+			}
+		}
+	}
+	if len(validated) != len(qids) {
+		t.Errorf("%d examples validated, want each of the %d once", len(validated), len(qids))
+	}
+
+	// Without query IDs every example is its own group
+	if folds, err := GroupKFold(nil, 4, 2); err != nil || len(folds[0].Validation) != 2 {
+		t.Errorf("GroupKFold(nil) = %v, %v, want 2 examples per fold", folds, err)
+	}
+	if _, err := GroupKFold([]int{1, 1, 2}, 3, 3); err == nil {
+		t.Errorf("GroupKFold() with fewer queries than folds succeeded")
+	}
+}
+
+func TestGroupSplit(t *testing.T) {
+	qids := []int{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
+	split, err := GroupSplit(qids, len(qids), 0.3)
+	if err != nil {
+		t.Fatalf("GroupSplit() error = %v", err)
+	}
+	if len(split.Validation) != 4 || len(split.Train) != 6 {
+		t.Errorf("GroupSplit() = %v, want the 4 examples of 2 queries held out", split)
+	}
+	inValidation := make(map[int]bool)
+	for _, i := range split.Validation {
+		inValidation[i] = true
+	}
+	for i := 0; i < len(qids); i += 2 {
+		if inValidation[i] != inValidation[i+1] {
+			t.Errorf("query %d is split between training and validation", qids[i])
+		}
+	}
+}
+
+func TestSubset_NoAliasing(t *testing.T) {
+	X := []features.Vector{{0}, {1}, {2}, {3}}
+	Y := []int{1, -1, 1, -1}
+	XSubset, YSubset := Subset(X, Y, []int{0, 3})
+	XSubset[1] = features.Vector{9}
+	YSubset[1] = 1
+	if !reflect.DeepEqual(X, []features.Vector{{0}, {1}, {2}, {3}}) || !reflect.DeepEqual(Y, []int{1, -1, 1, -1}) {
+		t.Errorf("Subset() changed the examples to %v, %v", X, Y)
+	}
+}
+
+func TestTrain_EarlyStopping(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	schema := features.NumberedSchema(2)
+	examples := func(n int) ([]features.Vector, []int) {
+		X := make([]features.Vector, n)
+		Y := make([]int, n)
+		for i := range X {
+			X[i] = features.Vector{r.NormFloat64(), r.NormFloat64()}
+			Y[i] = -1
+			if X[i][0]+0.1*r.NormFloat64() > 0 {
+				Y[i] = 1
+			}
+		}
+		return X, Y
+	}
+	XTrain, YTrain := examples(200)
+	XVal, YVal := examples(100)
+
+	lr := NewLogisticRegression(0.01, schema)
+	options := TrainOptions{Epochs: 200, Schedule: ConstantRate(0.5), EarlyStopping: &EarlyStopping{X: XVal, Y: YVal, Patience: 3}}
+	if err := lr.Train(XTrain, YTrain, options); err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	if accuracy := evaluateModel(lr, XVal, YVal); accuracy < 90 {
+		t.Errorf("validation accuracy = %.1f%%, want at least 90%%", accuracy)
+	}
+
+	options.EarlyStopping = &EarlyStopping{X: XVal, Y: YVal[:1]}
+	if err := lr.Train(XTrain, YTrain, options); err == nil {
+		t.Errorf("Train() with mismatched validation labels succeeded")
+	}
+}
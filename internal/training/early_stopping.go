@@ -0,0 +1,71 @@
+package training
+
+import (
+	"fmt"
+	"math"
+	"rpi-search-ranking/internal/features"
+)
+
+// EarlyStopping stops training once the loss on held-out validation examples has not improved for Patience epochs,
+// keeping the weights of the epoch with the lowest validation loss
+type EarlyStopping struct {
+	X        []features.Vector
+	Y        []int
+	Patience int // defaults to 5
+}
+
+// earlyStopper tracks the validation loss of every epoch for the trainers
+type earlyStopper struct {
+	patience      int
+	bestLoss      float64
+	noImprovement int
+}
+
+// newEarlyStopper returns a stopper for the options, or nil when stopping is nil
+func newEarlyStopper(stopping *EarlyStopping) (*earlyStopper, error) {
+	if stopping == nil {
+		return nil, nil
+	}
+	if len(stopping.X) != len(stopping.Y) {
+		return nil, fmt.Errorf("number of validation features (%d) does not match number of labels (%d)", len(stopping.X), len(stopping.Y))
+	}
+	patience := 5
+	if stopping.Patience > 0 {
+		patience = stopping.Patience
+	}
+	return &earlyStopper{patience: patience, bestLoss: math.Inf(1)}, nil
+}
+
+// observe records the validation loss of an epoch. improved reports a new lowest loss, whose weights the trainer
+// keeps, and stop that the loss has not improved for patience epochs.
+func (s *earlyStopper) observe(valLoss float64) (improved, stop bool) {
+	if valLoss < s.bestLoss {
+		s.bestLoss = valLoss
+		s.noImprovement = 0
+		return true, false
+	}
+	s.noImprovement++
+	return false, s.noImprovement >= s.patience
+}
+
+// hasBest reports whether the stopper saw a validation loss, so that the trainer restores its best weights
+func (s *earlyStopper) hasBest() bool {
+	return s != nil && !math.IsInf(s.bestLoss, 1)
+}
+
+// logStop prints the epoch at which training stops early
+func (s *earlyStopper) logStop(epoch int) {
+	fmt.Printf("Early stopping at epoch %d, best validation loss: %.4f\n", epoch, s.bestLoss)
+}
+
+// logEpoch prints the training loss every 10 epochs, followed by the validation loss unless it is NaN
+func logEpoch(epoch int, loss, valLoss float64) {
+	if epoch%10 != 0 {
+		return
+	}
+	if math.IsNaN(valLoss) {
+		fmt.Printf("Epoch %d, Loss: %.4f\n", epoch, loss)
+		return
+	}
+	fmt.Printf("Epoch %d, Loss: %.4f, Validation Loss: %.4f\n", epoch, loss, valLoss)
+}
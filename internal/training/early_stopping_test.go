@@ -0,0 +1,48 @@
+package training
+
+import (
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestEarlyStopper_observe(t *testing.T) {
+	stopper, err := newEarlyStopper(&EarlyStopping{Patience: 2})
+	if err != nil {
+		t.Fatalf("newEarlyStopper() error = %v", err)
+	}
+	if stopper.hasBest() {
+		t.Errorf("hasBest() before any epoch = true")
+	}
+
+	tests := []struct {
+		valLoss      float64
+		wantImproved bool
+		wantStop     bool
+	}{
+		{1.0, true, false},
+		{0.8, true, false},
+		{0.9, false, false},
+		{0.7, true, false},
+		{0.7, false, false},
+		{0.75, false, true},
+	}
+	for i, tt := range tests {
+		improved, stop := stopper.observe(tt.valLoss)
+		if improved != tt.wantImproved || stop != tt.wantStop {
+			t.Errorf("epoch %d: observe(%v) = %v, %v, want %v, %v", i, tt.valLoss, improved, stop, tt.wantImproved, tt.wantStop)
+		}
+	}
+	if !stopper.hasBest() || stopper.bestLoss != 0.7 {
+		t.Errorf("best loss = %v, want 0.7", stopper.bestLoss)
+	}
+
+	if stopper, err := newEarlyStopper(nil); stopper != nil || err != nil || stopper.hasBest() {
+		t.Errorf("newEarlyStopper(nil) = %v, %v, want nil", stopper, err)
+	}
+	if stopper, _ := newEarlyStopper(&EarlyStopping{}); stopper.patience != 5 {
+		t.Errorf("default patience = %d, want 5", stopper.patience)
+	}
+	if _, err := newEarlyStopper(&EarlyStopping{X: []features.Vector{{1}}}); err == nil {
+		t.Errorf("newEarlyStopper() with missing validation labels succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package training
+
+import (
+	"math"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Minimum number of rows of a gradient shard, below which goroutines cost more than they save
+const minShardRows = 4096
+
+// gradientShard holds the results of the gradient computation of a range of rows
+type gradientShard struct {
+	grad     *mat.VecDense // summed gradient of the weights
+	biasGrad float64       // summed gradient of the bias
+	loss     float64       // summed log loss
+}
+
+// gradientComputer computes the log loss gradient of a logistic regression over the rows of a standardized matrix,
+// splitting them across goroutines. Its buffers are reused between batches.
+type gradientComputer struct {
+	residual *mat.VecDense // predictions minus labels of every row, each shard using its own range
+	shards   []*gradientShard
+}
+
+// newGradientComputer returns a computer for batches of up to maxRows rows of numFeatures features on up to workers
+// goroutines
+func newGradientComputer(maxRows, numFeatures, workers int) *gradientComputer {
+	numShards := max(1, min(workers, (maxRows+minShardRows-1)/minShardRows))
+	c := &gradientComputer{residual: mat.NewVecDense(maxRows, nil), shards: make([]*gradientShard, numShards)}
+	for i := range c.shards {
+		c.shards[i] = &gradientShard{grad: mat.NewVecDense(numFeatures, nil)}
+	}
+	return c
+}
+
+// compute sets grad to the summed gradient of the log loss over the rows of X with labels y of 1 and 0, with the
+// bias gradient last, and returns the summed loss. Regularization is left to the caller.
+func (c *gradientComputer) compute(X *mat.Dense, y *mat.VecDense, weights *mat.VecDense, bias float64, grad []float64) float64 {
+	rows, _ := X.Dims()
+	numShards := max(1, min(len(c.shards), (rows+minShardRows-1)/minShardRows))
+	if numShards == 1 {
+		c.shards[0].compute(X, y, c.residual.SliceVec(0, rows).(*mat.VecDense), weights, bias)
+	} else {
+		size := (rows + numShards - 1) / numShards
+		var wg sync.WaitGroup
+		for s := 0; s < numShards; s++ {
+			start, end := s*size, min((s+1)*size, rows)
+			wg.Add(1)
+			go func(shard *gradientShard) {
+				defer wg.Done()
+				X := X.Slice(start, end, 0, weights.Len()).(*mat.Dense)
+				shard.compute(X, y.SliceVec(start, end).(*mat.VecDense), c.residual.SliceVec(start, end).(*mat.VecDense), weights, bias)
+			}(c.shards[s])
+		}
+		wg.Wait()
+	}
+
+	// Sum the shards
+	loss := 0.0
+	clear(grad)
+	for _, shard := range c.shards[:numShards] {
+		for j := 0; j < shard.grad.Len(); j++ {
+			grad[j] += shard.grad.AtVec(j)
+		}
+		grad[len(grad)-1] += shard.biasGrad
+		loss += shard.loss
+	}
+	return loss
+}
+
+// compute computes the gradient of the rows of X with matrix-vector products: the residuals are
+// sigmoid(X w + b) - y and the gradient of the weights is X^T residuals
+func (s *gradientShard) compute(X *mat.Dense, y, residual *mat.VecDense, weights *mat.VecDense, bias float64) {
+	rows, _ := X.Dims()
+	residual.MulVec(X, weights)
+
+	s.loss, s.biasGrad = 0, 0
+	raw := residual.RawVector()
+	for i := 0; i < rows; i++ {
+		pi := sigmoid(raw.Data[i*raw.Inc] + bias)
+		yi := y.AtVec(i)
+		s.loss -= yi*math.Log(pi+1e-15) + (1-yi)*math.Log(1-pi+1e-15)
+		raw.Data[i*raw.Inc] = pi - yi
+		s.biasGrad += pi - yi
+	}
+	s.grad.MulVec(X.T(), residual)
+}
@@ -0,0 +1,170 @@
+package training
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+)
+
+// standardizer standardizes feature vectors with the mean and std of the training data of a model
+type standardizer struct {
+	featureMean []float64
+	featureStd  []float64
+}
+
+// standardizeFeatures copies vectors of numFeatures features to a matrix and standardizes it. When the mean and std
+// are not yet known (training phase), they are computed from the vectors, and with center false the mean is left
+// at 0 and the std is the root mean square of each feature.
+func (s *standardizer) standardizeFeatures(vectors []features.Vector, numFeatures int, center bool) (*mat.Dense, error) {
+	numSamples := len(vectors)
+	if numSamples == 0 {
+		return nil, fmt.Errorf("empty feature set")
+	}
+
+	// Initialize feature matrix
+	X := mat.NewDense(numSamples, numFeatures, nil)
+
+	// Fill feature matrix
+	for i, v := range vectors {
+		if len(v) != numFeatures {
+			return nil, fmt.Errorf("feature vector %d has %d features, the schema has %d", i, len(v), numFeatures)
+		}
+		X.SetRow(i, v)
+	}
+
+	// Compute mean and std if not already computed (training phase)
+	if s.featureMean == nil {
+		s.featureMean = make([]float64, numFeatures)
+		s.featureStd = make([]float64, numFeatures)
+
+		// Compute mean
+		if center {
+			for i := 0; i < numSamples; i++ {
+				floats.Add(s.featureMean, X.RawRowView(i))
+			}
+			floats.Scale(1/float64(numSamples), s.featureMean)
+		}
+
+		// Compute std
+		for i := 0; i < numSamples; i++ {
+			for j, val := range X.RawRowView(i) {
+				diff := val - s.featureMean[j]
+				s.featureStd[j] += diff * diff
+			}
+		}
+		for j := range s.featureStd {
+			s.featureStd[j] = math.Sqrt(s.featureStd[j] / float64(numSamples))
+			if s.featureStd[j] == 0 {
+				s.featureStd[j] = 1 // Prevent division by zero
+			}
+		}
+	}
+
+	// Standardize features in place
+	for i := 0; i < numSamples; i++ {
+		row := X.RawRowView(i)
+		floats.Sub(row, s.featureMean)
+		floats.Div(row, s.featureStd)
+	}
+
+	return X, nil
+}
+
+// featureProjection maps ranker features to vectors of the schema of a model. It is computed once when the model
+// is created or loaded, since models convert the features of every document pair they compare.
+type featureProjection struct {
+	registryIndex []int // position in ranking.FeatureRegistry of each feature of the schema, -1 if not computed
+}
+
+// newFeatureProjection returns the projection of ranker features to the schema
+func newFeatureProjection(schema features.Schema) featureProjection {
+	rankingSchema := features.RankingSchema()
+	registryIndex := make([]int, schema.Len())
+	for i, id := range schema.IDs {
+		j, ok := rankingSchema.Index(id)
+		if !ok {
+			j = -1
+		}
+		registryIndex[i] = j
+	}
+	return featureProjection{registryIndex: registryIndex}
+}
+
+// fromFeatures converts ranker features to a vector of the model schema.
+// Features of the schema that the ranker does not compute are 0.
+func (p featureProjection) fromFeatures(f ranking.Features) features.Vector {
+	x := make(features.Vector, len(p.registryIndex))
+	for i, j := range p.registryIndex {
+		if j >= 0 {
+			x[i] = ranking.FeatureRegistry[j].Value(&f)
+		}
+	}
+	return x
+}
+
+// linearModel is a linear function of standardized feature vectors, shared by the linear pairwise learners.
+// The first document of a pair ranks higher when the decision value of their difference is positive.
+type linearModel struct {
+	Weights *mat.VecDense
+	bias    float64
+	lambda  float64         // L2 regularization parameter
+	schema  features.Schema // features of the vectors the model is trained on
+	standardizer
+	featureProjection
+}
+
+// Schema returns the features the model uses
+func (m *linearModel) Schema() features.Schema {
+	return m.schema
+}
+
+// decision returns the decision value of a feature vector of the model schema
+func (m *linearModel) decision(x features.Vector) float64 {
+	if m.Weights == nil {
+		return 0.0
+	}
+
+	z := m.bias
+	for i := range x {
+		z += m.Weights.AtVec(i) * (x[i] - m.featureMean[i]) / m.featureStd[i]
+	}
+	return z
+}
+
+// FeatureContributions attributes the model's decision value to each feature as weight * standardized value
+func (m *linearModel) FeatureContributions(f ranking.Features) []ranking.FeatureContribution {
+	if m.Weights == nil {
+		return nil
+	}
+
+	x := m.fromFeatures(f)
+	contributions := make([]ranking.FeatureContribution, len(x))
+	for i := range x {
+		standardized := (x[i] - m.featureMean[i]) / m.featureStd[i]
+		weight := m.Weights.AtVec(i)
+		contributions[i] = ranking.FeatureContribution{
+			Feature:           m.schema.Names[i],
+			Value:             x[i],
+			StandardizedValue: standardized,
+			Weight:            weight,
+			Contribution:      weight * standardized,
+		}
+	}
+	return contributions
+}
+
+// PredictClass predicts the class (1 or -1) for the feature difference of two ranked documents
+func (m *linearModel) PredictClass(diff ranking.Features) int {
+	return m.PredictVector(m.fromFeatures(diff))
+}
+
+// PredictVector predicts the class (1 or -1) for a feature vector of the model schema
+func (m *linearModel) PredictVector(x features.Vector) int {
+	if m.decision(x) >= 0 {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,42 @@
+package training
+
+import (
+	"path/filepath"
+	"reflect"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+	"testing"
+)
+
+func TestFeatureProjection(t *testing.T) {
+	f := ranking.Features{CoveredQueryTermNumber: 2, BM25: 1.5, InlinkCount: 3, PageRank: 0.25}
+	subset, err := features.ParseSchema("130,1,110")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The projection matches projecting the full ranking vector, with 0 for features the ranker does not compute
+	for _, schema := range []features.Schema{features.RankingSchema(), subset} {
+		want := features.Project(features.FromFeatures(f), features.RankingSchema(), schema)
+		if got := newFeatureProjection(schema).fromFeatures(f); !reflect.DeepEqual(got, want) {
+			t.Errorf("fromFeatures() for schema %v = %v, want %v", schema.IDs, got, want)
+		}
+	}
+
+	// Models keep the projection of their schema through a save and load
+	lr := NewLogisticRegression(0.01, subset)
+	if err := lr.Train([]features.Vector{{1, 0, 1}, {-1, 0, -1}}, []int{1, -1}, TrainOptions{Epochs: 1}); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "logistic.gob")
+	if err := lr.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadLogisticRegression(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.featureProjection, lr.featureProjection) {
+		t.Errorf("loaded projection = %v, want %v", loaded.featureProjection, lr.featureProjection)
+	}
+}
@@ -0,0 +1,369 @@
+package training
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+	"math"
+	"math/rand"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+	"slices"
+)
+
+// MLP represents a multilayer perceptron over the feature differences of document pairs: fully connected ReLU
+// hidden layers and an output logit of the probability that the first document ranks higher. It is trained with
+// Gorgonia and predicts with gonum, so a trained model is safe for concurrent use.
+type MLP struct {
+	hidden []int           // sizes of the hidden layers
+	schema features.Schema // features of the vectors the model is trained on
+	layers []mlpLayer      // hidden layers followed by the output layer, nil before training
+	standardizer
+	featureProjection
+}
+
+// mlpLayer is a fully connected layer
+type mlpLayer struct {
+	weights *mat.Dense // inputs x outputs
+	bias    []float64
+}
+
+// NewMLP creates a new MLP with hidden layers of the given sizes for feature vectors of the schema
+func NewMLP(hidden []int, schema features.Schema) *MLP {
+	return &MLP{
+		hidden:            hidden,
+		schema:            schema,
+		featureProjection: newFeatureProjection(schema),
+	}
+}
+
+// Schema returns the features the model uses
+func (m *MLP) Schema() features.Schema {
+	return m.schema
+}
+
+// MLPOptions configures the training of an MLP
+type MLPOptions struct {
+	Epochs        int
+	BatchSize     int            // examples per update, defaults to 256; the incomplete last batch of an epoch is skipped
+	LearningRate  float64        // learning rate of AdamW, defaults to 0.001
+	WeightDecay   float64        // decoupled weight decay of AdamW, applied to the weights but not the biases
+	Dropout       float64        // probability of zeroing each hidden unit of a training example
+	EarlyStopping *EarlyStopping // stops when the validation loss stops improving, nil trains all epochs
+}
+
+// mlpGraph is the Gorgonia expression graph of the mean cross-entropy of an MLP on a batch of examples
+type mlpGraph struct {
+	g       *G.ExprGraph
+	x, y    *G.Node // standardized examples and their labels of 1 and 0
+	masks   G.Nodes // dropout masks of the hidden layers, empty without dropout
+	weights G.Nodes // weights of each layer
+	biases  G.Nodes // biases of each layer, as 1 x outputs matrices
+	cost    *G.Node // mean cross-entropy of the batch
+	sizes   []int   // inputs of each layer followed by the output size of the last
+}
+
+// newMLPGraph builds the graph of an MLP with layers of the given sizes on batches of batchSize examples,
+// with Glorot-initialized weights and zero biases
+func newMLPGraph(sizes []int, batchSize int, dropout bool) (*mlpGraph, error) {
+	g := G.NewGraph()
+	net := &mlpGraph{
+		g:     g,
+		x:     G.NewMatrix(g, tensor.Float64, G.WithShape(batchSize, sizes[0]), G.WithName("x")),
+		y:     G.NewMatrix(g, tensor.Float64, G.WithShape(batchSize, 1), G.WithName("y")),
+		sizes: sizes,
+	}
+
+	h := net.x
+	for l := 0; l < len(sizes)-1; l++ {
+		w := G.NewMatrix(g, tensor.Float64, G.WithShape(sizes[l], sizes[l+1]), G.WithName(fmt.Sprintf("w%d", l)), G.WithInit(G.GlorotU(1)))
+		b := G.NewMatrix(g, tensor.Float64, G.WithShape(1, sizes[l+1]), G.WithName(fmt.Sprintf("b%d", l)), G.WithInit(G.Zeroes()))
+		net.weights = append(net.weights, w)
+		net.biases = append(net.biases, b)
+
+		z, err := G.Mul(h, w)
+		if err != nil {
+			return nil, err
+		}
+		if z, err = G.BroadcastAdd(z, b, nil, []byte{0}); err != nil {
+			return nil, err
+		}
+		if l < len(sizes)-2 {
+			if z, err = G.Rectify(z); err != nil {
+				return nil, err
+			}
+			if dropout {
+				mask := G.NewMatrix(g, tensor.Float64, G.WithShape(batchSize, sizes[l+1]), G.WithName(fmt.Sprintf("mask%d", l)))
+				net.masks = append(net.masks, mask)
+				if z, err = G.HadamardProd(z, mask); err != nil {
+					return nil, err
+				}
+			}
+		}
+		h = z
+	}
+
+	// The cross-entropy of a logit z with label y is softplus(z) - y z, which is stable for large |z|
+	softplus, err := G.Softplus(h)
+	if err != nil {
+		return nil, err
+	}
+	yz, err := G.HadamardProd(net.y, h)
+	if err != nil {
+		return nil, err
+	}
+	losses, err := G.Sub(softplus, yz)
+	if err != nil {
+		return nil, err
+	}
+	if net.cost, err = G.Mean(losses); err != nil {
+		return nil, err
+	}
+	if _, err = G.Grad(net.cost, net.learnables()...); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// learnables returns the weights and biases of the graph
+func (net *mlpGraph) learnables() G.Nodes {
+	return append(slices.Clone(net.weights), net.biases...)
+}
+
+// layers copies the current weights and biases of the graph
+func (net *mlpGraph) layers() []mlpLayer {
+	layers := make([]mlpLayer, len(net.weights))
+	for l := range layers {
+		weights := slices.Clone(net.weights[l].Value().Data().([]float64))
+		layers[l] = mlpLayer{
+			weights: mat.NewDense(net.sizes[l], net.sizes[l+1], weights),
+			bias:    slices.Clone(net.biases[l].Value().Data().([]float64)),
+		}
+	}
+	return layers
+}
+
+// adamW is Adam with decoupled weight decay (Loshchilov and Hutter, 2019): before each Adam step the weights shrink by
+// learningRate * weightDecay, instead of adding an L2 term to the gradient that Adam would rescale
+type adamW struct {
+	*G.AdamSolver
+	learningRate float64
+	weightDecay  float64
+	weights      G.Nodes
+}
+
+func (s *adamW) Step(model []G.ValueGrad) error {
+	if s.weightDecay > 0 {
+		for _, w := range s.weights {
+			floats.Scale(1-s.learningRate*s.weightDecay, w.Value().Data().([]float64))
+		}
+	}
+	return s.AdamSolver.Step(model)
+}
+
+// Train trains the MLP with AdamW on the mean cross-entropy of mini-batches of shuffled examples, with labels of 1
+// and -1
+func (m *MLP) Train(vectors []features.Vector, labels []int, options MLPOptions) error {
+	if len(vectors) != len(labels) {
+		return fmt.Errorf("number of features (%d) does not match number of labels (%d)", len(vectors), len(labels))
+	}
+	if len(vectors) == 0 {
+		return fmt.Errorf("empty training data")
+	}
+	for _, size := range m.hidden {
+		if size < 1 {
+			return fmt.Errorf("hidden layers need at least one unit, got %v", m.hidden)
+		}
+	}
+	if options.Dropout < 0 || options.Dropout >= 1 {
+		return fmt.Errorf("dropout must be in [0, 1), got %v", options.Dropout)
+	}
+
+	// Standardize features, computing the mean and std of this training data
+	m.featureMean, m.featureStd = nil, nil
+	X, err := m.standardizeFeatures(vectors, m.schema.Len(), true)
+	if err != nil {
+		return err
+	}
+
+	numSamples, numFeatures := X.Dims()
+	y := binaryLabels(labels)
+
+	// Standardize validation features with the training mean and std
+	stopper, err := newEarlyStopper(options.EarlyStopping)
+	if err != nil {
+		return err
+	}
+	var XVal *mat.Dense
+	var yVal *mat.VecDense
+	if stopper != nil {
+		if XVal, err = m.standardizeFeatures(options.EarlyStopping.X, m.schema.Len(), false); err != nil {
+			return fmt.Errorf("validation data: %v", err)
+		}
+		yVal = binaryLabels(options.EarlyStopping.Y)
+	}
+
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	batchSize = min(batchSize, numSamples)
+	learningRate := options.LearningRate
+	if learningRate <= 0 {
+		learningRate = 0.001
+	}
+
+	sizes := append(append([]int{numFeatures}, m.hidden...), 1)
+	net, err := newMLPGraph(sizes, batchSize, options.Dropout > 0)
+	if err != nil {
+		return fmt.Errorf("failed to build the network: %v", err)
+	}
+	vm := G.NewTapeMachine(net.g, G.BindDualValues(net.learnables()...))
+	defer vm.Close()
+	solver := &adamW{
+		AdamSolver:   G.NewAdamSolver(G.WithLearnRate(learningRate)),
+		learningRate: learningRate,
+		weightDecay:  options.WeightDecay,
+		weights:      net.weights,
+	}
+	m.layers = net.layers()
+
+	// Batches are copied to the backing arrays of the input tensors
+	xBatch := make([]float64, batchSize*numFeatures)
+	yBatch := make([]float64, batchSize)
+	xTensor := tensor.New(tensor.WithShape(batchSize, numFeatures), tensor.WithBacking(xBatch))
+	yTensor := tensor.New(tensor.WithShape(batchSize, 1), tensor.WithBacking(yBatch))
+	masks := make([][]float64, len(net.masks))
+	maskTensors := make([]*tensor.Dense, len(net.masks))
+	for l := range masks {
+		masks[l] = make([]float64, batchSize*m.hidden[l])
+		maskTensors[l] = tensor.New(tensor.WithShape(batchSize, m.hidden[l]), tensor.WithBacking(masks[l]))
+	}
+	keep := 1 - options.Dropout
+
+	var bestLayers []mlpLayer
+
+	order := make([]int, numSamples)
+	for i := range order {
+		order[i] = i
+	}
+	numBatches := numSamples / batchSize
+
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+		loss := 0.0
+		for b := 0; b < numBatches; b++ {
+			for row, i := range order[b*batchSize : (b+1)*batchSize] {
+				copy(xBatch[row*numFeatures:(row+1)*numFeatures], X.RawRowView(i))
+				yBatch[row] = y.AtVec(i)
+			}
+			if err := G.Let(net.x, xTensor); err != nil {
+				return err
+			}
+			if err := G.Let(net.y, yTensor); err != nil {
+				return err
+			}
+
+			// Inverted dropout: kept units are scaled by 1/keep so that predictions need no scaling
+			for l, mask := range masks {
+				for j := range mask {
+					mask[j] = 0
+					if rand.Float64() < keep {
+						mask[j] = 1 / keep
+					}
+				}
+				if err := G.Let(net.masks[l], maskTensors[l]); err != nil {
+					return err
+				}
+			}
+
+			// Forward and backward pass, then an AdamW step
+			if err := vm.RunAll(); err != nil {
+				return fmt.Errorf("epoch %d: %v", epoch, err)
+			}
+			loss += net.cost.Value().Data().(float64)
+			if err := solver.Step(G.NodesToValueGrads(net.learnables())); err != nil {
+				return fmt.Errorf("epoch %d: %v", epoch, err)
+			}
+			vm.Reset()
+		}
+		loss /= float64(numBatches)
+		m.layers = net.layers()
+
+		// Early stopping check on the validation loss, without dropout
+		valLoss := math.NaN()
+		if stopper != nil {
+			valLoss = m.crossEntropy(XVal, yVal)
+			improved, stop := stopper.observe(valLoss)
+			if improved {
+				bestLayers = m.layers
+			}
+			if stop {
+				stopper.logStop(epoch)
+				break
+			}
+		}
+		logEpoch(epoch, loss, valLoss)
+	}
+
+	// Keep the layers with the lowest validation loss
+	if stopper.hasBest() {
+		m.layers = bestLayers
+	}
+
+	return nil
+}
+
+// logits returns the output logit of each row of standardized examples
+func (m *MLP) logits(X *mat.Dense) []float64 {
+	A := X
+	for l, layer := range m.layers {
+		var Z mat.Dense
+		Z.Mul(A, layer.weights)
+		rows, _ := Z.Dims()
+		for i := 0; i < rows; i++ {
+			row := Z.RawRowView(i)
+			floats.Add(row, layer.bias)
+			if l < len(m.layers)-1 {
+				for j := range row {
+					row[j] = max(row[j], 0)
+				}
+			}
+		}
+		A = &Z
+	}
+	return mat.Col(nil, 0, A)
+}
+
+// crossEntropy returns the mean cross-entropy of the model on standardized examples
+func (m *MLP) crossEntropy(X *mat.Dense, y *mat.VecDense) float64 {
+	loss := 0.0
+	for i, z := range m.logits(X) {
+		loss += max(z, 0) + math.Log1p(math.Exp(-math.Abs(z))) - y.AtVec(i)*z
+	}
+	return loss / float64(y.Len())
+}
+
+// PredictClass predicts the class (1 or -1) for the feature difference of two ranked documents
+func (m *MLP) PredictClass(diff ranking.Features) int {
+	return m.PredictVector(m.fromFeatures(diff))
+}
+
+// PredictVector predicts the class (1 or -1) for a feature vector of the model schema
+func (m *MLP) PredictVector(x features.Vector) int {
+	if m.layers == nil {
+		return -1
+	}
+
+	standardized := make([]float64, len(x))
+	for i := range x {
+		standardized[i] = (x[i] - m.featureMean[i]) / m.featureStd[i]
+	}
+	if m.logits(mat.NewDense(1, len(standardized), standardized))[0] >= 0 {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package training
+
+import (
+	"math/rand"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+// xorPairs returns examples of two features labeled by the sign of x0 x1, which no linear model separates
+func xorPairs(r *rand.Rand, n int) ([]features.Vector, []int) {
+	X := make([]features.Vector, n)
+	Y := make([]int, n)
+	for i := range X {
+		X[i] = features.Vector{r.NormFloat64(), r.NormFloat64()}
+		Y[i] = -1
+		if X[i][0]*X[i][1] > 0 {
+			Y[i] = 1
+		}
+	}
+	return X, Y
+}
+
+func TestMLP_Train(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	X, Y := xorPairs(r, 2000)
+	XVal, YVal := xorPairs(r, 500)
+
+	tests := []struct {
+		name    string
+		hidden  []int
+		options MLPOptions
+	}{
+		{"one hidden layer", []int{16}, MLPOptions{Epochs: 30, BatchSize: 32, LearningRate: 0.01}},
+		{"dropout and weight decay", []int{32, 16}, MLPOptions{Epochs: 30, BatchSize: 32, LearningRate: 0.01, Dropout: 0.1, WeightDecay: 1e-4}},
+		{"early stopping", []int{16}, MLPOptions{Epochs: 100, BatchSize: 32, LearningRate: 0.01, EarlyStopping: &EarlyStopping{X: XVal, Y: YVal, Patience: 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlp := NewMLP(tt.hidden, features.NumberedSchema(2))
+			if err := mlp.Train(X, Y, tt.options); err != nil {
+				t.Fatalf("Train() error = %v", err)
+			}
+			if accuracy := evaluateModel(mlp, XVal, YVal); accuracy < 90 {
+				t.Errorf("validation accuracy = %.1f%%, want at least 90%%", accuracy)
+			}
+		})
+	}
+
+	if err := NewMLP([]int{0}, features.NumberedSchema(2)).Train(X, Y, MLPOptions{Epochs: 1}); err == nil {
+		t.Errorf("Train() with an empty hidden layer succeeded")
+	}
+	if err := NewMLP([]int{4}, features.NumberedSchema(2)).Train(X, Y, MLPOptions{Epochs: 1, Dropout: 1}); err == nil {
+		t.Errorf("Train() with a dropout of 1 succeeded")
+	}
+}
+
+func TestMLP_SaveLoad(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	X, Y := xorPairs(r, 300)
+	mlp := NewMLP([]int{8, 4}, features.NumberedSchema(2))
+	if err := mlp.Train(X, Y, MLPOptions{Epochs: 5, BatchSize: 16}); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "mlp.gob")
+	if err := mlp.Save(filename); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadModel(filename)
+	if err != nil {
+		t.Fatalf("LoadModel() error = %v", err)
+	}
+	if _, ok := loaded.(*MLP); !ok {
+		t.Fatalf("LoadModel() = %T, want *MLP", loaded)
+	}
+	for _, x := range X {
+		if got, want := loaded.PredictVector(x), mlp.PredictVector(x); got != want {
+			t.Fatalf("loaded PredictVector(%v) = %d, want %d", x, got, want)
+		}
+	}
+	if _, err := LoadLogisticRegression(filename); err == nil {
+		t.Errorf("LoadLogisticRegression() of an MLP succeeded")
+	}
+}
@@ -0,0 +1,290 @@
+package training
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"log"
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"rpi-search-ranking/internal/ranking"
+)
+
+// Types identifying the models in saved model files
+const (
+	logisticRegressionType = "LogisticRegression"
+	rankSVMType            = "RankSVM"
+	mlpType                = "MLP"
+)
+
+// modelFile is the gob-encoded representation of a trained model
+type modelFile struct {
+	Type         string
+	Weights      []float64
+	Bias         float64
+	Lambda       float64
+	FeatureIDs   []int // schema feature IDs, nil for models saved before feature vectors
+	FeatureNames []string
+	FeatureMean  []float64
+	FeatureStd   []float64
+	Layers       []layerFile // layers of an MLP, nil for linear models
+}
+
+// layerFile is a fully connected layer of an MLP in a model file
+type layerFile struct {
+	Inputs, Outputs int
+	Weights         []float64 // Inputs x Outputs, row-major
+	Bias            []float64
+}
+
+// Model is a trained pairwise model of any type, as read by LoadModel. Linear models also implement
+// ranking.FeatureContributor.
+type Model interface {
+	ranking.PairwiseModel
+	VectorModel
+	Save(filename string) error
+}
+
+// Save writes the trained model to a file
+func (lr *LogisticRegression) Save(filename string) error {
+	return lr.save(filename, logisticRegressionType)
+}
+
+// Save writes the trained model to a file
+func (svm *RankSVM) Save(filename string) error {
+	return svm.save(filename, rankSVMType)
+}
+
+// save writes a trained linear model to a file as a model of the given type
+func (m *linearModel) save(filename, modelType string) error {
+	if m.Weights == nil {
+		return fmt.Errorf("model has not been trained")
+	}
+
+	return saveModelFile(filename, modelFile{
+		Type:         modelType,
+		Weights:      mat.Col(nil, 0, m.Weights),
+		Bias:         m.bias,
+		Lambda:       m.lambda,
+		FeatureIDs:   m.schema.IDs,
+		FeatureNames: m.schema.Names,
+		FeatureMean:  m.featureMean,
+		FeatureStd:   m.featureStd,
+	})
+}
+
+// Save writes the trained model to a file
+func (m *MLP) Save(filename string) error {
+	if m.layers == nil {
+		return fmt.Errorf("model has not been trained")
+	}
+
+	layers := make([]layerFile, len(m.layers))
+	for l, layer := range m.layers {
+		inputs, outputs := layer.weights.Dims()
+		layers[l] = layerFile{
+			Inputs:  inputs,
+			Outputs: outputs,
+			Weights: mat.DenseCopyOf(layer.weights).RawMatrix().Data,
+			Bias:    layer.bias,
+		}
+	}
+	return saveModelFile(filename, modelFile{
+		Type:         mlpType,
+		FeatureIDs:   m.schema.IDs,
+		FeatureNames: m.schema.Names,
+		FeatureMean:  m.featureMean,
+		FeatureStd:   m.featureStd,
+		Layers:       layers,
+	})
+}
+
+// LoadLogisticRegression reads a model written by LogisticRegression.Save
+func LoadLogisticRegression(filename string) (*LogisticRegression, error) {
+	model, err := loadLinearModel(filename, logisticRegressionType)
+	if err != nil {
+		return nil, err
+	}
+	return &LogisticRegression{model}, nil
+}
+
+// LoadRankSVM reads a model written by RankSVM.Save
+func LoadRankSVM(filename string) (*RankSVM, error) {
+	model, err := loadLinearModel(filename, rankSVMType)
+	if err != nil {
+		return nil, err
+	}
+	return &RankSVM{model}, nil
+}
+
+// LoadMLP reads a model written by MLP.Save
+func LoadMLP(filename string) (*MLP, error) {
+	model, err := loadModelFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if model.Type != mlpType {
+		return nil, fmt.Errorf("model file %s contains a %s model, expected %s", filename, model.Type, mlpType)
+	}
+	return model.mlp(), nil
+}
+
+// LoadModel reads a model written by the Save method of any model
+func LoadModel(filename string) (Model, error) {
+	model, err := loadModelFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch model.Type {
+	case logisticRegressionType:
+		return &LogisticRegression{model.linearModel()}, nil
+	case rankSVMType:
+		return &RankSVM{model.linearModel()}, nil
+	case mlpType:
+		return model.mlp(), nil
+	}
+	return nil, fmt.Errorf("model file %s contains an unknown %s model", filename, model.Type)
+}
+
+// loadLinearModel reads a model file that must contain a model of the given type
+func loadLinearModel(filename, modelType string) (linearModel, error) {
+	model, err := loadModelFile(filename)
+	if err != nil {
+		return linearModel{}, err
+	}
+	if model.Type != modelType {
+		return linearModel{}, fmt.Errorf("model file %s contains a %s model, expected %s", filename, model.Type, modelType)
+	}
+	return model.linearModel(), nil
+}
+
+// schema returns the features of the model stored in the file
+func (model modelFile) schema() features.Schema {
+	return features.Schema{IDs: model.FeatureIDs, Names: model.FeatureNames}
+}
+
+// linearModel returns the model stored in the file
+func (model modelFile) linearModel() linearModel {
+	schema := model.schema()
+	return linearModel{
+		Weights:           mat.NewVecDense(len(model.Weights), model.Weights),
+		bias:              model.Bias,
+		lambda:            model.Lambda,
+		schema:            schema,
+		standardizer:      standardizer{featureMean: model.FeatureMean, featureStd: model.FeatureStd},
+		featureProjection: newFeatureProjection(schema),
+	}
+}
+
+// mlp returns the MLP stored in the file
+func (model modelFile) mlp() *MLP {
+	schema := model.schema()
+	m := &MLP{
+		schema:            schema,
+		layers:            make([]mlpLayer, len(model.Layers)),
+		standardizer:      standardizer{featureMean: model.FeatureMean, featureStd: model.FeatureStd},
+		featureProjection: newFeatureProjection(schema),
+	}
+	for l, layer := range model.Layers {
+		m.layers[l] = mlpLayer{weights: mat.NewDense(layer.Inputs, layer.Outputs, layer.Weights), bias: layer.Bias}
+		if l < len(model.Layers)-1 {
+			m.hidden = append(m.hidden, layer.Outputs)
+		}
+	}
+	return m
+}
+
+// validateLayers checks that the layers of an MLP chain from numFeatures inputs to one output
+func validateLayers(layers []layerFile, numFeatures int) error {
+	if len(layers) == 0 {
+		return fmt.Errorf("MLP has no layers")
+	}
+	inputs := numFeatures
+	for l, layer := range layers {
+		if layer.Inputs != inputs || layer.Outputs < 1 {
+			return fmt.Errorf("layer %d has %d inputs and %d outputs, expected %d inputs", l, layer.Inputs, layer.Outputs, inputs)
+		}
+		if len(layer.Weights) != layer.Inputs*layer.Outputs || len(layer.Bias) != layer.Outputs {
+			return fmt.Errorf("layer %d has %d weights and %d biases, expected %d and %d", l, len(layer.Weights), len(layer.Bias), layer.Inputs*layer.Outputs, layer.Outputs)
+		}
+		inputs = layer.Outputs
+	}
+	if inputs != 1 {
+		return fmt.Errorf("MLP has %d outputs, expected 1", inputs)
+	}
+	return nil
+}
+
+// saveModelFile gob-encodes a model to a file
+func saveModelFile(filename string, model modelFile) error {
+	// Ensure the directory exists
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Create the file
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Close the file and handle errors if they occur
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("warning: failed to close file: %v\n", closeErr)
+		}
+	}()
+
+	return gob.NewEncoder(file).Encode(model)
+}
+
+// loadModelFile decodes and validates a model written by saveModelFile
+func loadModelFile(filename string) (modelFile, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return modelFile{}, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("warning: failed to close file: %v\n", err)
+		}
+	}(file)
+
+	var model modelFile
+	if err := gob.NewDecoder(file).Decode(&model); err != nil {
+		return modelFile{}, fmt.Errorf("failed to decode model file %s: %v", filename, err)
+	}
+
+	// Models saved before feature vectors use the features of the ranker
+	if model.FeatureIDs == nil {
+		schema := features.RankingSchema()
+		model.FeatureIDs, model.FeatureNames = schema.IDs, schema.Names
+	}
+	numFeatures := len(model.FeatureIDs)
+	if len(model.FeatureNames) != numFeatures || len(model.FeatureMean) != numFeatures || len(model.FeatureStd) != numFeatures {
+		return modelFile{}, fmt.Errorf("model file %s has %d feature names and %d feature means for %d features", filename, len(model.FeatureNames), len(model.FeatureMean), numFeatures)
+	}
+	if model.Type == mlpType {
+		if err := validateLayers(model.Layers, numFeatures); err != nil {
+			return modelFile{}, fmt.Errorf("model file %s: %v", filename, err)
+		}
+	} else if len(model.Weights) != numFeatures {
+		return modelFile{}, fmt.Errorf("model file %s has %d weights, expected %d", filename, len(model.Weights), numFeatures)
+	}
+
+	return model, nil
+}
+
+// ModelFileVersion identifies the contents of a model file by the first 12 hex digits of its SHA-256 hash
+func ModelFileVersion(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
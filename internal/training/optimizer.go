@@ -0,0 +1,145 @@
+package training
+
+import (
+	"fmt"
+	"math"
+)
+
+// Optimizer updates model parameters from their gradient. Optimizers keep per-parameter state between steps,
+// so an optimizer trains one model at a time.
+type Optimizer interface {
+	// Init resets the state of the optimizer for n parameters, before training
+	Init(n int)
+	// Step updates params in place from their gradient, with the learning rate of the current epoch
+	Step(params, grad []float64, learningRate float64)
+}
+
+// SGD is plain (stochastic) gradient descent
+type SGD struct{}
+
+func (SGD) Init(int) {}
+
+func (SGD) Step(params, grad []float64, learningRate float64) {
+	for i := range params {
+		params[i] -= learningRate * grad[i]
+	}
+}
+
+// Momentum is gradient descent with momentum: each step follows an exponentially decaying sum of past gradients
+type Momentum struct {
+	Beta     float64 // decay of past gradients, typically 0.9
+	velocity []float64
+}
+
+func (m *Momentum) Init(n int) {
+	m.velocity = make([]float64, n)
+}
+
+func (m *Momentum) Step(params, grad []float64, learningRate float64) {
+	for i := range params {
+		m.velocity[i] = m.Beta*m.velocity[i] + grad[i]
+		params[i] -= learningRate * m.velocity[i]
+	}
+}
+
+// Adam scales each step by running estimates of the first and second moments of the gradient
+// (Kingma and Ba, 2015)
+type Adam struct {
+	Beta1, Beta2 float64 // decay of the moment estimates, typically 0.9 and 0.999
+	Epsilon      float64 // added to the second moment for numerical stability, typically 1e-8
+	m, v         []float64
+	t            int
+}
+
+// NewAdam returns Adam with the usual decay rates
+func NewAdam() *Adam {
+	return &Adam{Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8}
+}
+
+func (a *Adam) Init(n int) {
+	a.m = make([]float64, n)
+	a.v = make([]float64, n)
+	a.t = 0
+}
+
+func (a *Adam) Step(params, grad []float64, learningRate float64) {
+	a.t++
+	correction1 := 1 - math.Pow(a.Beta1, float64(a.t))
+	correction2 := 1 - math.Pow(a.Beta2, float64(a.t))
+	for i := range params {
+		a.m[i] = a.Beta1*a.m[i] + (1-a.Beta1)*grad[i]
+		a.v[i] = a.Beta2*a.v[i] + (1-a.Beta2)*grad[i]*grad[i]
+		params[i] -= learningRate * (a.m[i] / correction1) / (math.Sqrt(a.v[i]/correction2) + a.Epsilon)
+	}
+}
+
+// NewOptimizer returns the optimizer with the given name: sgd, momentum (with decay beta) or adam
+func NewOptimizer(name string, beta float64) (Optimizer, error) {
+	switch name {
+	case "sgd":
+		return SGD{}, nil
+	case "momentum":
+		return &Momentum{Beta: beta}, nil
+	case "adam":
+		return NewAdam(), nil
+	}
+	return nil, fmt.Errorf("unknown optimizer %q", name)
+}
+
+// Schedule returns the learning rate of an epoch, counted from 0
+type Schedule func(epoch int) float64
+
+// ConstantRate keeps the learning rate fixed
+func ConstantRate(rate float64) Schedule {
+	return func(int) float64 { return rate }
+}
+
+// StepDecay multiplies the learning rate by factor every few epochs
+func StepDecay(rate, factor float64, every int) Schedule {
+	return func(epoch int) float64 { return rate * math.Pow(factor, float64(epoch/every)) }
+}
+
+// ExponentialDecay multiplies the learning rate by factor every epoch
+func ExponentialDecay(rate, factor float64) Schedule {
+	return func(epoch int) float64 { return rate * math.Pow(factor, float64(epoch)) }
+}
+
+// CosineDecay anneals the learning rate from rate to 0 over epochs along a half cosine
+func CosineDecay(rate float64, epochs int) Schedule {
+	return func(epoch int) float64 {
+		return rate * 0.5 * (1 + math.Cos(math.Pi*float64(min(epoch, epochs))/float64(epochs)))
+	}
+}
+
+// ParseSchedule returns the schedule with the given name: constant, step (decaying by factor every few epochs),
+// exponential (decaying by factor every epoch) or cosine (annealing to 0 over epochs)
+func ParseSchedule(name string, rate, factor float64, every, epochs int) (Schedule, error) {
+	switch name {
+	case "constant":
+		return ConstantRate(rate), nil
+	case "step":
+		if every < 1 {
+			return nil, fmt.Errorf("step decay needs a positive number of epochs between decays, got %d", every)
+		}
+		return StepDecay(rate, factor, every), nil
+	case "exponential":
+		return ExponentialDecay(rate, factor), nil
+	case "cosine":
+		return CosineDecay(rate, epochs), nil
+	}
+	return nil, fmt.Errorf("unknown learning rate schedule %q", name)
+}
+
+// clipGradient scales the gradient down to an L2 norm of maxNorm if it is longer
+func clipGradient(grad []float64, maxNorm float64) {
+	norm := 0.0
+	for _, g := range grad {
+		norm += g * g
+	}
+	norm = math.Sqrt(norm)
+	if norm > maxNorm {
+		for i := range grad {
+			grad[i] *= maxNorm / norm
+		}
+	}
+}
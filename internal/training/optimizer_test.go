@@ -0,0 +1,96 @@
+package training
+
+import (
+	"math"
+	"math/rand"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestOptimizers_MinimizeQuadratic(t *testing.T) {
+	// Minimize (x - 3)^2 + (y + 1)^2
+	for _, name := range []string{"sgd", "momentum", "adam"} {
+		t.Run(name, func(t *testing.T) {
+			optimizer, err := NewOptimizer(name, 0.9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := []float64{0, 0}
+			optimizer.Init(len(params))
+			for step := 0; step < 2000; step++ {
+				grad := []float64{2 * (params[0] - 3), 2 * (params[1] + 1)}
+				optimizer.Step(params, grad, 0.01)
+			}
+			if math.Abs(params[0]-3) > 1e-2 || math.Abs(params[1]+1) > 1e-2 {
+				t.Errorf("%s converged to %v, want [3 -1]", name, params)
+			}
+		})
+	}
+	if _, err := NewOptimizer("lbfgs", 0); err == nil {
+		t.Errorf("NewOptimizer(lbfgs) succeeded")
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	tests := []struct {
+		name  string
+		epoch int
+		want  float64
+	}{
+		{"constant", 7, 0.1},
+		{"step", 9, 0.1},
+		{"step", 10, 0.05},
+		{"step", 25, 0.025},
+		{"exponential", 2, 0.025},
+		{"cosine", 0, 0.1},
+		{"cosine", 50, 0.05},
+		{"cosine", 100, 0},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.name, 0.1, 0.5, 10, 100)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%s) error = %v", tt.name, err)
+		}
+		if got := schedule(tt.epoch); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s learning rate at epoch %d = %v, want %v", tt.name, tt.epoch, got, tt.want)
+		}
+	}
+	if _, err := ParseSchedule("step", 0.1, 0.5, 0, 100); err == nil {
+		t.Errorf("ParseSchedule(step) without decay epochs succeeded")
+	}
+}
+
+func TestClipGradient(t *testing.T) {
+	grad := []float64{3, 4}
+	clipGradient(grad, 1)
+	if math.Abs(grad[0]-0.6) > 1e-12 || math.Abs(grad[1]-0.8) > 1e-12 {
+		t.Errorf("clipGradient() = %v, want [0.6 0.8]", grad)
+	}
+	grad = []float64{0.3, 0.4}
+	clipGradient(grad, 1)
+	if grad[0] != 0.3 || grad[1] != 0.4 {
+		t.Errorf("clipGradient() changed a short gradient to %v", grad)
+	}
+}
+
+func TestTrain_MiniBatchAdam(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	X := make([]features.Vector, 1000)
+	Y := make([]int, len(X))
+	for i := range X {
+		X[i] = features.Vector{r.NormFloat64(), 10 * r.NormFloat64()}
+		Y[i] = -1
+		if X[i][0]-0.1*X[i][1] > 0 {
+			Y[i] = 1
+		}
+	}
+
+	lr := NewLogisticRegression(0.001, features.NumberedSchema(2))
+	options := TrainOptions{Epochs: 20, BatchSize: 32, Optimizer: NewAdam(), Schedule: ConstantRate(0.05), ClipNorm: 5}
+	if err := lr.Train(X, Y, options); err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	if accuracy := evaluateModel(lr, X, Y); accuracy < 95 {
+		t.Errorf("training accuracy = %.1f%%, want at least 95%%", accuracy)
+	}
+}
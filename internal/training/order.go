@@ -0,0 +1,62 @@
+package training
+
+import (
+	"rpi-search-ranking/internal/features"
+	"slices"
+)
+
+// bm25FeatureID is the MSLR ID of the whole document BM25 feature
+const bm25FeatureID = 110
+
+// VectorModel is a pairwise model over feature vectors of its schema
+type VectorModel interface {
+	// Schema returns the features of the vectors the model expects
+	Schema() features.Schema
+	// PredictVector receives the feature difference of the first and second document and returns
+	// 1 if the first document should rank higher, -1 otherwise
+	PredictVector(diff features.Vector) int
+}
+
+// Order returns the indices of the documents of a query, given as vectors of the schema, in ranked order.
+// Like ranking.SortDocuments, a nil model orders by BM25, otherwise documents are ordered by the number of
+// pairwise comparisons they win, and ties are broken by BM25. The schema must match the model's.
+func Order(vectors []features.Vector, schema features.Schema, model VectorModel) []int {
+	bm25 := make([]float64, len(vectors))
+	if index, ok := schema.Index(bm25FeatureID); ok {
+		for i, v := range vectors {
+			bm25[i] = v[index]
+		}
+	}
+
+	scores := bm25
+	if model != nil {
+		scores = make([]float64, len(vectors))
+		for i := range vectors {
+			for j := range vectors {
+				if i != j && model.PredictVector(features.Diff(vectors[i], vectors[j])) == 1 {
+					scores[i]++
+				}
+			}
+		}
+	}
+
+	order := make([]int, len(vectors))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case scores[a] != scores[b]:
+			if scores[a] > scores[b] {
+				return -1
+			}
+			return 1
+		case bm25[a] > bm25[b]:
+			return -1
+		case bm25[a] < bm25[b]:
+			return 1
+		}
+		return 0
+	})
+	return order
+}
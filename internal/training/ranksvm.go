@@ -0,0 +1,184 @@
+package training
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"math/rand"
+	"rpi-search-ranking/internal/features"
+)
+
+// RankSVM represents a linear ranking SVM, trained on the feature differences of document pairs with the hinge loss
+// and L2 regularization. Pair differences are antisymmetric, so the model has no bias and features are scaled
+// without centering: swapping the documents of a pair always flips the predicted class.
+type RankSVM struct {
+	linearModel
+}
+
+// NewRankSVM creates a new ranking SVM with specified L2 strength for feature vectors of the schema
+func NewRankSVM(lambda float64, schema features.Schema) *RankSVM {
+	return &RankSVM{linearModel{
+		lambda:            lambda,
+		schema:            schema,
+		featureProjection: newFeatureProjection(schema),
+	}}
+}
+
+// RankSVMOptions configures the training of a RankSVM
+type RankSVMOptions struct {
+	Epochs        int
+	BatchSize     int            // examples per update, defaults to 1
+	EarlyStopping *EarlyStopping // stops when the validation hinge loss stops improving, nil trains all epochs
+}
+
+// hingeLabels converts labels of 1 and -1 to a vector of 1 and -1
+func hingeLabels(labels []int) *mat.VecDense {
+	y := mat.NewVecDense(len(labels), nil)
+	for i, label := range labels {
+		if label == 1 {
+			y.SetVec(i, 1.0)
+		} else {
+			y.SetVec(i, -1.0)
+		}
+	}
+	return y
+}
+
+// hingeLoss returns the mean hinge loss of the model on standardized examples
+func (svm *RankSVM) hingeLoss(X *mat.Dense, y *mat.VecDense) float64 {
+	numSamples, _ := X.Dims()
+	z := mat.NewVecDense(numSamples, nil)
+	z.MulVec(X, svm.Weights)
+	loss := 0.0
+	for i := 0; i < numSamples; i++ {
+		loss += math.Max(0, 1-y.AtVec(i)*z.AtVec(i))
+	}
+	return loss / float64(numSamples)
+}
+
+// Train trains the ranking SVM with the Pegasos solver (Shalev-Shwartz et al., 2007): each mini-batch of shuffled
+// examples takes a subgradient step of the regularized hinge loss with learning rate 1/(lambda t), followed by a
+// projection of the weights onto the ball of radius 1/sqrt(lambda) that holds the optimum
+func (svm *RankSVM) Train(vectors []features.Vector, labels []int, options RankSVMOptions) error {
+	if len(vectors) != len(labels) {
+		return fmt.Errorf("number of features (%d) does not match number of labels (%d)", len(vectors), len(labels))
+	}
+	if len(vectors) == 0 {
+		return fmt.Errorf("empty training data")
+	}
+	if svm.lambda <= 0 {
+		return fmt.Errorf("the Pegasos solver needs a positive lambda, got %v", svm.lambda)
+	}
+
+	// Scale features by the root mean square of this training data
+	svm.featureMean, svm.featureStd = nil, nil
+	X, err := svm.standardizeFeatures(vectors, svm.schema.Len(), false)
+	if err != nil {
+		return err
+	}
+
+	numSamples, numFeatures := X.Dims()
+	y := hingeLabels(labels)
+
+	// Scale validation features like the training features
+	stopper, err := newEarlyStopper(options.EarlyStopping)
+	if err != nil {
+		return err
+	}
+	var XVal *mat.Dense
+	var yVal *mat.VecDense
+	if stopper != nil {
+		if XVal, err = svm.standardizeFeatures(options.EarlyStopping.X, svm.schema.Len(), false); err != nil {
+			return fmt.Errorf("validation data: %v", err)
+		}
+		yVal = hingeLabels(options.EarlyStopping.Y)
+	}
+
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	batchSize = min(batchSize, numSamples)
+
+	// Pegasos starts from zero weights
+	weights := make([]float64, numFeatures)
+	svm.Weights = mat.NewVecDense(numFeatures, weights)
+	svm.bias = 0.0
+	radius := 1 / math.Sqrt(svm.lambda)
+
+	bestWeights := mat.NewVecDense(numFeatures, nil)
+
+	order := make([]int, numSamples)
+	for i := range order {
+		order[i] = i
+	}
+	step := make([]float64, numFeatures)
+	t := 0
+
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+		loss := 0.0
+		for start := 0; start < numSamples; start += batchSize {
+			batch := order[start:min(start+batchSize, numSamples)]
+			t++
+			learningRate := 1 / (svm.lambda * float64(t))
+
+			// Sum the examples of the batch inside the margin, which have a hinge loss subgradient of -y x
+			clear(step)
+			for _, i := range batch {
+				x, yi := X.RawRowView(i), y.AtVec(i)
+				if margin := yi * floats.Dot(weights, x); margin < 1 {
+					loss += 1 - margin
+					floats.AddScaled(step, yi, x)
+				}
+			}
+
+			// Shrink the weights for the regularization and step towards the violating examples
+			floats.Scale(1-learningRate*svm.lambda, weights)
+			floats.AddScaled(weights, learningRate/float64(len(batch)), step)
+
+			// Project onto the ball of radius 1/sqrt(lambda)
+			if norm := floats.Norm(weights, 2); norm > radius {
+				floats.Scale(radius/norm, weights)
+			}
+		}
+		loss /= float64(numSamples)
+
+		// Add L2 regularization term to loss
+		loss += 0.5 * svm.lambda * floats.Dot(weights, weights)
+
+		// Early stopping check on the validation hinge loss, without regularization
+		valLoss := math.NaN()
+		if stopper != nil {
+			valLoss = svm.hingeLoss(XVal, yVal)
+			improved, stop := stopper.observe(valLoss)
+			if improved {
+				bestWeights.CopyVec(svm.Weights)
+			}
+			if stop {
+				stopper.logStop(epoch)
+				break
+			}
+		}
+		logEpoch(epoch, loss, valLoss)
+	}
+
+	// Keep the weights with the lowest validation loss
+	if stopper.hasBest() {
+		svm.Weights.CopyVec(bestWeights)
+	}
+
+	return nil
+}
+
+// RankSVMGridSearchCV chooses the lambda of a RankSVM like GridSearchCV, training each fold with the options without
+// early stopping
+func RankSVMGridSearchCV(schema features.Schema, XTrain []features.Vector, YTrain []int, qids []int, lambdaValues []float64, numFolds int, options RankSVMOptions) (bestLambda float64, bestAccuracy float64) {
+	options.EarlyStopping = nil
+	return gridSearchCV(XTrain, YTrain, qids, lambdaValues, numFolds, func(lambda float64, X []features.Vector, Y []int) (VectorModel, error) {
+		svm := NewRankSVM(lambda, schema)
+		return svm, svm.Train(X, Y, options)
+	})
+}
@@ -0,0 +1,119 @@
+package training
+
+import (
+	"math/rand"
+	"path/filepath"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+// separablePairs returns pair differences of two features labeled by the sign of x0 - 0.1 x1
+func separablePairs(r *rand.Rand, n int) ([]features.Vector, []int) {
+	X := make([]features.Vector, n)
+	Y := make([]int, n)
+	for i := range X {
+		X[i] = features.Vector{r.NormFloat64(), 10 * r.NormFloat64()}
+		Y[i] = -1
+		if X[i][0]-0.1*X[i][1] > 0 {
+			Y[i] = 1
+		}
+	}
+	return X, Y
+}
+
+func TestRankSVM_Train(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	X, Y := separablePairs(r, 1000)
+	XVal, YVal := separablePairs(r, 200)
+
+	tests := []struct {
+		name    string
+		options RankSVMOptions
+	}{
+		{"stochastic", RankSVMOptions{Epochs: 10}},
+		{"mini-batch", RankSVMOptions{Epochs: 50, BatchSize: 16}},
+		{"early stopping", RankSVMOptions{Epochs: 100, BatchSize: 16, EarlyStopping: &EarlyStopping{X: XVal, Y: YVal, Patience: 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svm := NewRankSVM(0.001, features.NumberedSchema(2))
+			if err := svm.Train(X, Y, tt.options); err != nil {
+				t.Fatalf("Train() error = %v", err)
+			}
+			if accuracy := evaluateModel(svm, XVal, YVal); accuracy < 95 {
+				t.Errorf("validation accuracy = %.1f%%, want at least 95%%", accuracy)
+			}
+
+			// Swapping the documents of a pair flips the class
+			for _, x := range XVal[:20] {
+				swapped := features.Vector{-x[0], -x[1]}
+				if svm.decision(x) != 0 && svm.PredictVector(x) == svm.PredictVector(swapped) {
+					t.Errorf("PredictVector(%v) = PredictVector(%v)", x, swapped)
+				}
+			}
+		})
+	}
+
+	if err := NewRankSVM(0, features.NumberedSchema(2)).Train(X, Y, RankSVMOptions{Epochs: 1}); err == nil {
+		t.Errorf("Train() with lambda 0 succeeded")
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	X, Y := separablePairs(r, 200)
+	schema := features.NumberedSchema(2)
+	dir := t.TempDir()
+
+	svm := NewRankSVM(0.01, schema)
+	if err := svm.Train(X, Y, RankSVMOptions{Epochs: 5}); err != nil {
+		t.Fatal(err)
+	}
+	lr := NewLogisticRegression(0.01, schema)
+	if err := lr.Train(X, Y, TrainOptions{Epochs: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		model Model
+	}{
+		{"ranksvm", svm},
+		{"logistic", lr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name+".gob")
+			if err := tt.model.Save(filename); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			loaded, err := LoadModel(filename)
+			if err != nil {
+				t.Fatalf("LoadModel() error = %v", err)
+			}
+			switch tt.model.(type) {
+			case *RankSVM:
+				if _, ok := loaded.(*RankSVM); !ok {
+					t.Errorf("LoadModel() = %T, want *RankSVM", loaded)
+				}
+			case *LogisticRegression:
+				if _, ok := loaded.(*LogisticRegression); !ok {
+					t.Errorf("LoadModel() = %T, want *LogisticRegression", loaded)
+				}
+			}
+			for _, x := range X {
+				if got, want := loaded.PredictVector(x), tt.model.PredictVector(x); got != want {
+					t.Fatalf("loaded PredictVector(%v) = %d, want %d", x, got, want)
+				}
+			}
+		})
+	}
+
+	// Loading a model as the wrong type fails
+	if _, err := LoadLogisticRegression(filepath.Join(dir, "ranksvm.gob")); err == nil {
+		t.Errorf("LoadLogisticRegression() of a RankSVM succeeded")
+	}
+	if _, err := LoadRankSVM(filepath.Join(dir, "logistic.gob")); err == nil {
+		t.Errorf("LoadRankSVM() of a LogisticRegression succeeded")
+	}
+}
@@ -2,264 +2,231 @@ package training
 
 import (
 	"fmt"
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 	"log"
 	"math"
 	"math/rand"
-	"rpi-search-ranking/internal/ranking"
+	"rpi-search-ranking/internal/features"
 )
 
 // LogisticRegression represents a logistic regression model
 type LogisticRegression struct {
-	Weights     *mat.VecDense
-	bias        float64
-	lambda      float64 // L2 regularization parameter
-	featureMean []float64
-	featureStd  []float64
+	linearModel
 }
 
 // NewLogisticRegression creates a new logistic regression model with specified L2 strength
-func NewLogisticRegression(lambda float64) *LogisticRegression {
-	return &LogisticRegression{
-		lambda: lambda,
-	}
+// for feature vectors of the schema
+func NewLogisticRegression(lambda float64, schema features.Schema) *LogisticRegression {
+	return &LogisticRegression{linearModel{
+		lambda:            lambda,
+		schema:            schema,
+		featureProjection: newFeatureProjection(schema),
+	}}
 }
 
-// featureToVector converts a Features struct to a slice of float64
-func featureToVector(f ranking.Features) []float64 {
-	return []float64{
-		float64(f.CoveredQueryTermNumber),
-		f.CoveredQueryTermRatio,
-		float64(f.SumTermFrequency),
-		float64(f.MinTermFrequency),
-		float64(f.MaxTermFrequency),
-		f.MeanTermFrequency,
-		f.VarianceTermFrequency,
-		float64(f.StreamLength),
-		f.SumStreamLengthNormalizedTF,
-		f.MinStreamLengthNormalizedTF,
-		f.MaxStreamLengthNormalizedTF,
-		f.MeanStreamLengthNormalizedTF,
-		f.VarianceStreamLengthNormalizedTF,
-		f.SumTFIDF,
-		f.MinTFIDF,
-		f.MaxTFIDF,
-		f.MeanTFIDF,
-		f.VarianceTFIDF,
-		f.BM25,
-		float64(f.NumSlashesInURL),
-		float64(f.LengthOfURL),
-		float64(f.InlinkCount),
-		float64(f.OutlinkCount),
-		f.PageRank,
-	}
+// sigmoid computes the sigmoid function
+func sigmoid(x float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-x))
 }
 
-func (lr *LogisticRegression) standardizeFeatures(features []ranking.Features) (*mat.Dense, error) {
-	numSamples := len(features)
-	if numSamples == 0 {
-		return nil, fmt.Errorf("empty feature set")
-	}
-
-	// Convert first feature to get dimensions
-	firstVec := featureToVector(features[0])
-	numFeatures := len(firstVec)
-
-	// Initialize feature matrix
-	X := mat.NewDense(numSamples, numFeatures, nil)
-
-	// Fill feature matrix
-	for i, f := range features {
-		X.SetRow(i, featureToVector(f))
-	}
-
-	// Compute mean and std if not already computed (training phase)
-	if lr.featureMean == nil {
-		lr.featureMean = make([]float64, numFeatures)
-		lr.featureStd = make([]float64, numFeatures)
-
-		// Compute mean
-		for j := 0; j < numFeatures; j++ {
-			col := mat.Col(nil, j, X)
-			sum := 0.0
-			for _, val := range col {
-				sum += val
-			}
-			lr.featureMean[j] = sum / float64(numSamples)
-		}
-
-		// Compute std
-		for j := 0; j < numFeatures; j++ {
-			col := mat.Col(nil, j, X)
-			sumSquared := 0.0
-			for _, val := range col {
-				diff := val - lr.featureMean[j]
-				sumSquared += diff * diff
-			}
-			lr.featureStd[j] = math.Sqrt(sumSquared / float64(numSamples))
-			if lr.featureStd[j] == 0 {
-				lr.featureStd[j] = 1 // Prevent division by zero
-			}
+// binaryLabels converts labels of 1 and -1 to a vector of 1 and 0
+func binaryLabels(labels []int) *mat.VecDense {
+	y := mat.NewVecDense(len(labels), nil)
+	for i, label := range labels {
+		if label == 1 {
+			y.SetVec(i, 1.0)
+		} else {
+			y.SetVec(i, 0.0)
 		}
 	}
+	return y
+}
 
-	// Standardize features
-	standardized := mat.NewDense(numSamples, numFeatures, nil)
+// logLoss returns the mean cross-entropy of the model on standardized examples
+func (lr *LogisticRegression) logLoss(X *mat.Dense, y *mat.VecDense) float64 {
+	numSamples, _ := X.Dims()
+	z := mat.NewVecDense(numSamples, nil)
+	z.MulVec(X, lr.Weights)
+	loss := 0.0
 	for i := 0; i < numSamples; i++ {
-		for j := 0; j < numFeatures; j++ {
-			val := X.At(i, j)
-			standardizedVal := (val - lr.featureMean[j]) / lr.featureStd[j]
-			standardized.Set(i, j, standardizedVal)
-		}
+		pi := sigmoid(z.AtVec(i) + lr.bias)
+		yi := y.AtVec(i)
+		loss -= yi*math.Log(pi+1e-15) + (1-yi)*math.Log(1-pi+1e-15)
 	}
-
-	return standardized, nil
+	return loss / float64(numSamples)
 }
 
-// sigmoid computes the sigmoid function
-func sigmoid(x float64) float64 {
-	return 1.0 / (1.0 + math.Exp(-x))
+// TrainOptions configures the training of a LogisticRegression
+type TrainOptions struct {
+	Epochs        int
+	BatchSize     int            // examples per update, 0 for full-batch gradient descent
+	Optimizer     Optimizer      // defaults to SGD
+	Schedule      Schedule       // learning rate of each epoch, defaults to a constant 0.02
+	ClipNorm      float64        // maximum L2 norm of the gradient of a batch, 0 disables clipping
+	Workers       int            // goroutines computing the gradient of large batches, defaults to 1
+	EarlyStopping *EarlyStopping // stops when the validation loss stops improving, nil trains all epochs
 }
 
-// Train trains the logistic regression model with early stopping
-func (lr *LogisticRegression) Train(features []ranking.Features, labels []int, learningRate float64, numEpochs int) error {
-	if len(features) != len(labels) {
-		return fmt.Errorf("number of features (%d) does not match number of labels (%d)", len(features), len(labels))
+// Train trains the logistic regression model by mini-batch gradient descent, shuffling the examples every epoch
+func (lr *LogisticRegression) Train(vectors []features.Vector, labels []int, options TrainOptions) error {
+	if len(vectors) != len(labels) {
+		return fmt.Errorf("number of features (%d) does not match number of labels (%d)", len(vectors), len(labels))
 	}
-	if len(features) == 0 {
+	if len(vectors) == 0 {
 		return fmt.Errorf("empty training data")
 	}
 
-	// Standardize features
-	X, err := lr.standardizeFeatures(features)
+	// Standardize features, computing the mean and std of this training data
+	lr.featureMean, lr.featureStd = nil, nil
+	X, err := lr.standardizeFeatures(vectors, lr.schema.Len(), true)
 	if err != nil {
 		return err
 	}
 
 	numSamples, numFeatures := X.Dims()
-	y := mat.NewVecDense(len(labels), nil)
+	y := binaryLabels(labels)
 
-	// Fill the label vector
-	for i, label := range labels {
-		if label == 1 {
-			y.SetVec(i, 1.0)
-		} else {
-			y.SetVec(i, 0.0)
+	// Standardize validation features with the training mean and std
+	stopper, err := newEarlyStopper(options.EarlyStopping)
+	if err != nil {
+		return err
+	}
+	var XVal *mat.Dense
+	var yVal *mat.VecDense
+	if stopper != nil {
+		if XVal, err = lr.standardizeFeatures(options.EarlyStopping.X, lr.schema.Len(), false); err != nil {
+			return fmt.Errorf("validation data: %v", err)
 		}
+		yVal = binaryLabels(options.EarlyStopping.Y)
 	}
 
-	// Initialize weights with Xavier/Glorot initialization
-	weights := make([]float64, numFeatures)
-	limit := math.Sqrt(6.0 / float64(numFeatures))
-	for i := range weights {
-		weights[i] = (2.0*rand.Float64() - 1.0) * limit
+	optimizer := options.Optimizer
+	if optimizer == nil {
+		optimizer = SGD{}
+	}
+	schedule := options.Schedule
+	if schedule == nil {
+		schedule = ConstantRate(0.02)
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 || batchSize > numSamples {
+		batchSize = numSamples
 	}
 
+	// Initialize weights with Xavier/Glorot initialization. The bias is the last parameter,
+	// and lr.Weights shares the other parameters.
+	params := make([]float64, numFeatures+1)
+	limit := math.Sqrt(6.0 / float64(numFeatures))
+	for i := 0; i < numFeatures; i++ {
+		params[i] = (2.0*rand.Float64() - 1.0) * limit
+	}
+	weights := params[:numFeatures]
 	lr.Weights = mat.NewVecDense(numFeatures, weights)
 	lr.bias = 0.0
+	optimizer.Init(len(params))
+
+	bestWeights := mat.NewVecDense(numFeatures, nil)
+	bestBias := 0.0
+
+	order := make([]int, numSamples)
+	for i := range order {
+		order[i] = i
+	}
+	grad := make([]float64, len(params))
+	gradients := newGradientComputer(batchSize, numFeatures, options.Workers)
+
+	// Mini-batches of shuffled examples are copied to contiguous buffers for the matrix-vector products
+	var XBatch *mat.Dense
+	var yBatch *mat.VecDense
+	if batchSize < numSamples {
+		XBatch = mat.NewDense(batchSize, numFeatures, nil)
+		yBatch = mat.NewVecDense(batchSize, nil)
+	}
 
-	prevLoss := math.Inf(1)
-	patience := 5
-	noImprovement := 0
-
-	// Gradient descent with early stopping
-	for epoch := 0; epoch < numEpochs; epoch++ {
-		// Forward pass
-		predictions := mat.NewVecDense(numSamples, nil)
-		for i := 0; i < numSamples; i++ {
-			xRow := mat.Row(nil, i, X)
-			vecXRow := mat.NewVecDense(len(xRow), xRow)
-			z := mat.Dot(vecXRow, lr.Weights) + lr.bias
-			predictions.SetVec(i, sigmoid(z))
+	// Mini-batch gradient descent with early stopping
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		if batchSize < numSamples {
+			rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
 		}
+		learningRate := schedule(epoch)
 
-		// Compute loss with L2 regularization
 		loss := 0.0
-		for i := 0; i < numSamples; i++ {
-			yi := y.AtVec(i)
-			pi := predictions.AtVec(i)
-			loss -= yi*math.Log(pi+1e-15) + (1-yi)*math.Log(1-pi+1e-15)
+		for start := 0; start < numSamples; start += batchSize {
+			batch := order[start:min(start+batchSize, numSamples)]
+
+			// Forward and backward pass, accumulating the loss and the gradient of the batch
+			XB, yB := X, y
+			if XBatch != nil {
+				XB = XBatch.Slice(0, len(batch), 0, numFeatures).(*mat.Dense)
+				yB = yBatch.SliceVec(0, len(batch)).(*mat.VecDense)
+				for row, i := range batch {
+					XB.SetRow(row, X.RawRowView(i))
+					yB.SetVec(row, y.AtVec(i))
+				}
+			}
+			loss += gradients.compute(XB, yB, lr.Weights, lr.bias, grad)
+
+			// Average the gradient and add L2 regularization of the weights and bias
+			for j := range grad {
+				grad[j] = grad[j]/float64(len(batch)) + lr.lambda*params[j]
+			}
+			if options.ClipNorm > 0 {
+				clipGradient(grad, options.ClipNorm)
+			}
+
+			optimizer.Step(params, grad, learningRate)
+			lr.bias = params[numFeatures]
 		}
 		loss /= float64(numSamples)
 
 		// Add L2 regularization term to loss
-		l2Term := 0.0
-		for j := 0; j < numFeatures; j++ {
-			l2Term += lr.Weights.AtVec(j) * lr.Weights.AtVec(j)
-		}
-		loss += 0.5 * lr.lambda * l2Term
-
-		// Early stopping check
-		if loss >= prevLoss {
-			noImprovement++
-			if noImprovement >= patience {
-				fmt.Printf("Early stopping at epoch %d\n", epoch)
+		loss += 0.5 * lr.lambda * floats.Dot(weights, weights)
+
+		// Early stopping check on the validation loss, without regularization
+		valLoss := math.NaN()
+		if stopper != nil {
+			valLoss = lr.logLoss(XVal, yVal)
+			improved, stop := stopper.observe(valLoss)
+			if improved {
+				bestWeights.CopyVec(lr.Weights)
+				bestBias = lr.bias
+			}
+			if stop {
+				stopper.logStop(epoch)
 				break
 			}
-		} else {
-			noImprovement = 0
 		}
-		prevLoss = loss
-
-		if epoch%10 == 0 {
-			fmt.Printf("Epoch %d, Loss: %.4f\n", epoch, loss)
-		}
-
-		// Compute gradients with L2 regularization
-		predError := mat.NewVecDense(numSamples, nil)
-		predError.SubVec(predictions, y)
-
-		// Update weights with L2 regularization
-		gradW := mat.NewVecDense(numFeatures, nil)
-		for j := 0; j < numFeatures; j++ {
-			xCol := mat.Col(nil, j, X)
-			vecXCol := mat.NewVecDense(numSamples, xCol)
-			l2Term := lr.lambda * lr.Weights.AtVec(j)
-			gradW.SetVec(j, (mat.Dot(predError, vecXCol)/float64(numSamples))+l2Term)
-		}
-
-		// Update bias with L2 regularization
-		gradB := mat.Sum(predError)/float64(numSamples) + lr.lambda*lr.bias
+		logEpoch(epoch, loss, valLoss)
+	}
 
-		// Apply updates with adaptive learning rate
-		lr.Weights.AddScaledVec(lr.Weights, -learningRate, gradW)
-		lr.bias -= learningRate * gradB
+	// Keep the weights with the lowest validation loss
+	if stopper.hasBest() {
+		lr.Weights.CopyVec(bestWeights)
+		lr.bias = bestBias
 	}
 
 	return nil
 }
 
-// predict makes predictions for new features
-func (lr *LogisticRegression) predict(features ranking.Features) float64 {
-	if lr.Weights == nil {
-		return 0.0
-	}
-
-	// Convert and standardize features
-	x := featureToVector(features)
-	standardizedX := make([]float64, len(x))
-	for i := range x {
-		standardizedX[i] = (x[i] - lr.featureMean[i]) / lr.featureStd[i]
-	}
-
-	vecX := mat.NewVecDense(len(standardizedX), standardizedX)
-	z := mat.Dot(vecX, lr.Weights) + lr.bias
-	return sigmoid(z)
+// GridSearchCV performs grid search with query-grouped cross-validation for hyperparameter tuning, training each
+// fold with the options without early stopping. qids holds the query of each example, see GroupKFold.
+func GridSearchCV(schema features.Schema, XTrain []features.Vector, YTrain []int, qids []int, lambdaValues []float64, numFolds int, options TrainOptions) (bestLambda float64, bestAccuracy float64) {
+	options.EarlyStopping = nil
+	return gridSearchCV(XTrain, YTrain, qids, lambdaValues, numFolds, func(lambda float64, X []features.Vector, Y []int) (VectorModel, error) {
+		lr := NewLogisticRegression(lambda, schema)
+		return lr, lr.Train(X, Y, options)
+	})
 }
 
-// PredictClass predicts the class (1 or -1) for new features
-func (lr *LogisticRegression) PredictClass(features ranking.Features) int {
-	prob := lr.predict(features)
-	if prob >= 0.5 {
-		return 1
+// gridSearchCV returns the lambda whose models, trained by train on each fold, have the highest mean
+// validation accuracy
+func gridSearchCV(XTrain []features.Vector, YTrain []int, qids []int, lambdaValues []float64, numFolds int, train func(lambda float64, X []features.Vector, Y []int) (VectorModel, error)) (bestLambda float64, bestAccuracy float64) {
+	// Split data into folds for cross-validation, keeping the pairs of a query in one fold
+	folds, err := GroupKFold(qids, len(XTrain), numFolds)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return -1
-}
-
-// GridSearchCV performs grid search with cross-validation for hyperparameter tuning
-func GridSearchCV(XTrain []ranking.Features, YTrain []int, lambdaValues []float64, numFolds int) (bestLambda float64, bestAccuracy float64) {
-	// Split data into folds for cross-validation
-	foldSize := len(XTrain) / numFolds
 	var bestAcc float64
 	var bestLambdaValue float64
 
@@ -267,29 +234,19 @@ func GridSearchCV(XTrain []ranking.Features, YTrain []int, lambdaValues []float6
 		var totalAccuracy float64
 
 		// Perform cross-validation
-		for fold := 0; fold < numFolds; fold++ {
-			// Split training data into train and validation sets
-			startIdx := fold * foldSize
-			endIdx := startIdx + foldSize
-			if fold == numFolds-1 {
-				endIdx = len(XTrain) // For the last fold, include the rest of the data
-			}
-
+		for _, fold := range folds {
 			// Create training and validation sets
-			XTrainFold := append(XTrain[:startIdx], XTrain[endIdx:]...)
-			YTrainFold := append(YTrain[:startIdx], YTrain[endIdx:]...)
-			XValFold := XTrain[startIdx:endIdx]
-			YValFold := YTrain[startIdx:endIdx]
+			XTrainFold, YTrainFold := Subset(XTrain, YTrain, fold.Train)
+			XValFold, YValFold := Subset(XTrain, YTrain, fold.Validation)
 
 			// Train the model with the given lambda
-			lr := NewLogisticRegression(lambda)
-			err := lr.Train(XTrainFold, YTrainFold, 0.02, 100)
+			model, err := train(lambda, XTrainFold, YTrainFold)
 			if err != nil {
 				log.Fatal(err)
 			}
 
 			// Evaluate the model on the validation set
-			accuracy := evaluateModel(lr, XValFold, YValFold)
+			accuracy := evaluateModel(model, XValFold, YValFold)
 			totalAccuracy += accuracy
 		}
 
@@ -308,27 +265,6 @@ func GridSearchCV(XTrain []ranking.Features, YTrain []int, lambdaValues []float6
 }
 
 // evaluateModel evaluates the trained model on a validation set
-func evaluateModel(lr *LogisticRegression, XVal []ranking.Features, YVal []int) float64 {
-	TP, FP, TN, FN := 0, 0, 0, 0
-
-	// Make predictions and evaluate on the validation data
-	for i, features := range XVal {
-		class := lr.PredictClass(features)
-		actual := YVal[i]
-
-		// Update confusion matrix based on the prediction and actual class
-		if class == 1 && actual == 1 {
-			TP++ // True Positive
-		} else if class == 1 && actual == -1 {
-			FP++ // False Positive
-		} else if class == -1 && actual == -1 {
-			TN++ // True Negative
-		} else if class == -1 && actual == 1 {
-			FN++ // False Negative
-		}
-	}
-
-	// Calculate accuracy
-	accuracy := float64(TP+TN) / float64(TP+FP+TN+FN) * 100
-	return accuracy
+func evaluateModel(model VectorModel, XVal []features.Vector, YVal []int) float64 {
+	return Confusion(model, XVal, YVal).Accuracy()
 }
@@ -0,0 +1,82 @@
+package training
+
+import (
+	"fmt"
+	"io"
+	"rpi-search-ranking/internal/datagen"
+	"rpi-search-ranking/internal/features"
+)
+
+// LoadTrainTest loads the train and test datasets of a trainer, which must have the same features
+func LoadTrainTest(trainFile, testFile string) (train, test datagen.Dataset, err error) {
+	if train, err = datagen.LoadDataset(trainFile); err != nil {
+		return train, test, fmt.Errorf("loading train data: %v", err)
+	}
+	if test, err = datagen.LoadDataset(testFile); err != nil {
+		return train, test, fmt.Errorf("loading test data: %v", err)
+	}
+	if !train.Schema.Equal(test.Schema) {
+		return train, test, fmt.Errorf("train and test data have different features")
+	}
+	return train, test, nil
+}
+
+// HoldOut holds out the examples of queries of train, about fraction of all examples, to stop training when the
+// validation loss has not improved for patience epochs. It returns the remaining training examples, or all of
+// them and nil early stopping when fraction is 0.
+func HoldOut(train datagen.Dataset, fraction float64, patience int) ([]features.Vector, []int, *EarlyStopping, error) {
+	if fraction <= 0 {
+		return train.X, train.Y, nil, nil
+	}
+	split, err := GroupSplit(train.QIDs, len(train.X), fraction)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("splitting validation data: %v", err)
+	}
+	stopping := &EarlyStopping{Patience: patience}
+	stopping.X, stopping.Y = Subset(train.X, train.Y, split.Validation)
+	X, Y := Subset(train.X, train.Y, split.Train)
+	return X, Y, stopping, nil
+}
+
+// ConfusionMatrix counts the predictions of a model on pairs labeled 1 and -1
+type ConfusionMatrix struct {
+	TP, FP, TN, FN int
+}
+
+// Confusion predicts the class of every example with the model
+func Confusion(model VectorModel, X []features.Vector, Y []int) ConfusionMatrix {
+	var c ConfusionMatrix
+
+	// Make predictions and evaluate on the data
+	for i, x := range X {
+		class := model.PredictVector(x)
+		actual := Y[i]
+
+		// Update confusion matrix based on the prediction and actual class
+		if class == 1 && actual == 1 {
+			c.TP++ // True Positive
+		} else if class == 1 && actual == -1 {
+			c.FP++ // False Positive
+		} else if class == -1 && actual == -1 {
+			c.TN++ // True Negative
+		} else if class == -1 && actual == 1 {
+			c.FN++ // False Negative
+		}
+	}
+	return c
+}
+
+// Accuracy returns the percentage of correct predictions
+func (c ConfusionMatrix) Accuracy() float64 {
+	return float64(c.TP+c.TN) / float64(c.TP+c.FP+c.TN+c.FN) * 100
+}
+
+// Print writes the test accuracy and the confusion matrix
+func (c ConfusionMatrix) Print(w io.Writer) {
+	fmt.Fprintf(w, "Test Accuracy: %.2f%%\n", c.Accuracy())
+	fmt.Fprintf(w, "Confusion Matrix:\n")
+	fmt.Fprintf(w, "              Predicted\n")
+	fmt.Fprintf(w, "              1     -1\n")
+	fmt.Fprintf(w, "Actual  1    %d    %d\n", c.TP, c.FN)
+	fmt.Fprintf(w, "        -1   %d    %d\n", c.FP, c.TN)
+}
@@ -0,0 +1,86 @@
+package training
+
+import (
+	"path/filepath"
+	"rpi-search-ranking/internal/datagen"
+	"rpi-search-ranking/internal/features"
+	"testing"
+)
+
+func TestLoadTrainTest(t *testing.T) {
+	dir := t.TempDir()
+	save := func(name string, schema features.Schema) string {
+		filename := filepath.Join(dir, name)
+		dataset := datagen.Dataset{Schema: schema, X: []features.Vector{make(features.Vector, schema.Len())}, Y: []int{1}, QIDs: []int{1}}
+		if err := datagen.SaveDataset(filename, dataset); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	trainFile := save("train.gob", features.NumberedSchema(2))
+	testFile := save("test.gob", features.NumberedSchema(2))
+	otherFile := save("other.gob", features.NumberedSchema(3))
+
+	train, test, err := LoadTrainTest(trainFile, testFile)
+	if err != nil {
+		t.Fatalf("LoadTrainTest() error = %v", err)
+	}
+	if len(train.X) != 1 || len(test.X) != 1 {
+		t.Errorf("LoadTrainTest() loaded %d and %d examples, want 1 and 1", len(train.X), len(test.X))
+	}
+	if _, _, err := LoadTrainTest(trainFile, otherFile); err == nil {
+		t.Errorf("LoadTrainTest() of datasets with different features succeeded")
+	}
+	if _, _, err := LoadTrainTest(filepath.Join(dir, "missing.gob"), testFile); err == nil {
+		t.Errorf("LoadTrainTest() of a missing file succeeded")
+	}
+}
+
+func TestHoldOut(t *testing.T) {
+	train := datagen.Dataset{
+		X:    []features.Vector{{0}, {1}, {2}, {3}, {4}, {5}},
+		Y:    []int{1, -1, 1, -1, 1, -1},
+		QIDs: []int{1, 1, 2, 2, 3, 3},
+	}
+
+	X, Y, stopping, err := HoldOut(train, 0, 5)
+	if err != nil || stopping != nil || len(X) != len(train.X) || len(Y) != len(train.Y) {
+		t.Errorf("HoldOut(0) = %d examples, %v, %v, want all examples and no early stopping", len(X), stopping, err)
+	}
+
+	X, Y, stopping, err = HoldOut(train, 0.3, 7)
+	if err != nil {
+		t.Fatalf("HoldOut() error = %v", err)
+	}
+	if stopping == nil || stopping.Patience != 7 {
+		t.Fatalf("HoldOut() early stopping = %+v, want patience 7", stopping)
+	}
+	if len(X)+len(stopping.X) != len(train.X) || len(Y) != len(X) || len(stopping.Y) != len(stopping.X) {
+		t.Errorf("HoldOut() split %d examples into %d and %d", len(train.X), len(X), len(stopping.X))
+	}
+	if len(stopping.X)%2 != 0 {
+		t.Errorf("HoldOut() split the pairs of a query, holding out %d examples", len(stopping.X))
+	}
+}
+
+// constantModel predicts the same class for every vector
+type constantModel int
+
+func (m constantModel) Schema() features.Schema             { return features.NumberedSchema(1) }
+func (m constantModel) PredictVector(x features.Vector) int { return int(m) }
+
+func TestConfusion(t *testing.T) {
+	X := []features.Vector{{0}, {0}, {0}, {0}}
+	Y := []int{1, 1, 1, -1}
+
+	got := Confusion(constantModel(1), X, Y)
+	if want := (ConfusionMatrix{TP: 3, FP: 1}); got != want {
+		t.Errorf("Confusion() = %+v, want %+v", got, want)
+	}
+	if got.Accuracy() != 75 {
+		t.Errorf("Accuracy() = %v, want 75", got.Accuracy())
+	}
+	if got := Confusion(constantModel(-1), X, Y); got != (ConfusionMatrix{TN: 1, FN: 3}) {
+		t.Errorf("Confusion() = %+v, want 1 true negative and 3 false negatives", got)
+	}
+}
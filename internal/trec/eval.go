@@ -0,0 +1,192 @@
+package trec
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"rpi-search-ranking/internal/metrics"
+	"sort"
+)
+
+// Cutoffs of the precision and NDCG measures, a subset of those reported by trec_eval
+var (
+	precisionCutoffs = []int{5, 10, 20, 30, 100}
+	ndcgCutoffs      = []int{5, 10, 20}
+)
+
+// Measure is a single named value of a trec_eval report
+type Measure struct {
+	Name    string
+	Value   float64
+	Integer bool // counts are printed without decimals and summed instead of averaged
+}
+
+// Result holds the measures of a query, or of all queries when QueryID is "all"
+type Result struct {
+	QueryID  string
+	Measures []Measure
+}
+
+// Evaluate computes trec_eval measures for every judged query of the run, sorted by query ID, and their summary.
+// Documents with a relevance of at least 1 count as relevant. Queries without judgments are skipped, and judged
+// queries missing from the run are only included, with no documents retrieved, when complete is set (trec_eval -c).
+func Evaluate(run Run, qrels Qrels, complete bool) ([]Result, Result) {
+	var queryIDs []string
+	for queryID := range qrels {
+		if _, ok := run[queryID]; ok || complete {
+			queryIDs = append(queryIDs, queryID)
+		}
+	}
+	sort.Strings(queryIDs)
+
+	results := make([]Result, len(queryIDs))
+	for i, queryID := range queryIDs {
+		entries := append([]RunEntry(nil), run[queryID]...)
+		sortEntries(entries)
+		results[i] = evaluateQuery(queryID, entries, qrels[queryID])
+	}
+	return results, summarize(results)
+}
+
+func evaluateQuery(queryID string, entries []RunEntry, judgments map[string]int) Result {
+	relevances := make([]int, len(entries))
+	var numRelRet int
+	for i, entry := range entries {
+		relevances[i] = judgments[entry.DocID]
+		if relevances[i] >= metrics.DefaultRelevanceThreshold {
+			numRelRet++
+		}
+	}
+	ideal := make([]int, 0, len(judgments))
+	var numRel int
+	for _, relevance := range judgments {
+		ideal = append(ideal, relevance)
+		if relevance >= metrics.DefaultRelevanceThreshold {
+			numRel++
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+
+	measures := []Measure{
+		{Name: "num_ret", Value: float64(len(entries)), Integer: true},
+		{Name: "num_rel", Value: float64(numRel), Integer: true},
+		{Name: "num_rel_ret", Value: float64(numRelRet), Integer: true},
+		{Name: "map", Value: averagePrecision(relevances, numRel)},
+		{Name: "Rprec", Value: rPrecision(relevances, numRel)},
+		{Name: "recip_rank", Value: metrics.ReciprocalRank(relevances, metrics.DefaultRelevanceThreshold)},
+	}
+	for _, k := range precisionCutoffs {
+		measures = append(measures, Measure{
+			Name:  fmt.Sprintf("P_%d", k),
+			Value: metrics.PrecisionAt(relevances, k, metrics.DefaultRelevanceThreshold),
+		})
+	}
+	measures = append(measures, Measure{Name: "ndcg", Value: ndcg(relevances, ideal, 0)})
+	for _, k := range ndcgCutoffs {
+		measures = append(measures, Measure{Name: fmt.Sprintf("ndcg_cut_%d", k), Value: ndcg(relevances, ideal, k)})
+	}
+	return Result{QueryID: queryID, Measures: measures}
+}
+
+// averagePrecision divides by all relevant documents in the judgments, not only the retrieved ones
+func averagePrecision(relevances []int, numRel int) float64 {
+	if numRel == 0 {
+		return 0
+	}
+	var hits int
+	var sum float64
+	for i, relevance := range relevances {
+		if relevance >= metrics.DefaultRelevanceThreshold {
+			hits++
+			sum += float64(hits) / float64(i+1)
+		}
+	}
+	return sum / float64(numRel)
+}
+
+// rPrecision is the precision after numRel documents
+func rPrecision(relevances []int, numRel int) float64 {
+	if numRel == 0 {
+		return 0
+	}
+	return metrics.PrecisionAt(relevances, numRel, metrics.DefaultRelevanceThreshold)
+}
+
+// ndcg uses trec_eval's linear gains, where the gain of a document is its relevance grade
+func ndcg(relevances, ideal []int, k int) float64 {
+	idcg := linearDCG(ideal, k)
+	if idcg == 0 {
+		return 0
+	}
+	return linearDCG(relevances, k) / idcg
+}
+
+func linearDCG(relevances []int, k int) float64 {
+	if k <= 0 || k > len(relevances) {
+		k = len(relevances)
+	}
+	var dcg float64
+	for i, relevance := range relevances[:k] {
+		if relevance > 0 {
+			dcg += float64(relevance) / math.Log2(float64(i+2))
+		}
+	}
+	return dcg
+}
+
+// summarize sums the counts and averages the other measures over all queries
+func summarize(results []Result) Result {
+	summary := Result{QueryID: "all"}
+	if len(results) == 0 {
+		return summary
+	}
+	summary.Measures = make([]Measure, len(results[0].Measures))
+	for i, measure := range results[0].Measures {
+		summary.Measures[i] = Measure{Name: measure.Name, Integer: measure.Integer}
+	}
+	for _, result := range results {
+		for i, measure := range result.Measures {
+			summary.Measures[i].Value += measure.Value
+		}
+	}
+	for i := range summary.Measures {
+		if !summary.Measures[i].Integer {
+			summary.Measures[i].Value /= float64(len(results))
+		}
+	}
+	return summary
+}
+
+// WriteResults prints results in trec_eval's "measure<TAB>qid<TAB>value" format, per query when perQuery is set,
+// followed by the run ID, number of queries and summary
+func WriteResults(w io.Writer, tag string, results []Result, summary Result, perQuery bool) error {
+	if perQuery {
+		for _, result := range results {
+			if err := writeResult(w, result); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fmt.Fprintf(w, "%-22s\t%s\t%s\n", "runid", "all", tag); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%-22s\t%s\t%d\n", "num_q", "all", len(results)); err != nil {
+		return err
+	}
+	return writeResult(w, summary)
+}
+
+func writeResult(w io.Writer, result Result) error {
+	for _, measure := range result.Measures {
+		var err error
+		if measure.Integer {
+			_, err = fmt.Fprintf(w, "%-22s\t%s\t%d\n", measure.Name, result.QueryID, int(measure.Value))
+		} else {
+			_, err = fmt.Fprintf(w, "%-22s\t%s\t%.4f\n", measure.Name, result.QueryID, measure.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
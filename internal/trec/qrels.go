@@ -0,0 +1,36 @@
+package trec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Qrels maps query IDs to the graded judgments of their documents
+type Qrels map[string]map[string]int
+
+// ReadQrels reads judgments in the standard "qid iteration docno relevance" format
+func ReadQrels(r io.Reader) (Qrels, error) {
+	qrels := Qrels{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 fields, found %d", lineNumber, len(fields))
+		}
+		relevance, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid relevance %q", lineNumber, fields[3])
+		}
+		if qrels[fields[0]] == nil {
+			qrels[fields[0]] = map[string]int{}
+		}
+		qrels[fields[0]][fields[2]] = relevance
+	}
+	return qrels, scanner.Err()
+}
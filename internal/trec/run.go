@@ -0,0 +1,73 @@
+package trec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RunEntry is a single retrieved document of a run
+type RunEntry struct {
+	DocID string
+	Rank  int
+	Score float64
+}
+
+// Run maps query IDs to their retrieved documents
+type Run map[string][]RunEntry
+
+// WriteRun writes the entries of a query as "qid Q0 docno rank score tag" lines
+func WriteRun(w io.Writer, queryID string, entries []RunEntry, tag string) error {
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "%s Q0 %s %d %g %s\n", queryID, entry.DocID, entry.Rank, entry.Score, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadRun reads a run file. Like trec_eval, entries are ordered by descending score, ties broken by descending docno,
+// and the rank column is ignored.
+func ReadRun(r io.Reader) (Run, error) {
+	run := Run{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("line %d: expected 6 fields, found %d", lineNumber, len(fields))
+		}
+		rank, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rank %q", lineNumber, fields[3])
+		}
+		score, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid score %q", lineNumber, fields[4])
+		}
+		run[fields[0]] = append(run[fields[0]], RunEntry{DocID: fields[2], Rank: rank, Score: score})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, entries := range run {
+		sortEntries(entries)
+	}
+	return run, nil
+}
+
+// sortEntries orders entries the way trec_eval does
+func sortEntries(entries []RunEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].DocID > entries[j].DocID
+	})
+}
@@ -0,0 +1,75 @@
+package trec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Topic is a single query of a topics file
+type Topic struct {
+	ID    string
+	Title string
+}
+
+// Tags of the classic TREC topic format
+var (
+	topPattern   = regexp.MustCompile(`(?i)</?top>`)
+	numPattern   = regexp.MustCompile(`(?is)<num>\s*(?:Number:)?\s*(\S+)`)
+	titlePattern = regexp.MustCompile(`(?is)<title>\s*(?:Topic:)?(.*?)(?:<|$)`)
+)
+
+// ReadTopics reads topics in the classic TREC format (<top><num>…<title>…</top>) or,
+// when the file has no <top> tags, one "id<TAB>text" or "id text" topic per line
+func ReadTopics(r io.Reader) ([]Topic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if topPattern.MatchString(text) {
+		return parseTRECTopics(text)
+	}
+	return parseLineTopics(text)
+}
+
+func parseTRECTopics(text string) ([]Topic, error) {
+	var topics []Topic
+	for _, block := range topPattern.Split(text, -1) {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		num := numPattern.FindStringSubmatch(block)
+		title := titlePattern.FindStringSubmatch(block)
+		if num == nil || title == nil {
+			return nil, fmt.Errorf("topic without <num> or <title>: %q", strings.TrimSpace(block))
+		}
+		topics = append(topics, Topic{ID: num[1], Title: strings.Join(strings.Fields(title[1]), " ")})
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics between <top> tags")
+	}
+	return topics, nil
+}
+
+func parseLineTopics(text string) ([]Topic, error) {
+	var topics []Topic
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, title, ok := strings.Cut(line, "\t")
+		if !ok {
+			id, title, ok = strings.Cut(line, " ")
+		}
+		if !ok || strings.TrimSpace(title) == "" {
+			return nil, fmt.Errorf("line %d: expected topic ID and text", lineNumber)
+		}
+		topics = append(topics, Topic{ID: strings.TrimSpace(id), Title: strings.TrimSpace(title)})
+	}
+	return topics, scanner.Err()
+}
@@ -0,0 +1,166 @@
+package trec
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTopics(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Topic
+	}{
+		{
+			"TREC format",
+			"<top>\n<num> Number: 301\n<title> International  Organized Crime\n<desc> Description:\nIgnored\n</top>\n" +
+				"<top>\n<num> Number: 302 <title> Topic: Poliomyelitis\n</top>\n",
+			[]Topic{{ID: "301", Title: "International Organized Crime"}, {ID: "302", Title: "Poliomyelitis"}},
+		},
+		{
+			"Uppercase TREC tags",
+			"<TOP>\n<NUM> Number: 301\n<TITLE> Organized Crime\n</TOP>\n<Top><num> 302 <title> Poliomyelitis</Top>\n",
+			[]Topic{{ID: "301", Title: "Organized Crime"}, {ID: "302", Title: "Poliomyelitis"}},
+		},
+		{
+			"Line format",
+			"# comment\n1\trpi computer science\n2 troy ny\n",
+			[]Topic{{ID: "1", Title: "rpi computer science"}, {ID: "2", Title: "troy ny"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadTopics(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ReadTopics() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadTopics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadTopics(strings.NewReader("missing-text\n")); err == nil {
+		t.Errorf("ReadTopics() accepted a topic without text")
+	}
+	if _, err := ReadTopics(strings.NewReader("<TOP>\n</TOP>\n")); err == nil {
+		t.Errorf("ReadTopics() accepted a TREC file without topics")
+	}
+}
+
+func TestReadQrels(t *testing.T) {
+	got, err := ReadQrels(strings.NewReader("1 0 doc1 2\n1 0 doc2 0\n\n2 0 doc3 1\n"))
+	if err != nil {
+		t.Fatalf("ReadQrels() error = %v", err)
+	}
+	want := Qrels{"1": {"doc1": 2, "doc2": 0}, "2": {"doc3": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadQrels() = %v, want %v", got, want)
+	}
+
+	if _, err := ReadQrels(strings.NewReader("1 0 doc1\n")); err == nil {
+		t.Errorf("ReadQrels() accepted a line with 3 fields")
+	}
+}
+
+func TestRunRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []RunEntry{{DocID: "doc1", Rank: 1, Score: 2.5}, {DocID: "doc2", Rank: 2, Score: 1}}
+	if err := WriteRun(&buf, "1", entries, "bm25"); err != nil {
+		t.Fatalf("WriteRun() error = %v", err)
+	}
+	if want := "1 Q0 doc1 1 2.5 bm25\n1 Q0 doc2 2 1 bm25\n"; buf.String() != want {
+		t.Errorf("WriteRun() wrote %q, want %q", buf.String(), want)
+	}
+
+	// Ranks are ignored and ties are broken by descending docno
+	run, err := ReadRun(strings.NewReader("1 Q0 a 1 1 tag\n1 Q0 b 2 1 tag\n1 Q0 c 3 3 tag\n"))
+	if err != nil {
+		t.Fatalf("ReadRun() error = %v", err)
+	}
+	var order []string
+	for _, entry := range run["1"] {
+		order = append(order, entry.DocID)
+	}
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ReadRun() order = %v, want %v", order, want)
+	}
+}
+
+func measure(result Result, name string) float64 {
+	for _, m := range result.Measures {
+		if m.Name == name {
+			return m.Value
+		}
+	}
+	return math.NaN()
+}
+
+func TestEvaluate(t *testing.T) {
+	qrels := Qrels{
+		"1": {"d1": 2, "d2": 0, "d3": 1, "d4": 1},
+		"2": {"d5": 1},
+		"3": {"d6": 1},
+	}
+	run := Run{
+		"1": {{DocID: "d1", Score: 3}, {DocID: "d2", Score: 2}, {DocID: "d3", Score: 1}},
+		"2": {{DocID: "d9", Score: 1}},
+		"9": {{DocID: "d1", Score: 1}},
+	}
+
+	results, summary := Evaluate(run, qrels, false)
+	if len(results) != 2 || results[0].QueryID != "1" || results[1].QueryID != "2" {
+		t.Fatalf("Evaluate() evaluated %v, want queries 1 and 2", results)
+	}
+
+	// Query 1 retrieves 2 of its 3 relevant documents at ranks 1 and 3
+	idealDCG := 2 + 1/math.Log2(3) + 1/math.Log2(4)
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"num_ret", 3},
+		{"num_rel", 3},
+		{"num_rel_ret", 2},
+		{"map", (1 + 2.0/3) / 3},
+		{"Rprec", 2.0 / 3},
+		{"recip_rank", 1},
+		{"P_5", 0.4},
+		{"ndcg", (2 + 1/math.Log2(4)) / idealDCG},
+		{"ndcg_cut_5", (2 + 1/math.Log2(4)) / idealDCG},
+	}
+	for _, tt := range tests {
+		if got := measure(results[0], tt.name); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s of query 1 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := measure(summary, "num_ret"); got != 4 {
+		t.Errorf("summary num_ret = %v, want the sum 4", got)
+	}
+	if got, want := measure(summary, "map"), (1+2.0/3)/3/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("summary map = %v, want the mean %v", got, want)
+	}
+
+	if results, _ := Evaluate(run, qrels, true); len(results) != 3 {
+		t.Errorf("Evaluate() with complete evaluated %d queries, want 3", len(results))
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	var buf bytes.Buffer
+	summary := Result{QueryID: "all", Measures: []Measure{{Name: "num_ret", Value: 4, Integer: true}, {Name: "map", Value: 0.25}}}
+	if err := WriteResults(&buf, "bm25", nil, summary, false); err != nil {
+		t.Fatalf("WriteResults() error = %v", err)
+	}
+	want := "runid                 \tall\tbm25\n" +
+		"num_q                 \tall\t0\n" +
+		"num_ret               \tall\t4\n" +
+		"map                   \tall\t0.2500\n"
+	if buf.String() != want {
+		t.Errorf("WriteResults() wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
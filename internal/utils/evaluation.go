@@ -5,15 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
+	"rpi-search-ranking/internal/logging"
+	"rpi-search-ranking/internal/storage"
 	"time"
 )
 
+var logger = logging.For("evaluation")
+
+// DefaultEvaluationEndpoint is the evaluation component's collector URL
+const DefaultEvaluationEndpoint = "http://lspt-link-analysis.cs.rpi.edu:1234/evaluation/add_node/update_node_info"
+
 type Evaluation struct {
-	TotalStorage     byte          `json:"total_storage"`         // Total number of bytes used for storage
-	AlgorithmRunTime time.Duration `json:"algorithm_update_time"` // Time to update algorithm
-	QueryData        *QueryInfo    `json:"query_data"`            // Pointer to query information
+	TotalStorage     int64         `json:"total_storage"`            // Total number of bytes used for storage
+	AlgorithmRunTime time.Duration `json:"algorithm_update_time"`    // Time to update algorithm
+	QueryData        *QueryInfo    `json:"query_data"`               // Pointer to query information
+	Experiment       string        `json:"experiment,omitempty"`     // Experiment bucket or interleaving experiment of the query
+	ShadowRankers    []string      `json:"shadow_rankers,omitempty"` // Shadow rankers run on the query
 }
 
 type QueryInfo struct {
@@ -33,7 +40,7 @@ func CreateEvaluation() *Evaluation {
 	}
 
 	return &Evaluation{
-		TotalStorage:     byte(0),
+		TotalStorage:     0,
 		AlgorithmRunTime: 0, 
 		QueryData:        queryData,
 	}
@@ -51,33 +58,13 @@ func (e *Evaluation) SerializeToJson() (string, error) {
 }
 
 
-// Counts the amount of storage that ranking component is storing. 
+// UpdateStorageSize sets TotalStorage to the number of bytes stored below dirPath
 func (e *Evaluation) UpdateStorageSize(dirPath string) error {
-	var count int
-	stack := []string{dirPath}
-
-	for len(stack) > 0 {
-		// Pop the last directory off the stack
-		currentDir := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		// Open the directory
-		dirEntries, err := os.ReadDir(currentDir)
-		if err != nil {
-			return err
-		}
-
-		// Iterate over each entry in the directory
-		for _, entry := range dirEntries {
-			entryPath := filepath.Join(currentDir, entry.Name())
-			count++
-
-			// If the entry is a directory, add it to the stack to visit later
-			if entry.IsDir() {
-				stack = append(stack, entryPath)
-			}
-		}
+	size, err := storage.DirSize(dirPath)
+	if err != nil {
+		return err
 	}
+	e.TotalStorage = size
 	return nil
 }
 
@@ -93,7 +80,7 @@ func SendEvaluation(evaluation *Evaluation) error {
 	}
 
 	// New endpoint URL
-	serverURL := "http://lspt-link-analysis.cs.rpi.edu:1234/evaluation/add_node/update_node_info"
+	serverURL := DefaultEvaluationEndpoint
 
 	// Create a new POST request with JSON data in the body
 	req, err := http.NewRequest("POST", serverURL, bytes.NewBuffer([]byte(jsonStr)))
@@ -104,8 +91,8 @@ func SendEvaluation(evaluation *Evaluation) error {
 	// Set the content-type header to application/json
 	req.Header.Set("Content-Type", "application/json")
 
-	// Send the request using the default HTTP client
-	client := &http.Client{}
+	// Send the request with the reporter's timeout so a slow collector cannot block the caller
+	client := &http.Client{Timeout: DefaultReporterConfig().Timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error sending request: %v", err)
@@ -122,7 +109,7 @@ func SendEvaluation(evaluation *Evaluation) error {
 	// fmt.Println("Response body:", string(body))
 
 	// Successful request
-	fmt.Println("Successfully sent evaluation to server")
+	logger.Debug("sent evaluation to server", "url", serverURL)
 	return nil
 }
 
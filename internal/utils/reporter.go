@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"rpi-search-ranking/internal/monitoring"
+	"sync"
+	"time"
+)
+
+// Number of batches of spilled evaluations resent after each successful batch
+const resendBatches = 4
+
+// ReporterConfig configures a Reporter
+type ReporterConfig struct {
+	Endpoint       string        // collector URL receiving evaluations
+	Batch          bool          // post JSON arrays of evaluations instead of one evaluation per request
+	QueueSize      int           // evaluations buffered before new ones are dropped
+	BatchSize      int           // maximum evaluations flushed together, and posted together with Batch
+	FlushInterval  time.Duration // maximum time an evaluation waits for its batch to fill
+	MaxRetries     int           // retries of a failed batch before it is spilled
+	InitialBackoff time.Duration // wait before the first retry, doubled for every further retry
+	Timeout        time.Duration // timeout of a single request to the collector
+	SpillFile      string        // JSONL file receiving batches the collector did not accept, empty discards them
+}
+
+// DefaultReporterConfig returns the reporter settings used by the ranking API
+func DefaultReporterConfig() ReporterConfig {
+	return ReporterConfig{
+		Endpoint:       DefaultEvaluationEndpoint,
+		QueueSize:      1000,
+		BatchSize:      50,
+		FlushInterval:  5 * time.Second,
+		MaxRetries:     3,
+		InitialBackoff: 500 * time.Millisecond,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Reporter sends evaluations to the collector in batches from a background goroutine.
+// A nil *Reporter is valid and discards all evaluations, which disables reporting.
+type Reporter struct {
+	config ReporterConfig
+	client *http.Client
+
+	mu          sync.Mutex // guards closed and sends on queue
+	closed      bool
+	queue       chan *Evaluation
+	closing     chan struct{} // closed by Close to cut retries short
+	closingOnce sync.Once
+	done        chan struct{} // closed once the last batch has been handled
+
+	// Only used by the run goroutine
+	spillSize   int64 // bytes of the spill file
+	spillOffset int64 // bytes of the spill file that were resent
+}
+
+// NewReporter starts a reporter with the given configuration
+func NewReporter(config ReporterConfig) *Reporter {
+	r := &Reporter{
+		config:  config,
+		client:  &http.Client{Timeout: config.Timeout},
+		queue:   make(chan *Evaluation, config.QueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	// Evaluations spilled before a restart are resent too
+	if config.SpillFile != "" {
+		if info, err := os.Stat(config.SpillFile); err == nil {
+			r.spillSize = info.Size()
+		}
+	}
+	go r.run()
+	return r
+}
+
+// Report queues an evaluation without blocking, dropping it if the queue is full or the reporter is closed
+func (r *Reporter) Report(evaluation *Evaluation) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		monitoring.EvaluationReports.Inc("dropped")
+		return false
+	}
+	select {
+	case r.queue <- evaluation:
+		return true
+	default:
+		monitoring.EvaluationReports.Inc("dropped")
+		return false
+	}
+}
+
+// Close stops accepting evaluations and flushes the queue, spilling what cannot be sent before ctx is done
+func (r *Reporter) Close(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		r.closingOnce.Do(func() { close(r.closing) })
+		<-r.done
+		return ctx.Err()
+	}
+}
+
+// run collects queued evaluations into batches and sends them when full or when the flush interval passes
+func (r *Reporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Evaluation, 0, r.config.BatchSize)
+	for {
+		select {
+		case evaluation, ok := <-r.queue:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, evaluation)
+			if len(batch) >= r.config.BatchSize {
+				r.flush(batch)
+				batch = make([]*Evaluation, 0, r.config.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = make([]*Evaluation, 0, r.config.BatchSize)
+			}
+		}
+	}
+}
+
+// flush sends a batch with retries and spills it if the collector does not accept it.
+// After a successful send, previously spilled evaluations are resent.
+func (r *Reporter) flush(batch []*Evaluation) {
+	if len(batch) == 0 {
+		return
+	}
+
+	sent, err := r.sendWithRetries(batch)
+	monitoring.EvaluationReports.Add(float64(sent), "sent")
+	if err != nil {
+		logger.Warn("failed to send evaluations, spilling to file", "count", len(batch)-sent, "error", err)
+		r.spill(batch[sent:])
+		return
+	}
+	r.resendSpilled()
+}
+
+// sendWithRetries sends a batch, retrying the evaluations that were not accepted with exponential backoff unless
+// the reporter is closing. It returns the number of evaluations sent.
+func (r *Reporter) sendWithRetries(batch []*Evaluation) (int, error) {
+	backoff := r.config.InitialBackoff
+	sent := 0
+	var err error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-r.closing:
+				return sent, fmt.Errorf("reporter closed while retrying: %v", err)
+			}
+		}
+		var n int
+		n, err = r.send(batch[sent:])
+		sent += n
+		if err == nil {
+			return sent, nil
+		}
+	}
+	return sent, err
+}
+
+// send posts evaluations to the collector, as one JSON array with Batch or one JSON object per request otherwise.
+// It returns the number of evaluations the collector accepted before an error.
+func (r *Reporter) send(evaluations []*Evaluation) (int, error) {
+	if r.config.Batch {
+		if err := r.post(evaluations); err != nil {
+			return 0, err
+		}
+		return len(evaluations), nil
+	}
+	for i, evaluation := range evaluations {
+		if err := r.post(evaluation); err != nil {
+			return i, err
+		}
+	}
+	return len(evaluations), nil
+}
+
+// post sends a JSON body to the collector
+func (r *Reporter) post(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error serializing evaluations: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// spill appends evaluations to the spill file, one JSON object per line
+func (r *Reporter) spill(batch []*Evaluation) {
+	if r.config.SpillFile == "" {
+		monitoring.EvaluationReports.Add(float64(len(batch)), "failed")
+		return
+	}
+
+	err := func() error {
+		if err := os.MkdirAll(filepath.Dir(r.config.SpillFile), os.ModePerm); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(r.config.SpillFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		// A file replaced by the storage manager starts a new spill
+		if info, err := file.Stat(); err == nil && info.Size() < r.spillSize {
+			r.spillSize, r.spillOffset = info.Size(), 0
+		}
+		var lines bytes.Buffer
+		encoder := json.NewEncoder(&lines)
+		for _, evaluation := range batch {
+			if err := encoder.Encode(evaluation); err != nil {
+				file.Close()
+				return err
+			}
+		}
+		n, err := file.Write(lines.Bytes())
+		r.spillSize += int64(n)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}()
+	if err != nil {
+		logger.Error("failed to spill evaluations", "file", r.config.SpillFile, "count", len(batch), "error", err)
+		monitoring.EvaluationReports.Add(float64(len(batch)), "failed")
+		return
+	}
+	monitoring.EvaluationReports.Add(float64(len(batch)), "spilled")
+}
+
+// resendSpilled sends up to resendBatches batches of spilled evaluations, continuing from the last resent
+// evaluation, and removes the spill file once all were accepted. Nothing is read while the spill is empty.
+func (r *Reporter) resendSpilled() {
+	if r.config.SpillFile == "" || r.spillOffset >= r.spillSize {
+		return
+	}
+	file, err := os.Open(r.config.SpillFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Removed by the storage manager, which applies a quota to the spill
+			r.spillSize, r.spillOffset = 0, 0
+		} else {
+			logger.Warn("failed to open spill file", "file", r.config.SpillFile, "error", err)
+		}
+		return
+	}
+	defer file.Close()
+
+	// The storage manager may have replaced the file since it was last read
+	info, err := file.Stat()
+	if err != nil {
+		logger.Warn("failed to read spill file", "file", r.config.SpillFile, "error", err)
+		return
+	}
+	if r.spillSize = info.Size(); r.spillOffset > r.spillSize {
+		r.spillOffset = 0
+	}
+	if _, err := file.Seek(r.spillOffset, io.SeekStart); err != nil {
+		logger.Warn("failed to read spill file", "file", r.config.SpillFile, "error", err)
+		return
+	}
+
+	reader := bufio.NewReader(file)
+	for range resendBatches {
+		// Read the next batch, remembering the offset after each evaluation so a failure resends only unsent ones
+		var batch []*Evaluation
+		var offsets []int64
+		offset := r.spillOffset
+		for len(batch) < r.config.BatchSize {
+			line, readErr := reader.ReadBytes('\n')
+			if len(line) == 0 {
+				break
+			}
+			offset += int64(len(line))
+			evaluation := &Evaluation{}
+			if err := json.Unmarshal(line, evaluation); err != nil {
+				// Malformed lines, such as one cut short by a crash, are skipped with the preceding evaluation
+				logger.Warn("skipping malformed spilled evaluation", "error", err)
+				if len(offsets) == 0 {
+					r.spillOffset = offset
+				} else {
+					offsets[len(offsets)-1] = offset
+				}
+			} else {
+				batch = append(batch, evaluation)
+				offsets = append(offsets, offset)
+			}
+			if readErr != nil {
+				break
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		sent, err := r.send(batch)
+		monitoring.EvaluationReports.Add(float64(sent), "sent")
+		if sent > 0 {
+			r.spillOffset = offsets[sent-1]
+		}
+		if err != nil {
+			// The rest is retried after the next successful batch
+			logger.Warn("failed to resend spilled evaluations", "error", err)
+			return
+		}
+	}
+
+	if r.spillOffset >= r.spillSize {
+		if err := os.Remove(r.config.SpillFile); err != nil {
+			logger.Warn("failed to remove spill file", "file", r.config.SpillFile, "error", err)
+			return
+		}
+		r.spillSize, r.spillOffset = 0, 0
+	}
+}
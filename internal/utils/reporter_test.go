@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// collector is a fake evaluation collector that records received requests, each a JSON array of evaluations
+// when batch is set and a single evaluation otherwise
+type collector struct {
+	batch   bool
+	mu      sync.Mutex
+	batches [][]Evaluation
+	failing atomic.Bool
+	accept  atomic.Int64 // requests accepted before failing, when positive
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.failing.Load() || c.accept.Add(-1) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var batch []Evaluation
+	var err error
+	if c.batch {
+		err = json.NewDecoder(r.Body).Decode(&batch)
+	} else {
+		batch = make([]Evaluation, 1)
+		err = json.NewDecoder(r.Body).Decode(&batch[0])
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.batches = append(c.batches, batch)
+	c.mu.Unlock()
+}
+
+func (c *collector) received() (batches, evaluations int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, batch := range c.batches {
+		evaluations += len(batch)
+	}
+	return len(c.batches), evaluations
+}
+
+func testReporterConfig(endpoint, spillFile string) ReporterConfig {
+	return ReporterConfig{
+		Endpoint:       endpoint,
+		QueueSize:      10,
+		BatchSize:      2,
+		FlushInterval:  time.Hour,
+		MaxRetries:     1,
+		InitialBackoff: time.Millisecond,
+		Timeout:        time.Second,
+		SpillFile:      spillFile,
+	}
+}
+
+func TestReporter_Batches(t *testing.T) {
+	tests := []struct {
+		name         string
+		batch        bool
+		wantRequests int
+	}{
+		// Two full batches are sent while reporting and the remaining evaluation on Close
+		{"Arrays", true, 3},
+		{"One evaluation per request", false, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{batch: tt.batch}
+			server := httptest.NewServer(c)
+			defer server.Close()
+
+			config := testReporterConfig(server.URL, "")
+			config.Batch = tt.batch
+			reporter := NewReporter(config)
+			for i := 0; i < 5; i++ {
+				if !reporter.Report(CreateEvaluation()) {
+					t.Fatalf("Report() dropped evaluation %d", i)
+				}
+			}
+			if err := reporter.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if requests, evaluations := c.received(); requests != tt.wantRequests || evaluations != 5 {
+				t.Errorf("collector received %d requests with %d evaluations, want %d with 5", requests, evaluations, tt.wantRequests)
+			}
+			if reporter.Report(CreateEvaluation()) {
+				t.Errorf("Report() accepted an evaluation after Close()")
+			}
+		})
+	}
+}
+
+func TestReporter_SpillAndReplay(t *testing.T) {
+	c := &collector{}
+	c.failing.Store(true)
+	server := httptest.NewServer(c)
+	defer server.Close()
+	spillFile := filepath.Join(t.TempDir(), "spill.jsonl")
+
+	// The collector is down, so both evaluations end up in the spill file
+	reporter := NewReporter(testReporterConfig(server.URL, spillFile))
+	reporter.Report(CreateEvaluation())
+	reporter.Report(CreateEvaluation())
+	if err := reporter.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(spillFile); err != nil {
+		t.Fatalf("spill file not written: %v", err)
+	}
+
+	// The next successful batch replays the spilled evaluations and removes the file
+	c.failing.Store(false)
+	reporter = NewReporter(testReporterConfig(server.URL, spillFile))
+	reporter.Report(CreateEvaluation())
+	if err := reporter.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, evaluations := c.received(); evaluations != 3 {
+		t.Errorf("collector received %d evaluations, want 3", evaluations)
+	}
+	if _, err := os.Stat(spillFile); !os.IsNotExist(err) {
+		t.Errorf("spill file still exists after replay: %v", err)
+	}
+}
+
+func TestReporter_Disabled(t *testing.T) {
+	var reporter *Reporter
+	if reporter.Report(CreateEvaluation()) {
+		t.Errorf("nil Reporter accepted an evaluation")
+	}
+	if err := reporter.Close(context.Background()); err != nil {
+		t.Errorf("nil Reporter Close() error = %v", err)
+	}
+}
+
+func TestReporter_ResendInChunks(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	spillFile := filepath.Join(t.TempDir(), "spill.jsonl")
+
+	// Evaluations spilled before a restart
+	var spilled []byte
+	for i := 0; i < 3*resendBatches*2+1; i++ {
+		line, _ := json.Marshal(CreateEvaluation())
+		spilled = append(append(spilled, line...), '\n')
+	}
+	spilled = append(spilled, "{malformed\n"...)
+	if err := os.WriteFile(spillFile, spilled, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each successful batch of 2 resends at most resendBatches batches of the spill
+	reporter := NewReporter(testReporterConfig(server.URL, spillFile))
+	for i := 0; i < 2; i++ {
+		reporter.Report(CreateEvaluation())
+	}
+	waitFor(t, func() bool { _, evaluations := c.received(); return evaluations == 2+2*resendBatches })
+	if _, err := os.Stat(spillFile); err != nil {
+		t.Errorf("spill file removed before it was resent: %v", err)
+	}
+
+	// Further batches drain the rest, skipping the malformed line, and remove the file
+	for i := 0; i < 6; i++ {
+		reporter.Report(CreateEvaluation())
+	}
+	if err := reporter.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, evaluations := c.received(); evaluations != 8+3*resendBatches*2+1 {
+		t.Errorf("collector received %d evaluations, want %d", evaluations, 8+3*resendBatches*2+1)
+	}
+	if _, err := os.Stat(spillFile); !os.IsNotExist(err) {
+		t.Errorf("spill file still exists after replay: %v", err)
+	}
+}
+
+func TestReporter_PartialFailure(t *testing.T) {
+	c := &collector{}
+	c.accept.Store(2) // the second request fails, and so do retries
+	server := httptest.NewServer(c)
+	defer server.Close()
+	spillFile := filepath.Join(t.TempDir(), "spill.jsonl")
+
+	config := testReporterConfig(server.URL, spillFile)
+	config.MaxRetries = 0
+	reporter := NewReporter(config)
+	reporter.Report(CreateEvaluation())
+	reporter.Report(CreateEvaluation())
+	if err := reporter.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Only the evaluation the collector did not accept is spilled
+	data, err := os.ReadFile(spillFile)
+	if err != nil {
+		t.Fatalf("spill file not written: %v", err)
+	}
+	if _, evaluations := c.received(); evaluations != 1 || bytes.Count(data, []byte("\n")) != 1 {
+		t.Errorf("collector received %d evaluations and %d were spilled, want 1 and 1", evaluations, bytes.Count(data, []byte("\n")))
+	}
+}
+
+func TestReporter_CloseTwice(t *testing.T) {
+	// The collector hangs until the test ends, so Close times out
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer server.Close()
+	defer close(release)
+
+	config := testReporterConfig(server.URL, "")
+	config.Timeout = 50 * time.Millisecond
+	config.InitialBackoff = time.Hour
+	reporter := NewReporter(config)
+	reporter.Report(CreateEvaluation())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 10; i++ {
+		if err := reporter.Close(ctx); err != nil && err != context.Canceled {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+}
+
+// waitFor polls condition until it holds or a second passes
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}